@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// envPrefix is prepended to a flag's name, upper-cased with dashes turned
+// into underscores, to get the environment variable that sets it
+const envPrefix = "MEMORY_GAME_"
+
+// Config is how the server is set up
+type Config struct {
+	Addr            string        // address to listen on
+	StaticDir       string        // directory of the web client
+	DataDir         string        // directory relative data files are kept in
+	ScoresPath      string        // "" keeps scores in memory only
+	MatchesPath     string        // "" keeps match results in memory only
+	PlayersPath     string        // "" keeps players in memory only
+	PresetsPath     string        // difficulties and themes; "" for the built-in ones
+	CORSOrigins     []string      // origins allowed to call the API
+	ShutdownTimeout time.Duration // how long to wait for requests when stopping
+}
+
+// loadConfig reads the Config from args. A flag that isn't given is taken
+// from its environment variable, such as MEMORY_GAME_ADDR for -addr, if set.
+func loadConfig(args []string) (*Config, error) {
+	var c Config
+	var origins string
+	fs := flag.NewFlagSet("memory-game", flag.ContinueOnError)
+	fs.StringVar(&c.Addr, "addr", ":8081", "address to listen on")
+	fs.StringVar(&c.StaticDir, "static", "static", "directory of the web client")
+	fs.StringVar(&c.DataDir, "data-dir", ".", "directory relative -scores, -matches and -players paths are in")
+	fs.StringVar(&c.ScoresPath, "scores", "scores.jsonl", `file scores are kept in, or "" to keep them in memory only`)
+	fs.StringVar(&c.MatchesPath, "matches", "matches.jsonl", `file multiplayer match results are kept in, or "" to keep them in memory only`)
+	fs.StringVar(&c.PlayersPath, "players", "players.jsonl", `file player profiles and their games are kept in, or "" to keep them in memory only`)
+	fs.StringVar(&c.PresetsPath, "presets", "", "JSON file of difficulties and card themes to use instead of the built-in ones")
+	fs.StringVar(&origins, "cors-origins", "", `comma-separated origins allowed to call the API from other sites, or "*" for any`)
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "how long to let requests finish when stopping")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		v, ok := os.LookupEnv(env)
+		if set[f.Name] || !ok || err != nil {
+			return
+		}
+		if e := f.Value.Set(v); e != nil {
+			err = fmt.Errorf("%s: %w", env, e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			c.CORSOrigins = append(c.CORSOrigins, o)
+		}
+	}
+	for _, p := range []*string{&c.ScoresPath, &c.MatchesPath, &c.PlayersPath} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(c.DataDir, *p)
+		}
+	}
+	return &c, nil
+}
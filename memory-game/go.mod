@@ -1,3 +1,7 @@
 module memory-game
 
-go 1.21
+go 1.22
+
+require github.com/gorilla/websocket v1.5.1
+
+require golang.org/x/net v0.17.0 // indirect
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"memory-game/models"
+	"net/http"
+	"sort"
+)
+
+// GetConfig handles GET /api/config, returning what games can be set up
+// with: the difficulty presets, smallest board first, the card themes and
+// how many players a match takes.
+func (s *Server) GetConfig(w http.ResponseWriter, r *http.Request) {
+	type preset struct {
+		ID string `json:"id"`
+		models.Difficulty
+	}
+	presets := make([]preset, 0, len(s.presets.Difficulties))
+	for id, d := range s.presets.Difficulties {
+		presets = append(presets, preset{ID: id, Difficulty: d})
+	}
+	sort.Slice(presets, func(i, j int) bool {
+		if presets[i].Pairs != presets[j].Pairs {
+			return presets[i].Pairs < presets[j].Pairs
+		}
+		return presets[i].ID < presets[j].ID
+	})
+
+	themes := make([]string, 0, len(s.presets.Themes))
+	for name := range s.presets.Themes {
+		themes = append(themes, name)
+	}
+	sort.Strings(themes)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"difficulties": presets,
+		"themes":       themes,
+		"defaultTheme": models.DefaultTheme,
+		"players": map[string]int{
+			"min": models.MinPlayers,
+			"max": models.MaxPlayers,
+		},
+	})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"testing"
+)
+
+func TestGetConfig(t *testing.T) {
+	custom := &models.Presets{
+		Difficulties: map[string]models.Difficulty{"tiny": {Name: "Tiny", Rows: 2, Cols: 2, Pairs: 2}},
+		Themes:       map[string][]string{models.DefaultTheme: {"A", "B"}},
+	}
+	s := NewServer(store.NewMemoryStore(), store.NewMatchLog(), store.NewPlayerLog(), custom, nil)
+	t.Cleanup(s.Close)
+	builtin := newTestServer(t)
+
+	var config struct {
+		Difficulties []struct{ ID string }
+		Themes       []string
+	}
+	decode(t, do(s, http.MethodGet, "/api/config", ""), &config)
+	if len(config.Difficulties) != 1 || config.Difficulties[0].ID != "tiny" || len(config.Themes) != 1 {
+		t.Fatalf("got %+v, want only the custom presets", config)
+	}
+	createGame(t, s, `{"difficulty": "tiny"}`)
+	if w := do(s, http.MethodPost, "/api/games", `{"difficulty": "easy"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("built-in difficulty on a custom server: %d, want 400", w.Code)
+	}
+
+	// Each server keeps its own presets
+	decode(t, do(builtin, http.MethodGet, "/api/config", ""), &config)
+	if len(config.Difficulties) != 3 || config.Difficulties[0].ID != "easy" {
+		t.Fatalf("built-in server got %+v", config.Difficulties)
+	}
+	if w := do(builtin, http.MethodPost, "/api/games", `{"difficulty": "tiny"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("custom difficulty on the built-in server: %d, want 400", w.Code)
+	}
+}
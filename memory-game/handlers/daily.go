@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"memory-game/models"
+	"net/http"
+	"time"
+)
+
+// dailySeed returns the seed of date's daily challenge board at difficulty.
+// It is derived from the server's secret, so the board is the same for
+// everyone all day but can't be worked out in advance.
+func (s *Server) dailySeed(date, difficulty string) int64 {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "daily %s %s", date, difficulty)
+	return int64(binary.LittleEndian.Uint64(mac.Sum(nil)))
+}
+
+// GetDaily handles GET /api/daily, returning today's challenge date and the
+// dates of past challenges that have scores, newest first:
+//
+//	{"today": "2026-10-18", "archive": ["2026-10-17", "2026-10-15"]}
+//
+// Each day's leaderboard is at /api/leaderboard?daily=<date>.
+func (s *Server) GetDaily(w http.ResponseWriter, r *http.Request) {
+	days, err := s.scores.Days()
+	if err != nil {
+		log.Printf("Listing daily challenges: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	today := time.Now().UTC().Format(models.DateFormat)
+	archive := []string{}
+	for _, day := range days {
+		if day != today {
+			archive = append(archive, day)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"today":   today,
+		"archive": archive,
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDailyBoardOncePerPlayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.jsonl")
+	players, err := store.OpenPlayerLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store.NewMemoryStore(), store.NewMatchLog(), players, nil, []byte("test key"))
+	alice, bob := createPlayer(t, s, "alice"), createPlayer(t, s, "bob")
+	daily := func(p models.Player, difficulty string) string {
+		return `{"difficulty": "` + difficulty + `", "daily": true, "player": "` + p.ID + `", "token": "` + p.Token + `"}`
+	}
+	first := createGame(t, s, daily(alice, "easy"))
+	if w := do(s, http.MethodPost, "/api/games", daily(alice, "easy")); w.Code != http.StatusConflict {
+		t.Fatalf("second daily game: %d, want 409", w.Code)
+	}
+	// Without a profile the board could be dealt again and again
+	if w := do(s, http.MethodPost, "/api/games", `{"difficulty": "easy", "daily": true}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("anonymous daily game: %d, want 400", w.Code)
+	}
+	// Other boards, and regular games, are still dealt
+	createGame(t, s, daily(alice, "medium"))
+	createGame(t, s, `{"difficulty": "easy", "player": "`+alice.ID+`", "token": "`+alice.Token+`"}`)
+	other := createGame(t, s, daily(bob, "easy"))
+	if !slices.Equal(s.games[first].Cards, s.games[other].Cards) {
+		t.Fatal("daily boards differ between players")
+	}
+	s.Close()
+	players.Close()
+
+	// A restart remembers the boards dealt
+	if players, err = store.OpenPlayerLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer players.Close()
+	s = NewServer(store.NewMemoryStore(), store.NewMatchLog(), players, nil, []byte("test key"))
+	defer s.Close()
+	if w := do(s, http.MethodPost, "/api/games", daily(alice, "easy")); w.Code != http.StatusConflict {
+		t.Fatalf("daily game after a restart: %d, want 409", w.Code)
+	}
+}
+
+func TestDailyScoreOncePerName(t *testing.T) {
+	s := newTestServer(t)
+	daily := time.Now().UTC().Format(models.DateFormat)
+	res := func(gameID, playerID string) string {
+		return s.signResult(models.Result{
+			GameID: gameID, Difficulty: "easy", Time: 30, Moves: 8,
+			Score: 8*models.MatchPoints + models.SpeedBonusMax - 30,
+			Daily: daily, PlayerID: playerID,
+		})
+	}
+	mustSubmit(t, s, "alice", res("g1", ""))
+	mustSubmit(t, s, "bob", res("g2", "p1"))
+	for _, tt := range []struct{ desc, name, result string }{
+		{"same name", "alice", res("g3", "")},
+		{"name in another case", "ALICE", res("g4", "")},
+		{"same player", "carol", res("g5", "p1")},
+	} {
+		if w := submit(s, tt.name, tt.result); w.Code != http.StatusConflict {
+			t.Errorf("%s: %d, want 409", tt.desc, w.Code)
+		}
+	}
+	// Regular games aren't limited
+	mustSubmit(t, s, "alice", result(s, "g6", "easy", 30, 8))
+	mustSubmit(t, s, "alice", result(s, "g7", "easy", 30, 8))
+	if page := leaderboard(t, s, "?daily=today"); names(page) != "1:alice 2:bob" {
+		t.Fatalf("daily leaderboard: %q", names(page))
+	}
+}
@@ -2,33 +2,94 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"memory-game/models"
+	"memory-game/store"
 	"net/http"
-	"sync"
+	"strings"
+	"time"
 )
 
-var scores []models.Score
-var mu sync.Mutex
+// maxResultBody is the largest result submission accepted, in bytes
+const maxResultBody = 4 << 10
 
-// SubmitResult handles POST /api/game/result
-func SubmitResult(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// SubmitResult handles POST /api/game/result with {"name", "result"}, where
+// result is the signed result returned by the game's last flip. Each game
+// can be submitted once, and each name and player once per daily challenge
+// board. Invalid submissions get a 400 listing each
+// problem; see writeValidationError.
+func (s *Server) SubmitResult(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string `json:"name"`
+		Result string `json:"result"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxResultBody)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		message := "must be a JSON object with a name and result"
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			message = fmt.Sprintf("must be at most %d bytes", tooLarge.Limit)
+		}
+		writeValidationError(w, models.ValidationError{{Field: "body", Message: message}})
 		return
 	}
-	var score models.Score
-	if err := json.NewDecoder(r.Body).Decode(&score); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	name := strings.TrimSpace(req.Name)
+	result, err := s.verifyResult(req.Result)
+	if err != nil {
+		problems := models.ValidationError{{Field: "result", Message: err.Error()}}
+		if fe := models.ValidateName(name); fe != nil {
+			problems = append(problems, *fe)
+		}
+		writeValidationError(w, problems)
+		return
+	}
+
+	score := models.Score{
+		Name:       name,
+		Difficulty: result.Difficulty,
+		Time:       result.Time,
+		Moves:      result.Moves,
+		Score:      result.Score,
+		GameID:     result.GameID,
+		Date:       time.Now().UTC(),
+		Daily:      result.Daily,
+		PlayerID:   result.PlayerID,
+	}
+	if problems := score.Validate(s.presets); problems != nil {
+		writeValidationError(w, problems)
+		return
+	}
+
+	err = s.scores.Add(score)
+	switch {
+	case err == store.ErrDuplicate:
+		http.Error(w, "result already submitted", http.StatusConflict)
+		return
+	case err == store.ErrDailyPlayed:
+		http.Error(w, "this daily challenge already has a score from this name or player", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Saving score: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
-	mu.Lock()
-	scores = append(scores, score)
-	mu.Unlock()
 	w.WriteHeader(http.StatusOK)
 }
 
+// writeValidationError rejects a request with a 400 and a body like
+//
+//	{"error": "invalid submission", "fields": [{"field": "name", "message": "is required"}]}
+func writeValidationError(w http.ResponseWriter, problems models.ValidationError) {
+	writeJSON(w, http.StatusBadRequest, map[string]any{
+		"error":  "invalid submission",
+		"fields": problems,
+	})
+}
+
 // HealthCheck handles GET /api/health
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
-}
\ No newline at end of file
+}
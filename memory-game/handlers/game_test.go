@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	s := newTestServer(t)
+	w := do(s, http.MethodGet, "/api/health", "")
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Fatalf("got %d %q, want 200 OK", w.Code, w.Body)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		if w := do(s, method, "/api/health", ""); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: got %d, want 405", method, w.Code)
+		}
+	}
+}
+
+func TestSubmitResult(t *testing.T) {
+	s := newTestServer(t)
+	res := result(s, "g1", "easy", 30, 10)
+	mustSubmit(t, s, "alice", res)
+
+	page := leaderboard(t, s, "")
+	if page.Total != 1 || page.Scores[0].Name != "alice" || page.Scores[0].GameID != "g1" || page.Scores[0].Moves != 10 {
+		t.Fatalf("leaderboard: %+v", page)
+	}
+	if w := submit(s, "bob", res); w.Code != http.StatusConflict {
+		t.Fatalf("resubmitting: got %d, want 409", w.Code)
+	}
+}
+
+func TestSubmitResultMethod(t *testing.T) {
+	s := newTestServer(t)
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		w := do(s, method, "/api/game/result", "")
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: got %d, want 405", method, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); !strings.Contains(allow, http.MethodPost) {
+			t.Errorf("%s: Allow is %q, want POST", method, allow)
+		}
+	}
+}
+
+func TestSubmitResultMalformed(t *testing.T) {
+	s := newTestServer(t)
+	for _, body := range []string{"", "{", "not json", `{"name": 42}`, `["alice"]`} {
+		if w := do(s, http.MethodPost, "/api/game/result", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: got %d, want 400", body, w.Code)
+		}
+	}
+	if page := leaderboard(t, s, ""); page.Total != 0 {
+		t.Fatalf("leaderboard has %d scores, want none", page.Total)
+	}
+}
+
+func TestSubmitResultInvalid(t *testing.T) {
+	s := newTestServer(t)
+	other := newTestServerKey(t, []byte("other key"))
+	valid := result(s, "g1", "easy", 30, 10)
+	tests := []struct {
+		desc, name, result string
+		fields             []string
+	}{
+		{"missing result", "alice", "", []string{"result"}},
+		{"garbled result", "alice", "abc.def", []string{"result"}},
+		{"tampered result", "alice", strings.Replace(valid, "e", "f", 1), []string{"result"}},
+		{"forged result", "alice", result(other, "g2", "easy", 30, 10), []string{"result"}},
+		{"no name", "", valid, []string{"name"}},
+		{"long name", strings.Repeat("a", 21), valid, []string{"name"}},
+		{"bad name", "<b>alice</b>", valid, []string{"name"}},
+		{"bad name and result", "", "abc", []string{"result", "name"}},
+		{"impossible score", "alice", result(s, "g3", "easy", 30, 4), []string{"moves"}},
+	}
+	for _, tt := range tests {
+		w := submit(s, tt.name, tt.result)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", tt.desc, w.Code)
+			continue
+		}
+		var resp struct {
+			Fields []struct{ Field string }
+		}
+		decode(t, w, &resp)
+		var fields []string
+		for _, f := range resp.Fields {
+			fields = append(fields, f.Field)
+		}
+		if fmt.Sprint(fields) != fmt.Sprint(tt.fields) {
+			t.Errorf("%s: fields %v, want %v", tt.desc, fields, tt.fields)
+		}
+	}
+
+	for _, body := range []string{
+		`{"name": "alice", "result": `,
+		`["alice"]`,
+		`{"name": "alice", "result": "` + strings.Repeat("a", maxResultBody) + `"}`,
+	} {
+		w := do(s, http.MethodPost, "/api/game/result", body)
+		var resp struct {
+			Error  string
+			Fields []struct{ Field, Message string }
+		}
+		if w.Code == http.StatusBadRequest {
+			decode(t, w, &resp)
+		}
+		if w.Code != http.StatusBadRequest || resp.Error == "" || len(resp.Fields) != 1 || resp.Fields[0].Field != "body" {
+			t.Errorf("body %.30s...: got %d %+v, want a 400 about the body", body, w.Code, resp)
+		}
+	}
+	if page := leaderboard(t, s, ""); page.Total != 0 {
+		t.Fatalf("leaderboard has %d scores, want none", page.Total)
+	}
+}
+
+// TestSubmitResultConcurrent submits many results at once, each game
+// several times, and is meant to be run with -race
+func TestSubmitResultConcurrent(t *testing.T) {
+	const games, tries = 20, 5
+	s := newTestServer(t)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	for g := range games {
+		res := result(s, fmt.Sprintf("g%d", g), "easy", 30+g, 8+g)
+		for try := range tries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := submit(s, fmt.Sprintf("p%d-%d", g, try), res)
+				mu.Lock()
+				codes[w.Code]++
+				mu.Unlock()
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := do(s, http.MethodGet, "/api/leaderboard?limit=100", ""); w.Code != http.StatusOK {
+				t.Errorf("leaderboard: %d %s", w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if codes[http.StatusOK] != games || codes[http.StatusConflict] != games*(tries-1) {
+		t.Fatalf("status codes %v, want %d 200s and %d 409s", codes, games, games*(tries-1))
+	}
+	page := leaderboard(t, s, "?limit=100")
+	if page.Total != games || len(page.Scores) != games {
+		t.Fatalf("leaderboard has %d scores (%d shown), want %d", page.Total, len(page.Scores), games)
+	}
+	for i, r := range page.Scores {
+		if r.Rank != i+1 || i > 0 && r.Score.Score > page.Scores[i-1].Score.Score {
+			t.Fatalf("leaderboard out of order at %d: %+v", i, page.Scores)
+		}
+	}
+}
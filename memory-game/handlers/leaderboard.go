@@ -1,38 +1,135 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"memory-game/models"
+	"memory-game/store"
 	"net/http"
-	"sort"
+	"strconv"
+	"time"
 )
 
-// GetLeaderboard handles GET /api/leaderboard?difficulty=all|easy|medium|hard
-func GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	difficulty := r.URL.Query().Get("difficulty")
-	if difficulty == "" {
-		difficulty = "all"
+// Leaderboard page sizes
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// GetLeaderboard handles GET /api/leaderboard. Query parameters:
+//
+//	difficulty  all (default) or a difficulty ID from /api/config
+//	window      all (default), daily for today or weekly for this week (UTC)
+//	daily       today or a date: that day's daily challenge board instead
+//	            of regular games
+//	sort        score (default), time or moves
+//	unique      true to show only each player's best
+//	limit       scores per page, 1 to 100 (default 10)
+//	offset      scores to skip
+//
+// It returns {"scores": [...], "total": n}, each score with its rank.
+func (s *Server) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	q, err := s.parseQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := s.scores.Query(q)
+	if err != nil {
+		log.Printf("Loading leaderboard: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GetRank handles GET /api/leaderboard/rank?name=alice, returning the
+// player's best score and its rank. It takes the same difficulty, window,
+// daily, sort and unique parameters as GetLeaderboard.
+func (s *Server) GetRank(w http.ResponseWriter, r *http.Request) {
+	q, err := s.parseQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	ranked, err := s.scores.Rank(name, q)
+	switch {
+	case err == store.ErrNotFound:
+		http.Error(w, "no score for "+name, http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Ranking %s: %v", name, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ranked)
+}
+
+// parseQuery reads a leaderboard query from r's parameters
+func (s *Server) parseQuery(r *http.Request, now time.Time) (store.Query, error) {
+	params := r.URL.Query()
+	q := store.Query{Limit: defaultLimit}
 
-	mu.Lock()
-	var filtered []models.Score
-	for _, score := range scores {
-		if difficulty == "all" || score.Difficulty == difficulty {
-			filtered = append(filtered, score)
+	if q.Difficulty = params.Get("difficulty"); q.Difficulty != "" && q.Difficulty != "all" {
+		if _, ok := s.presets.Difficulties[q.Difficulty]; !ok {
+			return q, errors.New("difficulty must be all or a known difficulty")
 		}
 	}
-	mu.Unlock()
 
-	// Sort by score descending
-	sort.Slice(filtered, func(i, j int) bool {
-		return filtered[i].Score > filtered[j].Score
-	})
+	today := now.UTC().Truncate(24 * time.Hour)
+	switch q.Daily = params.Get("daily"); q.Daily {
+	case "":
+	case "today":
+		q.Daily = today.Format(models.DateFormat)
+	default:
+		if _, err := time.Parse(models.DateFormat, q.Daily); err != nil {
+			return q, errors.New("daily must be today or a date like 2006-01-02")
+		}
+	}
 
-	// Return top 10
-	if len(filtered) > 10 {
-		filtered = filtered[:10]
+	switch params.Get("window") {
+	case "", "all":
+	case "daily":
+		q.Since = today
+	case "weekly":
+		// Weeks start on Monday
+		q.Since = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	default:
+		return q, errors.New("window must be all, daily or weekly")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(filtered)
-}
\ No newline at end of file
+	switch q.Sort = params.Get("sort"); q.Sort {
+	case "", store.SortScore, store.SortTime, store.SortMoves:
+	default:
+		return q, errors.New("sort must be score, time or moves")
+	}
+
+	if v := params.Get("unique"); v != "" {
+		unique, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("unique must be true or false")
+		}
+		q.Unique = unique
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, errors.New("limit must be from 1 to 100")
+		}
+		q.Limit = limit
+	}
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return q, errors.New("offset must be a number, at least 0")
+		}
+		q.Offset = offset
+	}
+	return q, nil
+}
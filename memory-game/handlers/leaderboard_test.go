@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"memory-game/store"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// names lists a page's scores as rank:name
+func names(page store.Page) string {
+	var names []string
+	for _, r := range page.Scores {
+		names = append(names, fmt.Sprintf("%d:%s", r.Rank, r.Name))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestGetLeaderboardDifficulty(t *testing.T) {
+	s := newTestServer(t)
+	mustSubmit(t, s, "alice", result(s, "g1", "easy", 30, 8))
+	mustSubmit(t, s, "bob", result(s, "g2", "medium", 60, 18))
+	mustSubmit(t, s, "carol", result(s, "g3", "easy", 50, 8))
+
+	tests := map[string]string{
+		"":                   "1:bob 2:alice 3:carol",
+		"?difficulty=all":    "1:bob 2:alice 3:carol",
+		"?difficulty=easy":   "1:alice 2:carol",
+		"?difficulty=medium": "1:bob",
+		"?difficulty=hard":   "",
+	}
+	for query, want := range tests {
+		if got := names(leaderboard(t, s, query)); got != want {
+			t.Errorf("%s: got %q, want %q", query, got, want)
+		}
+	}
+	if w := do(s, http.MethodGet, "/api/leaderboard?difficulty=impossible", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown difficulty: got %d, want 400", w.Code)
+	}
+}
+
+func TestGetLeaderboardOrder(t *testing.T) {
+	s := newTestServer(t)
+	// alice scores best, bob is fastest and carol took the fewest moves
+	mustSubmit(t, s, "alice", result(s, "g1", "easy", 20, 10))
+	mustSubmit(t, s, "bob", result(s, "g2", "easy", 10, 24))
+	mustSubmit(t, s, "carol", result(s, "g3", "easy", 40, 8))
+
+	tests := map[string]string{
+		"":            "1:alice 2:carol 3:bob",
+		"?sort=score": "1:alice 2:carol 3:bob",
+		"?sort=time":  "1:bob 2:alice 3:carol",
+		"?sort=moves": "1:carol 2:alice 3:bob",
+	}
+	for query, want := range tests {
+		if got := names(leaderboard(t, s, query)); got != want {
+			t.Errorf("%s: got %q, want %q", query, got, want)
+		}
+	}
+	if w := do(s, http.MethodGet, "/api/leaderboard?sort=name", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort: got %d, want 400", w.Code)
+	}
+}
+
+func TestGetLeaderboardTopTen(t *testing.T) {
+	s := newTestServer(t)
+	for i := range 15 {
+		// Each player is a second slower than the last, so scores a point
+		// lower
+		mustSubmit(t, s, fmt.Sprintf("p%02d", i), result(s, fmt.Sprintf("g%d", i), "easy", 10+i, 8))
+	}
+
+	page := leaderboard(t, s, "")
+	if page.Total != 15 || len(page.Scores) != 10 {
+		t.Fatalf("got %d of %d scores, want 10 of 15", len(page.Scores), page.Total)
+	}
+	for i, r := range page.Scores {
+		if want := fmt.Sprintf("p%02d", i); r.Rank != i+1 || r.Name != want {
+			t.Fatalf("score %d is %d:%s, want %d:%s", i, r.Rank, r.Name, i+1, want)
+		}
+	}
+
+	if page := leaderboard(t, s, "?offset=10"); names(page) != "11:p10 12:p11 13:p12 14:p13 15:p14" {
+		t.Errorf("second page: %q", names(page))
+	}
+	if page := leaderboard(t, s, "?limit=100"); len(page.Scores) != 15 {
+		t.Errorf("limit=100: got %d scores, want 15", len(page.Scores))
+	}
+	for _, limit := range []string{"0", "101", "ten"} {
+		if w := do(s, http.MethodGet, "/api/leaderboard?limit="+limit, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: got %d, want 400", limit, w.Code)
+		}
+	}
+}
+
+func TestGetLeaderboardMethod(t *testing.T) {
+	s := newTestServer(t)
+	if w := do(s, http.MethodPost, "/api/leaderboard", "{}"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: got %d, want 405", w.Code)
+	}
+}
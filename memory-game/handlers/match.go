@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Lobby runs multiplayer matches over websockets. It seats players in an
+// open match for the difficulty and number of players they ask for, or in
+// a friend's match by ID, relays every flip to everyone in the match, and
+// records each finished match in a MatchStore. Turns and matches that run
+// out of time are ended by a timer.
+type Lobby struct {
+	results  store.MatchStore
+	presets  *models.Presets
+	upgrader websocket.Upgrader
+	stop     chan struct{} // closed by Close to stop the timer
+
+	mu      sync.Mutex
+	rooms   map[string]*room // by match ID
+	waiting map[string]*room // the open match for each queue, by queueKey
+	closed  bool
+}
+
+// room is a match and its players' sockets, by seat
+type room struct {
+	match   *models.Match
+	board   models.Difficulty // the match's difficulty
+	sockets []*socket
+	queue   string // queueKey the match was opened for
+}
+
+var (
+	errMatchNotFound = errors.New("match not found")
+	errLobbyClosed   = errors.New("server is shutting down")
+)
+
+// timeoutInterval is how often matches are checked for turns and matches
+// that have run out of time
+const timeoutInterval = time.Second
+
+// NewLobby returns a Lobby that deals matches from presets and records
+// results in results
+func NewLobby(results store.MatchStore, presets *models.Presets) *Lobby {
+	l := &Lobby{
+		results: results,
+		presets: presets,
+		stop:    make(chan struct{}),
+		rooms:   make(map[string]*room),
+		waiting: make(map[string]*room),
+	}
+	go l.runTimeouts(l.stop)
+	return l
+}
+
+func queueKey(difficulty string, size int) string {
+	return fmt.Sprintf("%s/%d", difficulty, size)
+}
+
+// ServeMatch handles GET /api/matches/ws, upgrading it to a websocket for
+// one player in a match. Query parameters:
+//
+//	name        the player's name, as on the leaderboard
+//	difficulty  a difficulty ID from /api/config (default easy)
+//	players     players in the match, 2 (default) to 4
+//	theme       card theme, if the player opens a new match
+//	match       ID of a waiting match to join instead of the queue
+//
+// See the README for the messages sent over the socket.
+func (l *Lobby) ServeMatch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	name := strings.TrimSpace(params.Get("name"))
+	if fe := models.ValidateName(name); fe != nil {
+		http.Error(w, fe.Field+" "+fe.Message, http.StatusBadRequest)
+		return
+	}
+	difficulty, size := "easy", models.MinPlayers
+	if v := params.Get("difficulty"); v != "" {
+		difficulty = v
+	}
+	if _, ok := l.presets.Difficulties[difficulty]; !ok {
+		http.Error(w, "unknown difficulty", http.StatusBadRequest)
+		return
+	}
+	if v := params.Get("players"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < models.MinPlayers || n > models.MaxPlayers {
+			http.Error(w, models.ErrBadPlayerNum.Error(), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	// The seat is taken before upgrading so that a full match or a taken
+	// name gets a plain HTTP error. Messages for the player queue up until
+	// the socket is open.
+	sock := newSocket()
+	l.mu.Lock()
+	rm, err := l.join(sock, name, difficulty, params.Get("theme"), size, params.Get("match"))
+	l.mu.Unlock()
+	switch {
+	case err == errMatchNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err == models.ErrMatchFull, err == models.ErrNameTaken, err == errLobbyClosed:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		// The match couldn't be dealt, e.g. for an unknown theme
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		l.leave(rm, sock)
+		return
+	}
+	sock.start(conn)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		var msg struct {
+			Type  string `json:"type"`
+			Index *int   `json:"index"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "flip" || msg.Index == nil {
+			sock.send(errorEvent("expected {\"type\": \"flip\", \"index\": n}"))
+			continue
+		}
+		l.flip(rm, sock, *msg.Index)
+	}
+	l.leave(rm, sock)
+}
+
+// join seats sock's player in the match with the given ID, or else in the
+// open match for difficulty and size, opening one dealt from theme if there
+// is none. l.mu must be held.
+func (l *Lobby) join(sock *socket, name, difficulty, theme string, size int, matchID string) (*room, error) {
+	if l.closed {
+		return nil, errLobbyClosed
+	}
+	var rm *room
+	if matchID != "" {
+		if rm = l.rooms[matchID]; rm == nil {
+			return nil, errMatchNotFound
+		}
+	} else if rm = l.waiting[queueKey(difficulty, size)]; rm == nil {
+		match, err := l.presets.NewMatch(randomID(), difficulty, theme, size, randomSeed(), time.Now())
+		if err != nil {
+			return nil, err
+		}
+		rm = &room{match: match, board: l.presets.Difficulties[difficulty], queue: queueKey(difficulty, size)}
+		l.rooms[match.ID] = rm
+		l.waiting[rm.queue] = rm
+	}
+	if _, err := rm.match.Join(name, time.Now()); err != nil {
+		return nil, err
+	}
+	rm.sockets = append(rm.sockets, sock)
+
+	if rm.match.Status == models.MatchWaiting {
+		rm.broadcast(rm.event("waiting"))
+		return rm, nil
+	}
+	if l.waiting[rm.queue] == rm {
+		delete(l.waiting, rm.queue)
+	}
+	for seat, s := range rm.sockets {
+		ev := rm.event("start")
+		ev["you"] = seat
+		s.send(ev)
+	}
+	return rm, nil
+}
+
+// flip turns over a card for sock's player and tells everyone in the match
+func (l *Lobby) flip(rm *room, sock *socket, index int) {
+	l.mu.Lock()
+	res, err := rm.match.Flip(rm.seat(sock), index, time.Now())
+	if err != nil {
+		// Running out of time ends the match
+		result, finished := l.finishIfOver(rm)
+		l.mu.Unlock()
+		sock.send(errorEvent(err.Error()))
+		if finished {
+			l.record(result)
+		}
+		return
+	}
+	ev := rm.event("flip")
+	ev["player"] = rm.seat(sock)
+	ev["index"] = res.Index
+	ev["symbol"] = res.Symbol
+	ev["hidden"] = res.Hidden
+	ev["pair"] = res.Pair
+	ev["matched"] = res.Matched
+	rm.broadcast(ev)
+	result, finished := l.finishIfOver(rm)
+	l.mu.Unlock()
+
+	if finished {
+		l.record(result)
+	}
+}
+
+// leave gives up sock's seat when the player disconnects
+func (l *Lobby) leave(rm *room, sock *socket) {
+	defer sock.close()
+	l.mu.Lock()
+	seat := rm.seat(sock)
+	if seat < 0 || rm.match.Status == models.MatchFinished || l.closed {
+		l.mu.Unlock()
+		return
+	}
+	waiting := rm.match.Status == models.MatchWaiting
+	hidden := rm.match.Leave(seat, time.Now())
+	if waiting {
+		rm.sockets = append(rm.sockets[:seat], rm.sockets[seat+1:]...)
+		if len(rm.sockets) == 0 {
+			delete(l.rooms, rm.match.ID)
+			if l.waiting[rm.queue] == rm {
+				delete(l.waiting, rm.queue)
+			}
+		} else {
+			rm.broadcast(rm.event("waiting"))
+		}
+		l.mu.Unlock()
+		return
+	}
+	ev := rm.event("left")
+	ev["player"] = seat
+	ev["hidden"] = hidden
+	rm.broadcast(ev)
+	result, finished := l.finishIfOver(rm)
+	l.mu.Unlock()
+
+	if finished {
+		l.record(result)
+	}
+}
+
+// runTimeouts calls checkTimeouts every timeoutInterval until stop is closed
+func (l *Lobby) runTimeouts(stop <-chan struct{}) {
+	ticker := time.NewTicker(timeoutInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			l.checkTimeouts(now)
+		}
+	}
+}
+
+// checkTimeouts passes the turn of players who have taken too long to flip,
+// and ends matches that have run out of time
+func (l *Lobby) checkTimeouts(now time.Time) {
+	var results []models.MatchResult
+	l.mu.Lock()
+	for _, rm := range l.rooms {
+		seat, hidden := rm.match.Timeout(now)
+		if seat >= 0 {
+			ev := rm.event("timeout")
+			ev["player"] = seat
+			ev["hidden"] = hidden
+			rm.broadcast(ev)
+		}
+		if result, finished := l.finishIfOver(rm); finished {
+			results = append(results, result)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, result := range results {
+		l.record(result)
+	}
+}
+
+// finishIfOver closes rm if its match has ended, sending its players the
+// result before disconnecting them. l.mu must be held.
+func (l *Lobby) finishIfOver(rm *room) (models.MatchResult, bool) {
+	if rm.match.Status != models.MatchFinished {
+		return models.MatchResult{}, false
+	}
+	delete(l.rooms, rm.match.ID)
+	result := rm.match.Result()
+	rm.broadcast(map[string]any{"type": "end", "result": result})
+	for _, s := range rm.sockets {
+		s.closeAfterSent()
+	}
+	return result, true
+}
+
+func (l *Lobby) record(result models.MatchResult) {
+	if err := l.results.Add(result); err != nil {
+		log.Printf("Saving match %s: %v", result.MatchID, err)
+	}
+}
+
+// Close disconnects every player, for when the server shuts down, and
+// waits briefly for them to be told why. Matches in progress are dropped
+// without a result. Close may be called more than once.
+func (l *Lobby) Close() {
+	var sockets []*socket
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	close(l.stop)
+	for _, rm := range l.rooms {
+		rm.broadcast(errorEvent("server is shutting down"))
+		for _, s := range rm.sockets {
+			s.closeAfterSent()
+			sockets = append(sockets, s)
+		}
+	}
+	l.mu.Unlock()
+
+	timeout := time.After(writeWait)
+	for _, s := range sockets {
+		select {
+		case <-s.stopped:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// RecentMatches handles GET /api/matches?limit=10, returning the latest
+// finished matches, newest first, as {"matches": [...]}
+func (l *Lobby) RecentMatches(w http.ResponseWriter, r *http.Request) {
+	limit := defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			http.Error(w, "limit must be from 1 to 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	matches, err := l.results.Recent(limit)
+	if err != nil {
+		log.Printf("Loading matches: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"matches": matches})
+}
+
+// seat returns the seat of sock's player, or -1
+func (rm *room) seat(sock *socket) int {
+	for i, s := range rm.sockets {
+		if s == sock {
+			return i
+		}
+	}
+	return -1
+}
+
+// event returns a message of type typ carrying the match's state. Players
+// are copied, as the message is encoded later by each socket's writer.
+func (rm *room) event(typ string) map[string]any {
+	d := rm.board
+	players := make([]models.MatchPlayer, len(rm.match.Players))
+	for i, p := range rm.match.Players {
+		players[i] = *p
+	}
+	return map[string]any{
+		"type":       typ,
+		"match":      rm.match.ID,
+		"difficulty": rm.match.Game.Difficulty,
+		"rows":       d.Rows,
+		"cols":       d.Cols,
+		"size":       rm.match.Size,
+		"theme":      rm.match.Game.Theme,
+		"timeLimit":  rm.match.Game.TimeLimit,
+		"turnLimit":  int(models.TurnLimit / time.Second),
+		"players":    players,
+		"turn":       rm.match.Turn,
+		"status":     rm.match.Status,
+	}
+}
+
+func (rm *room) broadcast(msg any) {
+	for _, s := range rm.sockets {
+		s.send(msg)
+	}
+}
+
+func errorEvent(message string) map[string]any {
+	return map[string]any{"type": "error", "message": message}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"memory-game/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// matchEvent is the part of a match message the tests look at
+type matchEvent struct {
+	Type   string              `json:"type"`
+	Match  string              `json:"match"`
+	Turn   int                 `json:"turn"`
+	Player int                 `json:"player"`
+	Hidden []int               `json:"hidden"`
+	Result *models.MatchResult `json:"result"`
+}
+
+// dialMatch connects a player to the lobby of s, served by ts
+func dialMatch(t *testing.T, ts *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/matches/ws?" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("joining with %s: %v (%v)", query, err, resp)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expect reads messages from conn until one of type typ
+func expect(t *testing.T, conn *websocket.Conn, typ string) matchEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var ev matchEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		if ev.Type == typ {
+			return ev
+		}
+	}
+}
+
+// startMatch seats alice and bob in a two player match at difficulty
+func startMatch(t *testing.T, s *Server, difficulty string) (alice, bob *websocket.Conn, matchID string) {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	alice = dialMatch(t, ts, "name=alice&difficulty="+difficulty)
+	matchID = expect(t, alice, "waiting").Match
+	bob = dialMatch(t, ts, "name=bob&difficulty="+difficulty)
+	expect(t, alice, "start")
+	expect(t, bob, "start")
+	return alice, bob, matchID
+}
+
+func TestServeMatchRefused(t *testing.T) {
+	s := newTestServer(t)
+	_, _, full := startMatch(t, s, "easy")
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"name=", http.StatusBadRequest},
+		{"name=carol&difficulty=impossible", http.StatusBadRequest},
+		{"name=carol&players=5", http.StatusBadRequest},
+		{"name=carol&theme=plants", http.StatusBadRequest},
+		{"name=carol&match=nope", http.StatusNotFound},
+		{"name=carol&match=" + full, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if w := do(s, http.MethodGet, "/api/matches/ws?"+tt.query, ""); w.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d", tt.query, w.Code, w.Body, tt.code)
+		}
+	}
+
+	s.lobby.Close()
+	if w := do(s, http.MethodGet, "/api/matches/ws?name=carol", ""); w.Code != http.StatusConflict {
+		t.Errorf("joining after Close: %d, want 409", w.Code)
+	}
+}
+
+func TestServeMatchNameTaken(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	alice := dialMatch(t, ts, "name=alice&players=3")
+	id := expect(t, alice, "waiting").Match
+	if w := do(s, http.MethodGet, "/api/matches/ws?name=alice&match="+id, ""); w.Code != http.StatusConflict {
+		t.Fatalf("name taken: %d, want 409", w.Code)
+	}
+}
+
+func TestMatchTurnTimeout(t *testing.T) {
+	s := newTestServer(t)
+	// The hard board's time limit outlasts every timeout below
+	alice, bob, _ := startMatch(t, s, "hard")
+	alice.WriteJSON(map[string]any{"type": "flip", "index": 0})
+	expect(t, bob, "flip")
+
+	now := time.Now().Add(models.TurnLimit + time.Second)
+	s.lobby.checkTimeouts(now)
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		ev := expect(t, conn, "timeout")
+		if ev.Player != 0 || ev.Turn != 1 || len(ev.Hidden) != 1 || ev.Hidden[0] != 0 {
+			t.Fatalf("timeout event %+v, want alice's card 0 hidden and bob's turn", ev)
+		}
+	}
+
+	// bob never flips, and forfeits on his third missed turn
+	for i := range models.MaxMissedTurns {
+		now = now.Add(models.TurnLimit + time.Second)
+		s.lobby.checkTimeouts(now)
+		if ev := expect(t, alice, "timeout"); ev.Player != 1 {
+			t.Fatalf("round %d: player %d timed out, want bob", i, ev.Player)
+		}
+		if i < models.MaxMissedTurns-1 {
+			// alice times out too, but flips in between, so she plays on
+			alice.WriteJSON(map[string]any{"type": "flip", "index": 0})
+			expect(t, alice, "flip")
+			now = now.Add(models.TurnLimit + time.Second)
+			s.lobby.checkTimeouts(now)
+			expect(t, alice, "timeout")
+		}
+	}
+	end := expect(t, alice, "end")
+	if got := end.Result.Winners; len(got) != 1 || got[0] != "alice" {
+		t.Fatalf("winners %v, want alice", got)
+	}
+	matches, _ := s.lobby.results.Recent(1)
+	if len(matches) != 1 || !matches[0].Players[1].Left {
+		t.Fatalf("recorded %+v, want bob's forfeit", matches)
+	}
+}
+
+func TestMatchDeadline(t *testing.T) {
+	s := newTestServer(t)
+	alice, bob, _ := startMatch(t, s, "easy")
+	// Nobody flips; the easy board's time limit is 3 minutes
+	s.lobby.checkTimeouts(time.Now().Add(3*time.Minute + time.Second))
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if ev := expect(t, conn, "end"); ev.Result == nil || len(ev.Result.Winners) != 2 {
+			t.Fatalf("end event %+v, want a tie", ev)
+		}
+	}
+	if matches, _ := s.lobby.results.Recent(10); len(matches) != 1 {
+		t.Fatalf("recorded %d matches, want 1", len(matches))
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errBadPlayer = errors.New("invalid player or token")
+
+// CreatePlayer handles POST /api/players with {"name": "alice"}. It creates
+// a profile and returns its id, name and token. The token is only returned
+// here; games started with the id and token are linked to the profile.
+func (s *Server) CreatePlayer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if fe := models.ValidateName(name); fe != nil {
+		writeValidationError(w, models.ValidationError{*fe})
+		return
+	}
+	player := models.Player{
+		ID:      randomID(),
+		Name:    name,
+		Token:   randomID(),
+		Created: time.Now().UTC(),
+	}
+	if err := s.players.Create(player); err != nil {
+		log.Printf("Creating player: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, player)
+}
+
+// GetPlayer handles GET /api/players/{id}, returning the profile's id, name
+// and created date
+func (s *Server) GetPlayer(w http.ResponseWriter, r *http.Request) {
+	if profile, ok := s.loadProfile(w, r); ok {
+		writeJSON(w, http.StatusOK, profile)
+	}
+}
+
+// GetPlayerStats handles GET /api/players/{id}/stats, returning the profile
+// with its stats for each difficulty played
+func (s *Server) GetPlayerStats(w http.ResponseWriter, r *http.Request) {
+	profile, ok := s.loadProfile(w, r)
+	if !ok {
+		return
+	}
+	id := profile["id"].(string)
+	games, err := s.players.Games(id, "", 0)
+	if err != nil {
+		log.Printf("Loading games of player %s: %v", id, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	profile["stats"] = models.SummarizeGames(games)
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// GetPlayerHistory handles GET /api/players/{id}/history, returning the
+// profile with its last games, oldest first. It takes an optional
+// difficulty and a limit, 1 to 100 (default 100).
+func (s *Server) GetPlayerHistory(w http.ResponseWriter, r *http.Request) {
+	profile, ok := s.loadProfile(w, r)
+	if !ok {
+		return
+	}
+	params := r.URL.Query()
+	limit := maxLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			http.Error(w, "limit must be from 1 to 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	id := profile["id"].(string)
+	games, err := s.players.Games(id, params.Get("difficulty"), limit)
+	if err != nil {
+		log.Printf("Loading games of player %s: %v", id, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	profile["games"] = games
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// loadProfile returns the public part of the profile named by the request's
+// id. If there is none, it writes the error and returns false.
+func (s *Server) loadProfile(w http.ResponseWriter, r *http.Request) (map[string]any, bool) {
+	id := r.PathValue("id")
+	player, err := s.players.Player(id)
+	switch {
+	case err == store.ErrNoPlayer:
+		http.Error(w, "player not found", http.StatusNotFound)
+		return nil, false
+	case err != nil:
+		log.Printf("Loading player %s: %v", id, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return map[string]any{
+		"id":      player.ID,
+		"name":    player.Name,
+		"created": player.Created,
+	}, true
+}
+
+// checkPlayer returns errBadPlayer unless token is the token of the
+// profile with the given ID
+func (s *Server) checkPlayer(id, token string) error {
+	player, err := s.players.Player(id)
+	if err == store.ErrNoPlayer {
+		return errBadPlayer
+	}
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(player.Token)) != 1 {
+		return errBadPlayer
+	}
+	return nil
+}
+
+// recordGame saves how a game linked to a player ended
+func (s *Server) recordGame(g *models.Game, won bool, now time.Time) {
+	elapsed := g.Elapsed(now)
+	if g.TimeLimit > 0 {
+		elapsed = min(elapsed, g.TimeLimit)
+	}
+	err := s.players.AddGame(models.GameRecord{
+		PlayerID:   g.PlayerID,
+		GameID:     g.ID,
+		Difficulty: g.Difficulty,
+		Won:        won,
+		Time:       elapsed,
+		Moves:      g.Moves,
+		Score:      g.Score,
+		Date:       now.UTC(),
+	})
+	if err != nil {
+		log.Printf("Recording game %s of player %s: %v", g.ID, g.PlayerID, err)
+	}
+}
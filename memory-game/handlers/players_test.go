@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetPlayerHistory(t *testing.T) {
+	s := newTestServer(t)
+	p := createPlayer(t, s, "alice")
+	for i, difficulty := range []string{"easy", "hard", "easy", "easy"} {
+		s.players.AddGame(models.GameRecord{PlayerID: p.ID, GameID: fmt.Sprint("g", i), Difficulty: difficulty, Date: time.Now()})
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "g0 g1 g2 g3"},
+		{"?limit=2", "g2 g3"},
+		{"?difficulty=easy&limit=2", "g2 g3"},
+		{"?difficulty=hard", "g1"},
+		{"?difficulty=medium", ""},
+	}
+	for _, tt := range tests {
+		w := do(s, http.MethodGet, "/api/players/"+p.ID+"/history"+tt.query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", tt.query, w.Code, w.Body)
+		}
+		var resp struct{ Games []models.GameRecord }
+		decode(t, w, &resp)
+		var ids []string
+		for _, g := range resp.Games {
+			ids = append(ids, g.GameID)
+		}
+		if got := strings.Join(ids, " "); got != tt.want || resp.Games == nil {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	for _, target := range []string{"/api/players/" + p.ID + "/history?limit=0", "/api/players/nobody/history"} {
+		if w := do(s, http.MethodGet, target, ""); w.Code == http.StatusOK {
+			t.Errorf("%s: %d", target, w.Code)
+		}
+	}
+}
+
+func TestCloseRecordsUnfinishedGames(t *testing.T) {
+	players := store.NewPlayerLog()
+	s := NewServer(store.NewMemoryStore(), store.NewMatchLog(), players, nil, []byte("test key"))
+	p := createPlayer(t, s, "alice")
+	linked := `{"difficulty": "easy", "player": "` + p.ID + `", "token": "` + p.Token + `"}`
+	started := createGame(t, s, linked)
+	flip(s, started, 0)
+	fresh := createGame(t, s, linked)
+	createGame(t, s, `{"difficulty": "easy"}`)
+	s.Close()
+
+	games, _ := players.Games(p.ID, "", 0)
+	if len(games) != 2 {
+		t.Fatalf("recorded %+v, want both of alice's games", games)
+	}
+	for _, g := range games {
+		if g.Won || (g.GameID != started && g.GameID != fresh) {
+			t.Errorf("recorded %+v, want a loss", g)
+		}
+	}
+	if len(s.games) != 0 {
+		t.Errorf("%d games kept after Close", len(s.games))
+	}
+}
+
+func TestExpireGamesRecordsAbandoned(t *testing.T) {
+	s := newTestServer(t)
+	p := createPlayer(t, s, "alice")
+	id := createGame(t, s, `{"difficulty": "easy", "player": "`+p.ID+`", "token": "`+p.Token+`"}`)
+	flip(s, id, 0)
+	s.expireGames(time.Now().Add(gameTTL / 2))
+	if games, _ := s.players.Games(p.ID, "", 0); len(games) != 1 || games[0].Won || games[0].Time != 180 {
+		t.Fatalf("recorded %+v, want a loss at the easy board's time limit", games)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"memory-game/models"
+	"strings"
+)
+
+var errBadResult = errors.New("invalid game result")
+
+// signResult encodes r as a token the server can later verify it issued
+func (s *Server) signResult(r models.Result) string {
+	payload, _ := json.Marshal(r)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyResult decodes a token made by signResult
+func (s *Server) verifyResult(token string) (models.Result, error) {
+	var r models.Result
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return r, errBadResult
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return r, errBadResult
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return r, errBadResult
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return r, errBadResult
+	}
+	if err := json.Unmarshal(payload, &r); err != nil {
+		return r, errBadResult
+	}
+	return r, nil
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Server serves the game's API. It holds everything the handlers share:
+// the stores, the presets games are dealt from, the key results are signed
+// with, the games in progress and the multiplayer lobby.
+type Server struct {
+	scores  store.ScoreStore
+	players store.PlayerStore
+	presets *models.Presets
+	lobby   *Lobby
+	key     []byte // signs game results and seeds daily boards
+	mux     *http.ServeMux
+
+	gamesMu sync.Mutex
+	games   map[string]*models.Game
+	stop    chan struct{} // closed by Close to stop sweeping games
+}
+
+// NewServer returns a Server keeping scores, match results and players in
+// the given stores and dealing games from presets, or the built-in presets
+// if it is nil. Results are signed with key; if it is nil a random key is
+// used, so results from before a restart are rejected.
+func NewServer(scores store.ScoreStore, matches store.MatchStore, players store.PlayerStore, presets *models.Presets, key []byte) *Server {
+	if presets == nil {
+		presets = models.DefaultPresets()
+	}
+	if key == nil {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	s := &Server{
+		scores:  scores,
+		players: players,
+		presets: presets,
+		lobby:   NewLobby(matches, presets),
+		key:     key,
+		mux:     http.NewServeMux(),
+		games:   make(map[string]*models.Game),
+		stop:    make(chan struct{}),
+	}
+	go s.sweepGames(s.stop)
+
+	s.mux.HandleFunc("GET /api/config", s.GetConfig)
+	s.mux.HandleFunc("POST /api/games", s.CreateGame)
+	s.mux.HandleFunc("POST /api/games/{id}/flip", s.FlipCard)
+	s.mux.HandleFunc("POST /api/game/result", s.SubmitResult)
+	s.mux.HandleFunc("GET /api/leaderboard", s.GetLeaderboard)
+	s.mux.HandleFunc("GET /api/leaderboard/rank", s.GetRank)
+	s.mux.HandleFunc("GET /api/daily", s.GetDaily)
+	s.mux.HandleFunc("POST /api/players", s.CreatePlayer)
+	s.mux.HandleFunc("GET /api/players/{id}", s.GetPlayer)
+	s.mux.HandleFunc("GET /api/players/{id}/stats", s.GetPlayerStats)
+	s.mux.HandleFunc("GET /api/players/{id}/history", s.GetPlayerHistory)
+	s.mux.HandleFunc("GET /api/matches", s.lobby.RecentMatches)
+	s.mux.HandleFunc("GET /api/matches/ws", s.lobby.ServeMatch)
+	s.mux.HandleFunc("GET /api/health", HealthCheck)
+	return s
+}
+
+// ServeHTTP routes a request under /api/ to its handler. Requests with the
+// wrong method get a 405.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close stops discarding expired games and disconnects multiplayer
+// players, for when the server shuts down. Games in progress can't be
+// finished after a restart, so players' unfinished games are recorded as
+// lost. The stores are left open.
+func (s *Server) Close() {
+	close(s.stop)
+	s.lobby.Close()
+
+	now := time.Now()
+	var unfinished []*models.Game
+	s.gamesMu.Lock()
+	for id, g := range s.games {
+		if g.PlayerID != "" && !g.Over() {
+			unfinished = append(unfinished, g)
+		}
+		delete(s.games, id)
+	}
+	s.gamesMu.Unlock()
+	for _, g := range unfinished {
+		s.recordGame(g, false, now)
+	}
+}
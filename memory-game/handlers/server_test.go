@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns a Server keeping everything in memory
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServerKey(t, []byte("test key"))
+}
+
+// newTestServerKey returns a Server keeping everything in memory and
+// signing results with key, closed when the test is done
+func newTestServerKey(t *testing.T, key []byte) *Server {
+	t.Helper()
+	s := NewServer(store.NewMemoryStore(), store.NewMatchLog(), store.NewPlayerLog(), nil, key)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// do sends a request with body, if not empty, to s and returns the
+// response
+func do(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+// decode decodes w's JSON body into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+// result returns a signed result for a game at difficulty finished in
+// seconds and moves, with the best score those allow
+func result(s *Server, gameID, difficulty string, seconds, moves int) string {
+	d := s.presets.Difficulties[difficulty]
+	score := d.Pairs*models.MatchPoints + max(0, models.SpeedBonusMax-seconds) - (moves-d.Pairs)*models.MissPenalty
+	return s.signResult(models.Result{
+		GameID:     gameID,
+		Difficulty: difficulty,
+		Time:       seconds,
+		Moves:      moves,
+		Score:      max(0, score),
+	})
+}
+
+// submit posts a result under name and returns the response
+func submit(s *Server, name, result string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"name": name, "result": result})
+	return do(s, http.MethodPost, "/api/game/result", string(body))
+}
+
+// mustSubmit submits a result and fails the test unless it is accepted
+func mustSubmit(t *testing.T, s *Server, name, result string) {
+	t.Helper()
+	if w := submit(s, name, result); w.Code != http.StatusOK {
+		t.Fatalf("submitting for %s: %d %s", name, w.Code, w.Body)
+	}
+}
+
+// leaderboard gets /api/leaderboard with query, failing the test unless it
+// succeeds
+func leaderboard(t *testing.T, s *Server, query string) store.Page {
+	t.Helper()
+	w := do(s, http.MethodGet, "/api/leaderboard"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("leaderboard%s: %d %s", query, w.Code, w.Body)
+	}
+	var page store.Page
+	decode(t, w, &page)
+	return page
+}
+
+// createPlayer creates a profile through the API
+func createPlayer(t *testing.T, s *Server, name string) models.Player {
+	t.Helper()
+	w := do(s, http.MethodPost, "/api/players", `{"name": "`+name+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating player %s: %d %s", name, w.Code, w.Body)
+	}
+	var p models.Player
+	decode(t, w, &p)
+	return p
+}
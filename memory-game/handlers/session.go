@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"memory-game/models"
+	"memory-game/store"
+	"net/http"
+	"time"
+)
+
+// gameTTL is how long an unfinished game is kept before it is discarded
+const gameTTL = time.Hour
+
+// sweepInterval is how often games that are over or expired are discarded
+const sweepInterval = time.Minute
+
+// CreateGame handles POST /api/games with {"difficulty": "easy"}. It deals a
+// new board and returns its size and time limit, but not the card faces. An
+// optional "theme" picks the faces. With "daily": true it deals today's
+// daily challenge board instead, which is the same for every player of the
+// difficulty until midnight UTC. With a "player" ID and its "token", the
+// game counts towards that player's stats. The daily board is only dealt to
+// players, once each, and the player store records it, so a restart doesn't
+// deal it again.
+func (s *Server) CreateGame(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Difficulty string `json:"difficulty"`
+		Theme      string `json:"theme"`
+		Daily      bool   `json:"daily"`
+		Player     string `json:"player"`
+		Token      string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Daily && req.Player == "" {
+		http.Error(w, "the daily challenge needs a player profile", http.StatusBadRequest)
+		return
+	}
+	if req.Player != "" {
+		if err := s.checkPlayer(req.Player, req.Token); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	now := time.Now()
+	seed, daily := randomSeed(), ""
+	if req.Daily {
+		daily = now.UTC().Format(models.DateFormat)
+		seed = s.dailySeed(daily, req.Difficulty)
+	}
+	game, err := s.presets.NewGame(randomID(), req.Difficulty, req.Theme, seed, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	game.Daily = daily
+	game.PlayerID = req.Player
+	if daily != "" {
+		// The attempt counts once the board is dealt, won or not
+		switch err := s.players.StartDaily(req.Player, daily, game.Difficulty); {
+		case err == store.ErrDailyPlayed:
+			http.Error(w, "today's challenge already played", http.StatusConflict)
+			return
+		case err != nil:
+			log.Printf("Dealing daily board to player %s: %v", req.Player, err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.gamesMu.Lock()
+	s.games[game.ID] = game
+	s.gamesMu.Unlock()
+
+	d := s.presets.Difficulties[game.Difficulty]
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"id":         game.ID,
+		"difficulty": game.Difficulty,
+		"rows":       d.Rows,
+		"cols":       d.Cols,
+		"pairs":      d.Pairs,
+		"theme":      game.Theme,
+		"timeLimit":  game.TimeLimit,
+		"daily":      game.Daily,
+	})
+}
+
+// FlipCard handles POST /api/games/{id}/flip with {"index": 3}. It reveals
+// the card and, when the game is won, returns a signed result that can be
+// submitted to the leaderboard.
+func (s *Server) FlipCard(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req struct {
+		Index *int `json:"index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Index == nil {
+		http.Error(w, "index is required", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	s.gamesMu.Lock()
+	game, ok := s.games[id]
+	if !ok {
+		s.gamesMu.Unlock()
+		http.Error(w, "game not found", http.StatusNotFound)
+		return
+	}
+	res, err := game.Flip(*req.Index, now)
+	if err == models.ErrTimeUp || err == nil && res.Finished {
+		// The game is won or lost, so it is discarded
+		delete(s.games, id)
+	}
+	s.gamesMu.Unlock()
+
+	if game.PlayerID != "" && (err == models.ErrTimeUp || err == nil && res.Finished) {
+		s.recordGame(game, err == nil, now)
+	}
+
+	switch {
+	case errors.Is(err, models.ErrGameOver), errors.Is(err, models.ErrTimeUp), errors.Is(err, models.ErrCardFaceUp):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := struct {
+		*models.FlipResult
+		Result string `json:"result,omitempty"`
+	}{FlipResult: res}
+	if res.Finished {
+		resp.Result = s.signResult(models.Result{
+			GameID:     game.ID,
+			Difficulty: game.Difficulty,
+			Time:       res.Time,
+			Moves:      res.Moves,
+			Score:      res.Score,
+			Daily:      game.Daily,
+			PlayerID:   game.PlayerID,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// sweepGames discards games every sweepInterval until stop is closed
+func (s *Server) sweepGames(stop <-chan struct{}) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.expireGames(now)
+		}
+	}
+}
+
+// expireGames discards games that are past their time limit or older than
+// gameTTL. Players' games among them were abandoned, and count as lost.
+func (s *Server) expireGames(now time.Time) {
+	var abandoned []*models.Game
+	s.gamesMu.Lock()
+	for id, g := range s.games {
+		if g.Over() || g.TimeUp(now) || now.Sub(g.CreatedAt) > gameTTL {
+			delete(s.games, id)
+			if g.PlayerID != "" && !g.Over() {
+				abandoned = append(abandoned, g)
+			}
+		}
+	}
+	s.gamesMu.Unlock()
+	for _, g := range abandoned {
+		s.recordGame(g, false, now)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randomSeed() int64 {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return int64(binary.LittleEndian.Uint64(b))
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// createGame starts a game through the API and returns its ID
+func createGame(t *testing.T, s *Server, body string) string {
+	t.Helper()
+	w := do(s, http.MethodPost, "/api/games", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating game: %d %s", w.Code, w.Body)
+	}
+	var game struct{ ID string }
+	decode(t, w, &game)
+	return game.ID
+}
+
+// flip flips a card of game id through the API and returns the status code
+func flip(s *Server, id string, index int) int {
+	return do(s, http.MethodPost, "/api/games/"+id+"/flip", `{"index": `+strconv.Itoa(index)+`}`).Code
+}
+
+func TestExpireGames(t *testing.T) {
+	s := newTestServer(t)
+	fresh := createGame(t, s, `{"difficulty": "easy"}`)
+	started := createGame(t, s, `{"difficulty": "easy"}`)
+	if code := flip(s, started, 0); code != http.StatusOK {
+		t.Fatalf("flip: %d", code)
+	}
+	stale := createGame(t, s, `{"difficulty": "easy"}`)
+	s.games[stale].CreatedAt = time.Now().Add(-gameTTL - time.Minute)
+
+	// The easy board's time limit is 3 minutes
+	s.expireGames(time.Now().Add(4 * time.Minute))
+	for id, want := range map[string]bool{fresh: true, started: false, stale: false} {
+		if _, kept := s.games[id]; kept != want {
+			t.Errorf("game kept is %v, want %v", kept, want)
+		}
+	}
+	if code := flip(s, started, 1); code != http.StatusNotFound {
+		t.Errorf("flip in expired game: %d, want 404", code)
+	}
+}
+
+func TestFlipCardRejected(t *testing.T) {
+	s := newTestServer(t)
+	id := createGame(t, s, `{"difficulty": "easy"}`)
+	cards := s.games[id].Cards
+	// Find two cards that don't match
+	miss := 1
+	for cards[miss] == cards[0] {
+		miss++
+	}
+	for _, i := range []int{0, miss} {
+		if code := flip(s, id, i); code != http.StatusOK {
+			t.Fatalf("flip %d: %d", i, code)
+		}
+	}
+	for _, body := range []string{`{}`, `{"index": 99}`} {
+		if w := do(s, http.MethodPost, "/api/games/"+id+"/flip", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", body, w.Code)
+		}
+	}
+	if faceUp := s.games[id].FaceUp; len(faceUp) != 2 {
+		t.Fatalf("refused flips changed the cards face up to %v", faceUp)
+	}
+	if code := flip(s, "nope", 0); code != http.StatusNotFound {
+		t.Errorf("unknown game: %d, want 404", code)
+	}
+}
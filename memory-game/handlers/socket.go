@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Websocket limits
+const (
+	writeWait      = 10 * time.Second // for a single write
+	pongWait       = 60 * time.Second // for any message, including a pong
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 512
+	sendBufferSize = 16
+)
+
+// socket is a player's websocket connection. gorilla/websocket allows one
+// writer at a time, so messages are queued with send and written by the
+// socket's own writePump goroutine. Messages can be queued before start.
+type socket struct {
+	conn *websocket.Conn
+	out  chan any
+	done chan struct{}
+	once sync.Once
+	// stopped is closed once the writer has closed the connection
+	stopped chan struct{}
+}
+
+func newSocket() *socket {
+	return &socket{
+		out:     make(chan any, sendBufferSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// start sets up conn's keepalive and starts writing queued messages to it
+func (s *socket) start(conn *websocket.Conn) {
+	s.conn = conn
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go s.writePump()
+}
+
+// send queues msg without blocking. A socket too slow to keep up is closed
+// rather than allowed to hold up the match.
+func (s *socket) send(msg any) {
+	select {
+	case <-s.done:
+	case s.out <- msg:
+	default:
+		log.Printf("Socket too slow, closing")
+		s.close()
+	}
+}
+
+// closeAfterSent closes the socket once the messages already queued have
+// been written
+func (s *socket) closeAfterSent() {
+	s.send(nil)
+}
+
+// close stops the writer and closes the connection, if it was started.
+// The reader then sees an error. close may be called more than once.
+func (s *socket) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *socket) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		s.close()
+		s.conn.Close()
+		close(s.stopped)
+	}()
+	for {
+		select {
+		case <-s.done:
+			return
+		case msg := <-s.out:
+			if msg == nil {
+				s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -1,25 +1,141 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"memory-game/handlers"
+	"memory-game/middleware"
+	"memory-game/models"
+	"memory-game/store"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
 func main() {
+	cfg, err := loadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves until the process is interrupted or terminated, then lets
+// requests in flight finish before closing the stores
+func run(cfg *Config) error {
+	presets := models.DefaultPresets()
+	if cfg.PresetsPath != "" {
+		p, err := models.LoadConfig(cfg.PresetsPath)
+		if err != nil {
+			return fmt.Errorf("loading presets: %w", err)
+		}
+		presets = p
+	}
+
+	var scores store.ScoreStore = store.NewMemoryStore()
+	if cfg.ScoresPath != "" {
+		s, err := store.OpenJSONLStore(cfg.ScoresPath)
+		if err != nil {
+			return fmt.Errorf("opening scores: %w", err)
+		}
+		scores = s
+	}
+	defer scores.Close()
+
+	matches := store.NewMatchLog()
+	if cfg.MatchesPath != "" {
+		m, err := store.OpenMatchLog(cfg.MatchesPath)
+		if err != nil {
+			return fmt.Errorf("opening match results: %w", err)
+		}
+		matches = m
+	}
+	defer matches.Close()
+
+	players := store.NewPlayerLog()
+	if cfg.PlayersPath != "" {
+		p, err := store.OpenPlayerLog(cfg.PlayersPath)
+		if err != nil {
+			return fmt.Errorf("opening players: %w", err)
+		}
+		players = p
+	}
+	defer players.Close()
+
+	// Results are signed so that scores can't be forged. Set a secret to
+	// keep results valid across restarts.
+	var key []byte
+	if secret := os.Getenv("MEMORY_GAME_SECRET"); secret != "" {
+		key = []byte(secret)
+	} else {
+		log.Print("MEMORY_GAME_SECRET is not set; using a random key, so results and daily boards change on restart")
+	}
+	api := handlers.NewServer(scores, matches, players, presets, key)
+
+	srv := &http.Server{
+		Addr: cfg.Addr,
+		Handler: middleware.Chain(newRouter(cfg, api),
+			middleware.Logger,
+			middleware.Recover,
+			middleware.CORS(cfg.CORSOrigins),
+			middleware.Gzip,
+		),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on %s", cfg.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	// Shutdown doesn't wait for websockets, so their players are told the
+	// server is going away here
+	api.Close()
+	if err != nil {
+		return fmt.Errorf("shutting down: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newRouter serves the web client and sends API requests to api. Requests
+// with the wrong method get a 405 from the mux.
+func newRouter(cfg *Config, api http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+
 	// Serve static files
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static/"))))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.StaticDir))))
 
 	// API routes
-	http.HandleFunc("/api/game/result", handlers.SubmitResult)
-	http.HandleFunc("/api/leaderboard", handlers.GetLeaderboard)
-	http.HandleFunc("/api/health", handlers.HealthCheck)
+	mux.Handle("/api/", api)
 
 	// Serve index.html for root
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "./static/index.html")
+	index := filepath.Join(cfg.StaticDir, "index.html")
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, index)
 	})
-
-	fmt.Println("Server starting on :8081")
-	http.ListenAndServe(":8081", nil)
-}
\ No newline at end of file
+	return mux
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve sends req through h and returns the response
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// captureLog sends the log to a buffer until the test is done
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+	return &buf
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mark("a"), mark("b"))
+	serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, " "); got != "a b handler" {
+		t.Fatalf("ran %q, want a b handler", got)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	buf := captureLog(t)
+	tests := []struct {
+		handler http.HandlerFunc
+		want    string
+	}{
+		{func(w http.ResponseWriter, r *http.Request) {}, "GET /api/x 200 0B"},
+		{func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) }, "GET /api/x 200 5B"},
+		{func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }, "GET /api/x 404 19B"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		serve(Logger(tt.handler), httptest.NewRequest(http.MethodGet, "/api/x", nil))
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("logged %q, want %q", buf.String(), tt.want)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	buf := captureLog(t)
+	w := serve(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})), httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(buf.String(), "Panic serving GET /: boom") {
+		t.Fatalf("got %d, logged %q; want a 500 and the panic logged", w.Code, buf.String())
+	}
+
+	// A response already started is left as it is
+	w = serve(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	})), httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Fatalf("got %d %q after a late panic", w.Code, w.Body)
+	}
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler passed on", err)
+		}
+	}()
+	serve(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	tests := []struct {
+		desc      string
+		origins   []string
+		method    string
+		origin    string
+		preflight bool
+		allowed   bool
+		code      int
+	}{
+		{"same origin", []string{"https://a.example"}, http.MethodGet, "", false, false, http.StatusOK},
+		{"allowed", []string{"https://a.example"}, http.MethodGet, "https://a.example", false, true, http.StatusOK},
+		{"not allowed", []string{"https://a.example"}, http.MethodGet, "https://b.example", false, false, http.StatusOK},
+		{"any origin", []string{"*"}, http.MethodPost, "https://b.example", false, true, http.StatusOK},
+		{"no origins", nil, http.MethodGet, "https://b.example", false, false, http.StatusOK},
+		{"preflight", []string{"https://a.example"}, http.MethodOptions, "https://a.example", true, true, http.StatusNoContent},
+		{"preflight not allowed", []string{"https://a.example"}, http.MethodOptions, "https://b.example", true, false, http.StatusOK},
+		{"OPTIONS without preflight", []string{"*"}, http.MethodOptions, "https://a.example", false, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/x", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := serve(CORS(tt.origins)(ok), req)
+		allowOrigin := w.Header().Get("Access-Control-Allow-Origin")
+		if w.Code != tt.code || (allowOrigin == tt.origin && tt.origin != "") != tt.allowed {
+			t.Errorf("%s: got %d, Access-Control-Allow-Origin %q", tt.desc, w.Code, allowOrigin)
+		}
+		if methods := w.Header().Get("Access-Control-Allow-Methods"); (methods != "") != (tt.preflight && tt.allowed) {
+			t.Errorf("%s: Access-Control-Allow-Methods %q", tt.desc, methods)
+		}
+	}
+}
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat("memory game ", 100)
+	tests := []struct {
+		desc       string
+		header     map[string]string
+		handler    http.HandlerFunc
+		compressed bool
+		typ        string
+	}{
+		{"accepted", map[string]string{"Accept-Encoding": "gzip, deflate"},
+			func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }, true, "text/plain; charset=utf-8"},
+		{"not accepted", nil,
+			func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }, false, "text/plain; charset=utf-8"},
+		{"content type kept", map[string]string{"Accept-Encoding": "gzip"},
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(body))
+			}, true, "application/json"},
+		{"already encoded", map[string]string{"Accept-Encoding": "gzip"},
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				w.Write([]byte(body))
+			}, false, "text/plain; charset=utf-8"},
+		{"range request", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"},
+			func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }, false, "text/plain; charset=utf-8"},
+		{"websocket upgrade", map[string]string{"Accept-Encoding": "gzip", "Upgrade": "websocket"},
+			func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }, false, "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		w := serve(Gzip(tt.handler), req)
+		if typ := w.Header().Get("Content-Type"); typ != tt.typ {
+			t.Errorf("%s: Content-Type %q, want %q", tt.desc, typ, tt.typ)
+		}
+		if compressed := w.Header().Get("Content-Encoding") == "gzip"; compressed != tt.compressed {
+			t.Errorf("%s: compressed is %v, want %v", tt.desc, compressed, tt.compressed)
+			continue
+		}
+		got := w.Body.String()
+		if tt.compressed {
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.desc, err)
+			}
+			data, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.desc, err)
+			}
+			got = string(data)
+		}
+		if got != body {
+			t.Errorf("%s: body %.20q..., want the handler's", tt.desc, got)
+		}
+	}
+}
+
+func TestGzipNoBody(t *testing.T) {
+	for _, status := range []int{http.StatusNoContent, http.StatusNotModified} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := serve(Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})), req)
+		if w.Code != status || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+			t.Errorf("%d: got %d, Content-Encoding %q, %d bytes", status, w.Code, w.Header().Get("Content-Encoding"), w.Body.Len())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Limits on configured boards
+const (
+	MinBoardSide = 2
+	MaxBoardSide = 10
+)
+
+// Config replaces the built-in difficulties and card themes. Either may be
+// left out to keep the built-in ones. Difficulties don't need Pairs, which
+// is worked out from Rows and Cols.
+type Config struct {
+	Difficulties map[string]Difficulty `json:"difficulties"`
+	Themes       map[string][]string   `json:"themes"`
+}
+
+// LoadConfig reads a Config from the JSON file at path and, if it is valid,
+// returns the presets it makes: the built-in ones with its difficulties and
+// themes in their place
+func LoadConfig(path string) (*Presets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	p := DefaultPresets()
+	difficulties, themes := p.Difficulties, p.Themes
+	if c.Difficulties != nil {
+		difficulties = make(map[string]Difficulty, len(c.Difficulties))
+		for id, d := range c.Difficulties {
+			if d.Name == "" {
+				d.Name = id
+			}
+			d.Pairs = d.Rows * d.Cols / 2
+			difficulties[id] = d
+		}
+	}
+	if c.Themes != nil {
+		themes = c.Themes
+	}
+	if err := checkConfig(difficulties, themes); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Presets{Difficulties: difficulties, Themes: themes}, nil
+}
+
+// checkConfig reports the first problem with a set of difficulties and
+// themes: every board must fit the rules and be dealable from every theme
+func checkConfig(difficulties map[string]Difficulty, themes map[string][]string) error {
+	if len(difficulties) == 0 {
+		return errors.New("no difficulties")
+	}
+	most := 0
+	for id, d := range difficulties {
+		switch {
+		case id == "" || id == "all":
+			return fmt.Errorf("difficulty ID %q is reserved", id)
+		case d.Rows < MinBoardSide || d.Rows > MaxBoardSide || d.Cols < MinBoardSide || d.Cols > MaxBoardSide:
+			return fmt.Errorf("difficulty %s: rows and cols must be from %d to %d", id, MinBoardSide, MaxBoardSide)
+		case d.Rows*d.Cols%2 != 0:
+			return fmt.Errorf("difficulty %s: board must have an even number of cards", id)
+		case d.TimeLimit < 0 || d.TimeLimit > MaxGameTime:
+			return fmt.Errorf("difficulty %s: time limit must be from 0 to %d seconds", id, MaxGameTime)
+		}
+		most = max(most, d.Pairs)
+	}
+	if _, ok := themes[DefaultTheme]; !ok {
+		return fmt.Errorf("themes must include %s", DefaultTheme)
+	}
+	for name, faces := range themes {
+		seen := make(map[string]bool, len(faces))
+		for _, face := range faces {
+			if face == "" || seen[face] {
+				return fmt.Errorf("theme %s: faces must be distinct and not empty", name)
+			}
+			seen[face] = true
+		}
+		if len(faces) < most {
+			return fmt.Errorf("theme %s: needs at least %d faces for the largest board", name, most)
+		}
+	}
+	return nil
+}
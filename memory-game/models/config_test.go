@@ -0,0 +1,126 @@
+package models
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// faces returns n distinct card faces
+func faces(n int) []string {
+	f := make([]string, n)
+	for i := range f {
+		f[i] = string(rune('A' + i))
+	}
+	return f
+}
+
+func TestCheckConfig(t *testing.T) {
+	board := Difficulty{Rows: 2, Cols: 3, Pairs: 3, TimeLimit: 60}
+	tests := []struct {
+		desc  string
+		board Difficulty
+		id    string
+		theme []string
+		err   string // part of the error, or "" for none
+	}{
+		{"valid", board, "small", faces(3), ""},
+		{"no time limit", Difficulty{Rows: 2, Cols: 2, Pairs: 2}, "small", faces(3), ""},
+		{"reserved ID", board, "all", faces(3), "reserved"},
+		{"empty ID", board, "", faces(3), "reserved"},
+		{"too few rows", Difficulty{Rows: 1, Cols: 4, Pairs: 2}, "small", faces(3), "rows and cols"},
+		{"too many cols", Difficulty{Rows: 2, Cols: MaxBoardSide + 2, Pairs: MaxBoardSide + 2}, "small", faces(20), "rows and cols"},
+		{"odd cards", Difficulty{Rows: 3, Cols: 3, Pairs: 4}, "small", faces(4), "even number"},
+		{"negative time limit", Difficulty{Rows: 2, Cols: 2, Pairs: 2, TimeLimit: -1}, "small", faces(3), "time limit"},
+		{"time limit too long", Difficulty{Rows: 2, Cols: 2, Pairs: 2, TimeLimit: MaxGameTime + 1}, "small", faces(3), "time limit"},
+		{"too few faces", board, "small", faces(2), "at least 3 faces"},
+		{"duplicate face", board, "small", []string{"A", "B", "A"}, "distinct"},
+		{"empty face", board, "small", []string{"A", "B", ""}, "distinct"},
+	}
+	for _, tt := range tests {
+		err := checkConfig(map[string]Difficulty{tt.id: tt.board}, map[string][]string{DefaultTheme: faces(3), "test": tt.theme})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.desc, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want one about %q", tt.desc, err, tt.err)
+		}
+	}
+
+	if err := checkConfig(nil, defaultThemes); err == nil {
+		t.Error("no difficulties accepted")
+	}
+	if err := checkConfig(map[string]Difficulty{"small": board}, map[string][]string{"test": faces(3)}); err == nil {
+		t.Errorf("themes without %s accepted", DefaultTheme)
+	}
+}
+
+// writeConfig writes a config file for a test
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "presets.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `{"difficulties": {"tiny": {"rows": 2, "cols": 3, "timeLimit": 30}, "wide": {"name": "Wide", "rows": 2, "cols": 10}}}`)
+	p, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Difficulty{
+		"tiny": {Name: "tiny", Rows: 2, Cols: 3, Pairs: 3, TimeLimit: 30},
+		"wide": {Name: "Wide", Rows: 2, Cols: 10, Pairs: 10},
+	}
+	if !maps.Equal(p.Difficulties, want) {
+		t.Fatalf("got difficulties %v, want %v", p.Difficulties, want)
+	}
+	if len(p.Themes) != len(defaultThemes) {
+		t.Error("themes replaced by a config without any")
+	}
+	if _, err := p.NewGame("g", "tiny", "animals", 1, time.Now()); err != nil {
+		t.Errorf("dealing a configured board: %v", err)
+	}
+	if _, ok := DefaultPresets().Difficulties["tiny"]; ok {
+		t.Error("loading a config changed the built-in presets")
+	}
+}
+
+func TestLoadConfigThemes(t *testing.T) {
+	// The built-in hard board needs 32 faces
+	path := writeConfig(t, `{"themes": {"classic": ["A", "B", "C", "D", "E", "F", "G", "H"]}}`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "at least 32 faces") {
+		t.Fatalf("got %v, want an error about the hard board", err)
+	}
+
+	path = writeConfig(t, `{"difficulties": {"easy": {"rows": 4, "cols": 4}}, "themes": {"classic": ["A", "B", "C", "D", "E", "F", "G", "H"]}}`)
+	p, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Themes["animals"]; ok || len(p.Themes) != 1 {
+		t.Fatalf("got themes %v, want only classic", p.Themes)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"difficulties": {"easy": {"rows": 4, "cols": 4}`,
+		`{"difficulties": {"easy": {"rows": "four"}}}`,
+		`{"difficulties": {}}`,
+		`{"difficulties": {"odd": {"rows": 3, "cols": 3}}}`,
+	} {
+		if p, err := LoadConfig(writeConfig(t, data)); err == nil || p != nil {
+			t.Errorf("%s: loaded %v", data, p)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file loaded")
+	}
+}
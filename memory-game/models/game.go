@@ -0,0 +1,215 @@
+package models
+
+import (
+	"errors"
+	"maps"
+	"math/rand"
+	"time"
+)
+
+// Difficulty describes the board for one difficulty level
+type Difficulty struct {
+	Name      string `json:"name"` // shown to players
+	Rows      int    `json:"rows"`
+	Cols      int    `json:"cols"`
+	Pairs     int    `json:"pairs"`
+	TimeLimit int    `json:"timeLimit"` // seconds from the first flip, or 0 for none
+}
+
+// Presets are what games are dealt from: the levels a game can be played
+// at, by ID, and the sets of card faces a board can be dealt from, by name,
+// each with enough faces for the largest board
+type Presets struct {
+	Difficulties map[string]Difficulty
+	Themes       map[string][]string
+}
+
+// DefaultPresets returns the built-in difficulties and themes. LoadConfig
+// can replace them.
+func DefaultPresets() *Presets {
+	return &Presets{
+		Difficulties: maps.Clone(defaultDifficulties),
+		Themes:       maps.Clone(defaultThemes),
+	}
+}
+
+// defaultDifficulties are the built-in difficulties
+var defaultDifficulties = map[string]Difficulty{
+	"easy":   {Name: "Easy (4x4)", Rows: 4, Cols: 4, Pairs: 8, TimeLimit: 180},
+	"medium": {Name: "Medium (6x6)", Rows: 6, Cols: 6, Pairs: 18, TimeLimit: 480},
+	"hard":   {Name: "Hard (8x8)", Rows: 8, Cols: 8, Pairs: 32, TimeLimit: 900},
+}
+
+// defaultThemes are the built-in themes
+var defaultThemes = map[string][]string{
+	"classic": {"🎮", "🎵", "🎨", "🚀", "🐶", "🍎", "⚽", "🌟", "🍕", "🎂", "🌈", "🐱", "🎸", "🚲", "🍦", "🦄", "🍔", "🎃", "🌺", "🐸", "🍇", "⚡", "🔥", "🌙", "💎", "🎈", "🔔", "🎁", "🎊", "🍭", "🍪", "🥤"},
+	"animals": {"🐶", "🐱", "🐭", "🐹", "🐰", "🦊", "🐻", "🐼", "🐨", "🐯", "🦁", "🐮", "🐷", "🐸", "🐵", "🐔", "🐧", "🐦", "🐤", "🦆", "🦅", "🦉", "🦇", "🐺", "🐗", "🐴", "🦄", "🐝", "🐛", "🦋", "🐌", "🐞"},
+	"food":    {"🍏", "🍎", "🍐", "🍊", "🍋", "🍌", "🍉", "🍇", "🍓", "🍈", "🍒", "🍑", "🥭", "🍍", "🥥", "🥝", "🍅", "🍆", "🥑", "🥦", "🌽", "🥕", "🥐", "🍞", "🧀", "🥚", "🥞", "🥓", "🍔", "🍟", "🍕", "🌭"},
+}
+
+// DefaultTheme is dealt when a game doesn't ask for a theme
+const DefaultTheme = "classic"
+
+// Scoring rules
+const (
+	MatchPoints   = 10  // for each pair found
+	MissPenalty   = 2   // for each pair that doesn't match
+	SpeedBonusMax = 100 // less one point per second taken
+)
+
+// Errors returned by Game.Flip
+var (
+	ErrGameOver   = errors.New("game is over")
+	ErrTimeUp     = errors.New("time is up")
+	ErrBadCard    = errors.New("no such card")
+	ErrCardFaceUp = errors.New("card is already face up")
+)
+
+// Game is a game in progress, kept on the server so that the board, moves
+// and time can't be forged. Card faces are only revealed by Flip.
+type Game struct {
+	ID         string    `json:"id"`
+	Difficulty string    `json:"difficulty"`
+	Daily      string    `json:"daily,omitempty"` // date of the daily challenge the board is for
+	Theme      string    `json:"theme"`
+	PlayerID   string    `json:"playerId,omitempty"` // profile the game is linked to, if any
+	TimeLimit  int       `json:"timeLimit"`          // seconds from the first flip, or 0 for none
+	Seed       int64     `json:"-"`
+	Cards      []string  `json:"-"`
+	Matched    []bool    `json:"-"`
+	FaceUp     []int     `json:"-"` // unmatched cards turned over this move
+	Moves      int       `json:"moves"`
+	Pairs      int       `json:"pairs"` // pairs found so far
+	Score      int       `json:"score"`
+	CreatedAt  time.Time `json:"-"`
+	StartedAt  time.Time `json:"-"` // at the first flip
+	FinishedAt time.Time `json:"-"`
+}
+
+// NewGame deals a board for difficulty from theme's faces, shuffled from
+// seed. An empty theme means DefaultTheme.
+func (p *Presets) NewGame(id, difficulty, theme string, seed int64, now time.Time) (*Game, error) {
+	d, ok := p.Difficulties[difficulty]
+	if !ok {
+		return nil, errors.New("unknown difficulty")
+	}
+	if theme == "" {
+		theme = DefaultTheme
+	}
+	faces, ok := p.Themes[theme]
+	if !ok {
+		return nil, errors.New("unknown theme")
+	}
+	cards := make([]string, 0, 2*d.Pairs)
+	for _, s := range faces[:d.Pairs] {
+		cards = append(cards, s, s)
+	}
+	rand.New(rand.NewSource(seed)).Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+	return &Game{
+		ID:         id,
+		Difficulty: difficulty,
+		Theme:      theme,
+		TimeLimit:  d.TimeLimit,
+		Seed:       seed,
+		Cards:      cards,
+		Matched:    make([]bool, len(cards)),
+		CreatedAt:  now,
+	}, nil
+}
+
+// FlipResult is what a flip reveals
+type FlipResult struct {
+	Index  int    `json:"index"`
+	Symbol string `json:"symbol"`
+	// Hidden lists cards turned back face down before this flip, because
+	// they didn't match
+	Hidden []int `json:"hidden,omitempty"`
+	// Pair is set on the second flip of a move, to the two cards flipped
+	Pair    []int `json:"pair,omitempty"`
+	Matched bool  `json:"matched"`
+	Moves   int   `json:"moves"`
+	Score   int   `json:"score"`
+	// Finished is set once every pair has been found; Time is then the
+	// game's length in seconds
+	Finished bool `json:"finished"`
+	Time     int  `json:"time,omitempty"`
+}
+
+// Over reports whether every pair has been found
+func (g *Game) Over() bool {
+	return !g.FinishedAt.IsZero()
+}
+
+// Elapsed returns the time taken, in whole seconds, from the first flip to
+// the last one or to now
+func (g *Game) Elapsed(now time.Time) int {
+	if g.StartedAt.IsZero() {
+		return 0
+	}
+	if g.Over() {
+		now = g.FinishedAt
+	}
+	return int(now.Sub(g.StartedAt) / time.Second)
+}
+
+// TimeUp reports whether the game's time limit has passed
+func (g *Game) TimeUp(now time.Time) bool {
+	return g.TimeLimit > 0 && !g.Over() && !g.StartedAt.IsZero() &&
+		now.Sub(g.StartedAt) > time.Duration(g.TimeLimit)*time.Second
+}
+
+// Flip turns over the card at index. Two cards make a move; a pair that
+// doesn't match stays face up until the next flip. Once the time limit has
+// passed, every flip returns ErrTimeUp. A flip that returns an error
+// leaves the game as it was.
+func (g *Game) Flip(index int, now time.Time) (*FlipResult, error) {
+	if g.Over() {
+		return nil, ErrGameOver
+	}
+	if g.TimeUp(now) {
+		return nil, ErrTimeUp
+	}
+	if index < 0 || index >= len(g.Cards) {
+		return nil, ErrBadCard
+	}
+	// A pair that didn't match is turned back over by this flip, so either
+	// of its cards may be flipped again
+	if g.Matched[index] || (len(g.FaceUp) == 1 && g.FaceUp[0] == index) {
+		return nil, ErrCardFaceUp
+	}
+
+	res := &FlipResult{Index: index, Symbol: g.Cards[index]}
+	if len(g.FaceUp) == 2 {
+		res.Hidden = g.FaceUp
+		g.FaceUp = nil
+	}
+	if g.StartedAt.IsZero() {
+		g.StartedAt = now
+	}
+
+	g.FaceUp = append(g.FaceUp, index)
+	if len(g.FaceUp) == 2 {
+		g.Moves++
+		first, second := g.FaceUp[0], g.FaceUp[1]
+		res.Pair = []int{first, second}
+		if g.Cards[first] == g.Cards[second] {
+			res.Matched = true
+			g.Matched[first], g.Matched[second] = true, true
+			g.FaceUp = nil
+			g.Pairs++
+			g.Score += MatchPoints
+			if g.Pairs == len(g.Cards)/2 {
+				g.FinishedAt = now
+				g.Score += max(0, SpeedBonusMax-g.Elapsed(now))
+				res.Finished = true
+				res.Time = g.Elapsed(now)
+			}
+		} else {
+			g.Score = max(0, g.Score-MissPenalty)
+		}
+	}
+	res.Moves, res.Score = g.Moves, g.Score
+	return res, nil
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testGame returns a game dealt as cards, which must hold each face twice
+func testGame(timeLimit int, cards ...string) *Game {
+	return &Game{
+		ID:         "g",
+		Difficulty: "test",
+		TimeLimit:  timeLimit,
+		Cards:      cards,
+		Matched:    make([]bool, len(cards)),
+	}
+}
+
+func TestNewGame(t *testing.T) {
+	p := DefaultPresets()
+	now := time.Now()
+	g, err := p.NewGame("g", "easy", "", 42, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Theme != DefaultTheme || g.TimeLimit != 180 || len(g.Cards) != 16 {
+		t.Fatalf("got theme %q, time limit %d, %d cards", g.Theme, g.TimeLimit, len(g.Cards))
+	}
+	counts := make(map[string]int)
+	for _, c := range g.Cards {
+		counts[c]++
+	}
+	for face, n := range counts {
+		if n != 2 {
+			t.Errorf("%s dealt %d times, want 2", face, n)
+		}
+	}
+	again, _ := p.NewGame("g2", "easy", "", 42, now)
+	if !slices.Equal(g.Cards, again.Cards) {
+		t.Error("the same seed dealt different boards")
+	}
+
+	for _, tt := range []struct{ difficulty, theme string }{
+		{"impossible", ""},
+		{"easy", "plants"},
+	} {
+		if _, err := p.NewGame("g", tt.difficulty, tt.theme, 1, now); err == nil {
+			t.Errorf("NewGame(%q, %q) succeeded", tt.difficulty, tt.theme)
+		}
+	}
+}
+
+func TestFlip(t *testing.T) {
+	type flip struct {
+		index   int
+		err     error
+		hidden  []int
+		pair    []int
+		matched bool
+		moves   int
+		score   int
+	}
+	tests := []struct {
+		desc  string
+		flips []flip
+	}{
+		{"match", []flip{
+			{index: 0},
+			{index: 2, pair: []int{0, 2}, matched: true, moves: 1, score: 10},
+		}},
+		{"miss, then the pair is hidden by the next flip", []flip{
+			{index: 0},
+			{index: 1, pair: []int{0, 1}, moves: 1},
+			{index: 3, hidden: []int{0, 1}, moves: 1},
+		}},
+		{"miss penalty after a match", []flip{
+			{index: 0},
+			{index: 2, pair: []int{0, 2}, matched: true, moves: 1, score: 10},
+			{index: 1, moves: 1, score: 10},
+			{index: 4, pair: []int{1, 4}, moves: 2, score: 8},
+		}},
+		{"score doesn't go below zero", []flip{
+			{index: 0},
+			{index: 1, pair: []int{0, 1}, moves: 1},
+		}},
+		{"flipping the face up card again", []flip{
+			{index: 0},
+			{index: 0, err: ErrCardFaceUp},
+			{index: 2, pair: []int{0, 2}, matched: true, moves: 1, score: 10},
+		}},
+		{"flipping a missed card starts the next move", []flip{
+			{index: 0},
+			{index: 1, pair: []int{0, 1}, moves: 1},
+			{index: 1, hidden: []int{0, 1}, moves: 1},
+			{index: 3, pair: []int{1, 3}, matched: true, moves: 2, score: 10},
+		}},
+		{"flipping a matched card", []flip{
+			{index: 0},
+			{index: 2, pair: []int{0, 2}, matched: true, moves: 1, score: 10},
+			{index: 2, err: ErrCardFaceUp},
+		}},
+		{"cards off the board", []flip{
+			{index: -1, err: ErrBadCard},
+			{index: 6, err: ErrBadCard},
+		}},
+	}
+	now := time.Now()
+	for _, tt := range tests {
+		g := testGame(0, "a", "b", "a", "b", "c", "c")
+		for i, f := range tt.flips {
+			res, err := g.Flip(f.index, now)
+			if err != f.err {
+				t.Errorf("%s: flip %d: error %v, want %v", tt.desc, i, err, f.err)
+				break
+			}
+			if err != nil {
+				continue
+			}
+			want := &FlipResult{
+				Index:   f.index,
+				Symbol:  g.Cards[f.index],
+				Hidden:  f.hidden,
+				Pair:    f.pair,
+				Matched: f.matched,
+				Moves:   f.moves,
+				Score:   f.score,
+			}
+			if !reflect.DeepEqual(res, want) {
+				t.Errorf("%s: flip %d: got %+v, want %+v", tt.desc, i, res, want)
+				break
+			}
+		}
+	}
+}
+
+// TestFlipRejected checks that a refused flip doesn't change the game, so
+// the cards left face up are still the ones the player saw
+func TestFlipRejected(t *testing.T) {
+	now := time.Now()
+	g := testGame(0, "a", "b", "a", "b", "c", "c")
+	for _, i := range []int{0, 2, 1, 4} {
+		if _, err := g.Flip(i, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1 and 4 missed and are still face up
+	before := *g
+	for _, i := range []int{0, 2, 9} {
+		if _, err := g.Flip(i, now); err == nil {
+			t.Fatalf("flipping %d succeeded", i)
+		}
+	}
+	if !slices.Equal(g.FaceUp, []int{1, 4}) || g.Moves != before.Moves || g.Score != before.Score {
+		t.Fatalf("after refused flips, face up %v, moves %d, score %d; want [1 4], %d, %d",
+			g.FaceUp, g.Moves, g.Score, before.Moves, before.Score)
+	}
+	res, err := g.Flip(3, now)
+	if err != nil || !slices.Equal(res.Hidden, []int{1, 4}) {
+		t.Fatalf("next flip: %+v, %v; want 1 and 4 hidden", res, err)
+	}
+}
+
+func TestFlipFinish(t *testing.T) {
+	start := time.Now()
+	g := testGame(0, "a", "a", "b", "b")
+	g.Flip(0, start)
+	g.Flip(1, start.Add(10*time.Second))
+	g.Flip(2, start.Add(20*time.Second))
+	res, err := g.Flip(3, start.Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two pairs and 70 seconds of speed bonus
+	if !res.Finished || res.Time != 30 || res.Score != 2*MatchPoints+SpeedBonusMax-30 || !g.Over() {
+		t.Fatalf("got %+v, want finished in 30s with score %d", res, 2*MatchPoints+SpeedBonusMax-30)
+	}
+	if g.Elapsed(start.Add(time.Hour)) != 30 {
+		t.Errorf("elapsed time kept counting after the game was won")
+	}
+	if _, err := g.Flip(0, start.Add(31*time.Second)); err != ErrGameOver {
+		t.Fatalf("flip after the end: %v, want %v", err, ErrGameOver)
+	}
+}
+
+func TestFlipTimeUp(t *testing.T) {
+	start := time.Now()
+	g := testGame(60, "a", "a", "b", "b")
+	if g.TimeUp(start.Add(time.Hour)) {
+		t.Fatal("time up before the first flip")
+	}
+	if _, err := g.Flip(0, start); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Flip(1, start.Add(60*time.Second)); err != nil {
+		t.Fatalf("flip on the limit: %v", err)
+	}
+	late := start.Add(61 * time.Second)
+	if !g.TimeUp(late) {
+		t.Fatal("time not up after the limit")
+	}
+	if _, err := g.Flip(2, late); err != ErrTimeUp {
+		t.Fatalf("flip after the limit: %v, want %v", err, ErrTimeUp)
+	}
+
+	untimed := testGame(0, "a", "a")
+	untimed.Flip(0, start)
+	if untimed.TimeUp(start.Add(24 * time.Hour)) {
+		t.Fatal("game without a time limit ran out of time")
+	}
+}
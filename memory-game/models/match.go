@@ -0,0 +1,255 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Players in a multiplayer match
+const (
+	MinPlayers = 2
+	MaxPlayers = 4
+)
+
+// Multiplayer time limits
+const (
+	TurnLimit      = 30 * time.Second // to flip a card before the turn passes
+	MaxMissedTurns = 3                // turns in a row a player can miss before forfeiting
+	MaxMatchTime   = time.Hour        // for boards without a time limit
+)
+
+// Match states
+const (
+	MatchWaiting  = "waiting" // for players to join
+	MatchPlaying  = "playing"
+	MatchFinished = "finished"
+)
+
+// Errors returned by Match methods
+var (
+	ErrMatchFull    = errors.New("match is full")
+	ErrNameTaken    = errors.New("name is taken in this match")
+	ErrNotStarted   = errors.New("match has not started")
+	ErrNotYourTurn  = errors.New("not your turn")
+	ErrBadPlayerNum = errors.New("players must be from 2 to 4")
+)
+
+// MatchPlayer is a player's seat in a match
+type MatchPlayer struct {
+	Name  string `json:"name"`
+	Pairs int    `json:"pairs"`          // pairs found
+	Left  bool   `json:"left,omitempty"` // left before the match ended
+
+	missed int // turns missed in a row
+}
+
+// Match is a multiplayer game: players take turns flipping cards on one
+// shared board, and whoever finds a pair goes again. The player with the
+// most pairs at the end wins. The board's clock starts with the match, and
+// each flip must come within TurnLimit of the last; see Timeout.
+type Match struct {
+	ID          string         `json:"id"`
+	Size        int            `json:"size"` // players needed to start
+	Players     []*MatchPlayer `json:"players"`
+	Turn        int            `json:"turn"` // seat of the player to flip
+	Status      string         `json:"status"`
+	Game        *Game          `json:"-"`
+	TurnStarted time.Time      `json:"-"` // when the player in turn last flipped, or got the turn
+	Finished    time.Time      `json:"-"`
+}
+
+// MatchResult is the outcome of a finished match, as recorded
+type MatchResult struct {
+	MatchID    string        `json:"matchId"`
+	Difficulty string        `json:"difficulty"`
+	Players    []MatchPlayer `json:"players"`
+	Winners    []string      `json:"winners"` // more than one on a tie
+	Moves      int           `json:"moves"`
+	Time       int           `json:"time"` // in seconds
+	Date       time.Time     `json:"date"`
+}
+
+// NewMatch deals a board for size players at difficulty from theme's faces,
+// shuffled from seed
+func (p *Presets) NewMatch(id, difficulty, theme string, size int, seed int64, now time.Time) (*Match, error) {
+	if size < MinPlayers || size > MaxPlayers {
+		return nil, ErrBadPlayerNum
+	}
+	game, err := p.NewGame(id, difficulty, theme, seed, now)
+	if err != nil {
+		return nil, err
+	}
+	return &Match{ID: id, Size: size, Status: MatchWaiting, Game: game}, nil
+}
+
+// Join seats a player and returns their seat. The match starts once every
+// seat is taken.
+func (m *Match) Join(name string, now time.Time) (int, error) {
+	if m.Status != MatchWaiting {
+		return 0, ErrMatchFull
+	}
+	for _, p := range m.Players {
+		if p.Name == name {
+			return 0, ErrNameTaken
+		}
+	}
+	m.Players = append(m.Players, &MatchPlayer{Name: name})
+	if len(m.Players) == m.Size {
+		m.Status = MatchPlaying
+		m.Game.StartedAt = now
+		m.TurnStarted = now
+	}
+	return len(m.Players) - 1, nil
+}
+
+// Flip turns over a card for the player in seat. A pair keeps the turn;
+// a miss passes it to the next player. If the match's time is up, it ends
+// and Flip returns ErrTimeUp, whoever's turn it is.
+func (m *Match) Flip(seat, index int, now time.Time) (*FlipResult, error) {
+	switch {
+	case m.Status == MatchWaiting:
+		return nil, ErrNotStarted
+	case m.Status == MatchFinished:
+		return nil, ErrGameOver
+	case m.TimeUp(now):
+		m.finish(now)
+		return nil, ErrTimeUp
+	case seat != m.Turn:
+		return nil, ErrNotYourTurn
+	}
+	res, err := m.Game.Flip(index, now)
+	if err != nil {
+		return nil, err
+	}
+	m.Players[seat].missed = 0
+	m.TurnStarted = now
+	switch {
+	case res.Matched:
+		m.Players[seat].Pairs++
+		if res.Finished {
+			m.finish(now)
+		}
+	case res.Pair != nil:
+		m.passTurn(now)
+	}
+	return res, nil
+}
+
+// TimeUp reports whether the match has run out of time: the board's time
+// limit from the start of the match, or MaxMatchTime if it has none
+func (m *Match) TimeUp(now time.Time) bool {
+	if m.Status != MatchPlaying {
+		return false
+	}
+	if m.Game.TimeLimit > 0 {
+		return m.Game.TimeUp(now)
+	}
+	return now.Sub(m.Game.StartedAt) > MaxMatchTime
+}
+
+// Timeout ends the match if its time is up, or else passes the turn of a
+// player who hasn't flipped a card within TurnLimit, turning back any card
+// they left face up. A player who misses MaxMissedTurns turns in a row
+// forfeits, as if they had left. Timeout returns the seat of the player who
+// timed out, or -1, and the cards turned back.
+func (m *Match) Timeout(now time.Time) (seat int, hidden []int) {
+	if m.Status != MatchPlaying {
+		return -1, nil
+	}
+	if m.TimeUp(now) {
+		m.finish(now)
+		return -1, nil
+	}
+	if now.Sub(m.TurnStarted) <= TurnLimit {
+		return -1, nil
+	}
+	seat = m.Turn
+	m.Players[seat].missed++
+	if m.Players[seat].missed >= MaxMissedTurns {
+		return seat, m.Leave(seat, now)
+	}
+	if len(m.Game.FaceUp) == 1 {
+		hidden, m.Game.FaceUp = m.Game.FaceUp, nil
+	}
+	m.passTurn(now)
+	return seat, hidden
+}
+
+// Leave gives up the seat of the player in seat. A player who leaves before
+// the match starts frees their seat, so seats after it move down one.
+// Once it has started, their turns are skipped, and the match ends if fewer
+// than two players are left. Leave returns any card the player had turned
+// over, which is turned back face down.
+func (m *Match) Leave(seat int, now time.Time) (hidden []int) {
+	switch m.Status {
+	case MatchWaiting:
+		m.Players = append(m.Players[:seat], m.Players[seat+1:]...)
+		return nil
+	case MatchFinished:
+		return nil
+	}
+	m.Players[seat].Left = true
+	if m.active() < MinPlayers {
+		m.finish(now)
+		return nil
+	}
+	if seat == m.Turn {
+		if len(m.Game.FaceUp) == 1 {
+			hidden, m.Game.FaceUp = m.Game.FaceUp, nil
+		}
+		m.passTurn(now)
+	}
+	return hidden
+}
+
+// Result returns the outcome of a finished match
+func (m *Match) Result() MatchResult {
+	r := MatchResult{
+		MatchID:    m.ID,
+		Difficulty: m.Game.Difficulty,
+		Moves:      m.Game.Moves,
+		Time:       m.Game.Elapsed(m.Finished),
+		Date:       m.Finished.UTC(),
+	}
+	best := -1
+	for _, p := range m.Players {
+		r.Players = append(r.Players, *p)
+		if p.Left {
+			continue
+		}
+		switch {
+		case p.Pairs > best:
+			best, r.Winners = p.Pairs, []string{p.Name}
+		case p.Pairs == best:
+			r.Winners = append(r.Winners, p.Name)
+		}
+	}
+	return r
+}
+
+// active returns how many players haven't left
+func (m *Match) active() int {
+	n := 0
+	for _, p := range m.Players {
+		if !p.Left {
+			n++
+		}
+	}
+	return n
+}
+
+// passTurn moves the turn to the next player who hasn't left
+func (m *Match) passTurn(now time.Time) {
+	m.TurnStarted = now
+	for {
+		m.Turn = (m.Turn + 1) % len(m.Players)
+		if !m.Players[m.Turn].Left {
+			return
+		}
+	}
+}
+
+func (m *Match) finish(now time.Time) {
+	m.Status = MatchFinished
+	m.Finished = now
+}
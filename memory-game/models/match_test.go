@@ -0,0 +1,221 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// testMatch returns a match for len(names) players on a board dealt as
+// cards, started at start
+func testMatch(t *testing.T, start time.Time, timeLimit int, names []string, cards ...string) *Match {
+	t.Helper()
+	m := &Match{ID: "m", Size: len(names), Status: MatchWaiting, Game: testGame(timeLimit, cards...)}
+	for _, name := range names {
+		if _, err := m.Join(name, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestMatchJoin(t *testing.T) {
+	p := DefaultPresets()
+	now := time.Now()
+	if _, err := p.NewMatch("m", "easy", "", 5, 1, now); err != ErrBadPlayerNum {
+		t.Fatalf("five players: %v, want %v", err, ErrBadPlayerNum)
+	}
+	m, err := p.NewMatch("m", "easy", "", 3, 1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name   string
+		seat   int
+		err    error
+		status string
+	}{
+		{"alice", 0, nil, MatchWaiting},
+		{"alice", 0, ErrNameTaken, MatchWaiting},
+		{"bob", 1, nil, MatchWaiting},
+		{"carol", 2, nil, MatchPlaying},
+		{"dave", 0, ErrMatchFull, MatchPlaying},
+	}
+	for _, s := range steps {
+		seat, err := m.Join(s.name, now)
+		if seat != s.seat || err != s.err || m.Status != s.status {
+			t.Fatalf("%s joining: seat %d, %v, %s; want %d, %v, %s", s.name, seat, err, m.Status, s.seat, s.err, s.status)
+		}
+	}
+	if !m.Game.StartedAt.Equal(now) || !m.TurnStarted.Equal(now) {
+		t.Fatal("the clock didn't start with the match")
+	}
+}
+
+func TestMatchFlip(t *testing.T) {
+	start := time.Now()
+	m := &Match{ID: "m", Size: 2, Status: MatchWaiting, Game: testGame(0, "a", "b", "a", "b", "c", "c")}
+	m.Join("alice", start)
+	if _, err := m.Flip(0, 0, start); err != ErrNotStarted {
+		t.Fatalf("flip before the start: %v, want %v", err, ErrNotStarted)
+	}
+	m.Join("bob", start)
+
+	steps := []struct {
+		seat, index int
+		err         error
+		turn        int // after the flip
+		pairs       [2]int
+	}{
+		{1, 0, ErrNotYourTurn, 0, [2]int{0, 0}},
+		{0, 0, nil, 0, [2]int{0, 0}},
+		{0, 2, nil, 0, [2]int{1, 0}}, // a pair keeps the turn
+		{0, 1, nil, 0, [2]int{1, 0}},
+		{0, 4, nil, 1, [2]int{1, 0}}, // a miss passes it
+		{0, 5, ErrNotYourTurn, 1, [2]int{1, 0}},
+		{1, 2, ErrCardFaceUp, 1, [2]int{1, 0}},
+		{1, 4, nil, 1, [2]int{1, 0}},
+		{1, 5, nil, 1, [2]int{1, 1}},
+		{1, 1, nil, 1, [2]int{1, 1}},
+		{1, 3, nil, 1, [2]int{1, 2}},
+		{1, 0, ErrGameOver, 1, [2]int{1, 2}},
+	}
+	for i, s := range steps {
+		now := start.Add(time.Duration(i) * time.Second)
+		_, err := m.Flip(s.seat, s.index, now)
+		pairs := [2]int{m.Players[0].Pairs, m.Players[1].Pairs}
+		if err != s.err || m.Turn != s.turn || pairs != s.pairs {
+			t.Fatalf("step %d: %v, turn %d, pairs %v; want %v, %d, %v", i, err, m.Turn, pairs, s.err, s.turn, s.pairs)
+		}
+		if err == nil && !m.TurnStarted.Equal(now) {
+			t.Fatalf("step %d: the turn clock wasn't reset", i)
+		}
+	}
+	if m.Status != MatchFinished {
+		t.Fatalf("status %s after the last pair", m.Status)
+	}
+	r := m.Result()
+	if !slices.Equal(r.Winners, []string{"bob"}) || r.Moves != 4 || r.Time != 10 {
+		t.Fatalf("result %+v, want bob winning in 4 moves and 10s", r)
+	}
+}
+
+func TestMatchTimeUp(t *testing.T) {
+	start := time.Now()
+	m := testMatch(t, start, 60, []string{"alice", "bob"}, "a", "a", "b", "b")
+	if m.TimeUp(start.Add(60 * time.Second)) {
+		t.Fatal("time up on the limit")
+	}
+	// The board's clock runs from the start of the match, not the first flip
+	late := start.Add(61 * time.Second)
+	if _, err := m.Flip(1, 0, late); err != ErrTimeUp {
+		t.Fatalf("flip after the limit by the player not in turn: %v, want %v", err, ErrTimeUp)
+	}
+	if m.Status != MatchFinished || !m.Finished.Equal(late) {
+		t.Fatalf("status %s, finished %v after time ran out", m.Status, m.Finished)
+	}
+
+	untimed := testMatch(t, start, 0, []string{"alice", "bob"}, "a", "a")
+	if seat, _ := untimed.Timeout(start.Add(MaxMatchTime)); seat != 0 || untimed.Status != MatchPlaying {
+		t.Fatalf("untimed match at MaxMatchTime: seat %d timed out, status %s", seat, untimed.Status)
+	}
+	if untimed.Timeout(start.Add(MaxMatchTime + time.Second)); untimed.Status != MatchFinished {
+		t.Fatal("untimed match didn't end after MaxMatchTime")
+	}
+}
+
+func TestMatchTimeout(t *testing.T) {
+	start := time.Now()
+	m := testMatch(t, start, 0, []string{"alice", "bob", "carol"}, "a", "b", "a", "b", "c", "c")
+	if seat, _ := m.Timeout(start.Add(TurnLimit)); seat != -1 {
+		t.Fatalf("timed out on the limit: seat %d", seat)
+	}
+	m.Flip(0, 0, start.Add(time.Second))
+	// The turn clock restarts with each flip
+	if seat, _ := m.Timeout(start.Add(TurnLimit + time.Second)); seat != -1 {
+		t.Fatalf("timed out %v after the last flip", TurnLimit)
+	}
+
+	now := start.Add(TurnLimit + 2*time.Second)
+	seat, hidden := m.Timeout(now)
+	if seat != 0 || !slices.Equal(hidden, []int{0}) || m.Turn != 1 || !m.TurnStarted.Equal(now) {
+		t.Fatalf("timeout: seat %d, hidden %v, turn %d; want 0, [0], 1", seat, hidden, m.Turn)
+	}
+	if len(m.Game.FaceUp) != 0 {
+		t.Fatalf("cards still face up: %v", m.Game.FaceUp)
+	}
+
+	// bob keeps playing; carol misses every turn and forfeits on the third
+	for i := range MaxMissedTurns {
+		m.Flip(1, 1, now)
+		m.Flip(1, 4, now)
+		if m.Turn != 2 {
+			t.Fatalf("round %d: turn %d after bob's miss", i, m.Turn)
+		}
+		now = now.Add(TurnLimit + time.Second)
+		if seat, _ := m.Timeout(now); seat != 2 {
+			t.Fatalf("round %d: seat %d timed out, want 2", i, seat)
+		}
+		if i < MaxMissedTurns-1 {
+			// alice plays, which clears her earlier miss
+			m.Flip(0, 1, now)
+			m.Flip(0, 4, now)
+		}
+	}
+	if !m.Players[2].Left || m.Players[0].missed != 0 || m.Turn != 0 || m.Status != MatchPlaying {
+		t.Fatalf("after carol's third miss: players %+v %+v %+v, turn %d, status %s",
+			*m.Players[0], *m.Players[1], *m.Players[2], m.Turn, m.Status)
+	}
+}
+
+func TestMatchLeave(t *testing.T) {
+	start := time.Now()
+	waiting := &Match{ID: "m", Size: 3, Status: MatchWaiting, Game: testGame(0, "a", "a")}
+	waiting.Join("alice", start)
+	waiting.Join("bob", start)
+	waiting.Leave(0, start)
+	if len(waiting.Players) != 1 || waiting.Players[0].Name != "bob" {
+		t.Fatalf("leaving before the start left %+v", waiting.Players[0])
+	}
+
+	m := testMatch(t, start, 0, []string{"alice", "bob", "carol"}, "a", "b", "a", "b")
+	m.Flip(0, 0, start)
+	if hidden := m.Leave(0, start); !slices.Equal(hidden, []int{0}) || m.Turn != 1 {
+		t.Fatalf("leaving in turn: hidden %v, turn %d; want [0], 1", hidden, m.Turn)
+	}
+	m.Flip(1, 0, start)
+	m.Flip(1, 2, start)
+	m.Leave(2, start.Add(time.Second))
+	if m.Status != MatchFinished {
+		t.Fatal("match went on with one player")
+	}
+	if r := m.Result(); !slices.Equal(r.Winners, []string{"bob"}) || r.Time != 1 {
+		t.Fatalf("result %+v, want bob winning after 1s", r)
+	}
+
+	// Players who left can't win, even with more pairs
+	left := testMatch(t, start, 0, []string{"alice", "bob", "carol"}, "a", "a", "b", "b")
+	left.Flip(0, 0, start)
+	left.Flip(0, 1, start)
+	left.Leave(0, start)
+	left.Leave(1, start)
+	if r := left.Result(); !slices.Equal(r.Winners, []string{"carol"}) {
+		t.Fatalf("winners %v, want the player still in", r.Winners)
+	}
+}
+
+func TestMatchTie(t *testing.T) {
+	start := time.Now()
+	m := testMatch(t, start, 60, []string{"alice", "bob", "carol"}, "a", "a", "b", "b")
+	m.Flip(0, 0, start)
+	m.Flip(0, 1, start)
+	m.Flip(0, 2, start)
+	m.Flip(0, 0, start) // already matched
+	m.Timeout(start.Add(TurnLimit + time.Second))
+	m.Flip(1, 2, start.Add(TurnLimit+time.Second))
+	m.Flip(1, 3, start.Add(TurnLimit+time.Second))
+	m.Timeout(start.Add(61 * time.Second))
+	if r := m.Result(); m.Status != MatchFinished || !slices.Equal(r.Winners, []string{"alice", "bob"}) {
+		t.Fatalf("status %s, winners %v; want a tie between alice and bob", m.Status, r.Winners)
+	}
+}
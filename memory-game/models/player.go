@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Player is a player's profile, which links their games together. Token is
+// the secret a client proves it owns the profile with; it is only shown
+// when the profile is created.
+type Player struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Token   string    `json:"token"`
+	Created time.Time `json:"created"`
+}
+
+// GameRecord is how one of a player's games ended: won, or lost by running
+// out of time or being abandoned
+type GameRecord struct {
+	PlayerID   string    `json:"playerId"`
+	GameID     string    `json:"gameId"`
+	Difficulty string    `json:"difficulty"`
+	Won        bool      `json:"won"`
+	Time       int       `json:"time"` // in seconds
+	Moves      int       `json:"moves"`
+	Score      int       `json:"score"`
+	Date       time.Time `json:"date"` // when the game ended
+}
+
+// PlayerStats sums up a player's games at one difficulty. Times and moves
+// are only counted for games won, and are 0 until one is.
+type PlayerStats struct {
+	Played       int     `json:"played"`
+	Won          int     `json:"won"`
+	WinRate      float64 `json:"winRate"`      // won / played
+	BestTime     int     `json:"bestTime"`     // seconds
+	AverageTime  float64 `json:"averageTime"`  // seconds
+	AverageMoves float64 `json:"averageMoves"` // per game
+}
+
+// SummarizeGames returns the stats of records for each difficulty played
+func SummarizeGames(records []GameRecord) map[string]*PlayerStats {
+	stats := make(map[string]*PlayerStats)
+	totals := make(map[string][2]int) // time and moves of games won
+	for _, r := range records {
+		s := stats[r.Difficulty]
+		if s == nil {
+			s = &PlayerStats{}
+			stats[r.Difficulty] = s
+		}
+		s.Played++
+		if !r.Won {
+			continue
+		}
+		s.Won++
+		if s.Won == 1 || r.Time < s.BestTime {
+			s.BestTime = r.Time
+		}
+		t := totals[r.Difficulty]
+		totals[r.Difficulty] = [2]int{t[0] + r.Time, t[1] + r.Moves}
+	}
+	for difficulty, s := range stats {
+		s.WinRate = float64(s.Won) / float64(s.Played)
+		if s.Won > 0 {
+			t := totals[difficulty]
+			s.AverageTime = float64(t[0]) / float64(s.Won)
+			s.AverageMoves = float64(t[1]) / float64(s.Won)
+		}
+	}
+	return stats
+}
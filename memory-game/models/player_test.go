@@ -0,0 +1,43 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeGames(t *testing.T) {
+	tests := []struct {
+		desc    string
+		records []GameRecord
+		want    map[string]*PlayerStats
+	}{
+		{"no games", nil, map[string]*PlayerStats{}},
+		{"only losses", []GameRecord{
+			{Difficulty: "easy", Time: 180, Moves: 20},
+			{Difficulty: "easy", Time: 50, Moves: 5},
+		}, map[string]*PlayerStats{
+			"easy": {Played: 2},
+		}},
+		{"wins and losses", []GameRecord{
+			{Difficulty: "easy", Won: true, Time: 40, Moves: 10},
+			{Difficulty: "easy", Time: 180, Moves: 30},
+			{Difficulty: "easy", Won: true, Time: 30, Moves: 13},
+			{Difficulty: "easy", Won: true, Time: 50, Moves: 10},
+		}, map[string]*PlayerStats{
+			"easy": {Played: 4, Won: 3, WinRate: 0.75, BestTime: 30, AverageTime: 40, AverageMoves: 11},
+		}},
+		{"by difficulty", []GameRecord{
+			{Difficulty: "easy", Won: true, Time: 40, Moves: 10},
+			{Difficulty: "hard", Time: 900, Moves: 80},
+			{Difficulty: "hard", Won: true, Time: 600, Moves: 70},
+		}, map[string]*PlayerStats{
+			"easy": {Played: 1, Won: 1, WinRate: 1, BestTime: 40, AverageTime: 40, AverageMoves: 10},
+			"hard": {Played: 2, Won: 1, WinRate: 0.5, BestTime: 600, AverageTime: 600, AverageMoves: 70},
+		}},
+	}
+	for _, tt := range tests {
+		if got := SummarizeGames(tt.records); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
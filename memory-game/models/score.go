@@ -1,10 +1,31 @@
 package models
 
+import "time"
+
 // Score represents a game score entry
 type Score struct {
-	Name       string `json:"name"`
-	Difficulty string `json:"difficulty"` // "easy", "medium", "hard"
-	Time       int    `json:"time"`       // in seconds
+	Name       string    `json:"name"`
+	Difficulty string    `json:"difficulty"` // "easy", "medium", "hard"
+	Time       int       `json:"time"`       // in seconds
+	Moves      int       `json:"moves"`
+	Score      int       `json:"score"`
+	GameID     string    `json:"gameId,omitempty"`   // the game the score is for
+	Date       time.Time `json:"date"`               // when the score was submitted
+	Daily      string    `json:"daily,omitempty"`    // date of the daily challenge played, if any
+	PlayerID   string    `json:"playerId,omitempty"` // profile of the player, if any
+}
+
+// DateFormat is the format of daily challenge dates
+const DateFormat = "2006-01-02"
+
+// Result is the outcome of a finished game, as computed by the server. It is
+// signed and handed to the player, who submits it to the leaderboard.
+type Result struct {
+	GameID     string `json:"gameId"`
+	Difficulty string `json:"difficulty"`
+	Time       int    `json:"time"`
 	Moves      int    `json:"moves"`
 	Score      int    `json:"score"`
-}
\ No newline at end of file
+	Daily      string `json:"daily,omitempty"`
+	PlayerID   string `json:"playerId,omitempty"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on submitted scores
+const (
+	MaxNameLength = 20
+	MaxGameTime   = 3600 // seconds; games are discarded after an hour
+)
+
+// FieldError is a problem with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found with a request
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateName checks a player name: 1 to MaxNameLength letters, digits,
+// spaces, '-', '_' or '.', not starting or ending with a space
+func ValidateName(name string) *FieldError {
+	switch n := utf8.RuneCountInString(name); {
+	case n == 0:
+		return &FieldError{"name", "is required"}
+	case n > MaxNameLength:
+		return &FieldError{"name", fmt.Sprintf("must be at most %d characters", MaxNameLength)}
+	case strings.TrimSpace(name) != name:
+		return &FieldError{"name", "must not start or end with a space"}
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_.", r) {
+			return &FieldError{"name", "may only contain letters, digits, spaces, '-', '_' and '.'"}
+		}
+	}
+	return nil
+}
+
+// Validate checks that s is a score a real game could have produced: a
+// valid name and difficulty, and moves, time and score consistent with the
+// difficulty's pairs, time limit and the scoring rules. Difficulties are
+// looked up in p. It returns nil if s is valid.
+func (s Score) Validate(p *Presets) ValidationError {
+	var errs ValidationError
+	if fe := ValidateName(s.Name); fe != nil {
+		errs = append(errs, *fe)
+	}
+	limit := MaxGameTime
+	d, ok := p.Difficulties[s.Difficulty]
+	if !ok {
+		errs = append(errs, FieldError{"difficulty", "is not a known difficulty"})
+	} else if d.TimeLimit > 0 {
+		limit = d.TimeLimit
+	}
+	if s.Time < 0 || s.Time > limit {
+		errs = append(errs, FieldError{"time", fmt.Sprintf("must be from 0 to %d seconds", limit)})
+	}
+	if s.Moves < 0 {
+		errs = append(errs, FieldError{"moves", "must not be negative"})
+	}
+	if s.Daily != "" {
+		if _, err := time.Parse(DateFormat, s.Daily); err != nil {
+			errs = append(errs, FieldError{"daily", "must be a date like 2006-01-02"})
+		}
+	}
+	if !ok || len(errs) > 0 {
+		return errs
+	}
+
+	// Every pair takes a move, and every other move is a miss
+	if s.Moves < d.Pairs {
+		return append(errs, FieldError{"moves", fmt.Sprintf("must be at least %d, one for each pair", d.Pairs)})
+	}
+	// Misses cost points, but the score never drops below zero, so it can
+	// be anywhere between the total less every penalty and the total with
+	// none
+	misses := s.Moves - d.Pairs
+	most := d.Pairs*MatchPoints + max(0, SpeedBonusMax-s.Time)
+	least := most - misses*MissPenalty
+	if s.Score < max(0, least) || s.Score > most {
+		errs = append(errs, FieldError{"score", fmt.Sprintf("%d is not possible in %d moves and %d seconds", s.Score, s.Moves, s.Time)})
+	}
+	return errs
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"alice", true},
+		{"Zoë O.", true},
+		{"bob_2-b", true},
+		{strings.Repeat("é", MaxNameLength), true},
+		{"", false},
+		{strings.Repeat("a", MaxNameLength+1), false},
+		{" alice", false},
+		{"alice ", false},
+		{"<b>alice</b>", false},
+		{"alice\n", false},
+	}
+	for _, tt := range tests {
+		if fe := ValidateName(tt.name); (fe == nil) != tt.ok {
+			t.Errorf("ValidateName(%q) = %v, want ok %v", tt.name, fe, tt.ok)
+		}
+	}
+}
+
+func TestScoreValidate(t *testing.T) {
+	// A perfect easy game: 8 pairs in 8 moves and 30 seconds
+	p := DefaultPresets()
+	valid := Score{Name: "alice", Difficulty: "easy", Time: 30, Moves: 8, Score: 8*MatchPoints + SpeedBonusMax - 30}
+	tests := []struct {
+		desc   string
+		change func(*Score)
+		fields string // the fields with errors, in order
+	}{
+		{"valid", func(s *Score) {}, ""},
+		{"misses within the penalty", func(s *Score) { s.Moves, s.Score = 10, s.Score-2*MissPenalty }, ""},
+		{"misses without a penalty", func(s *Score) { s.Moves = 10 }, ""},
+		{"no speed bonus", func(s *Score) { s.Time, s.Score = 180, 8*MatchPoints }, ""},
+		{"daily", func(s *Score) { s.Daily = "2026-10-18" }, ""},
+		{"bad name", func(s *Score) { s.Name = "" }, "name"},
+		{"unknown difficulty", func(s *Score) { s.Difficulty = "impossible" }, "difficulty"},
+		{"negative time", func(s *Score) { s.Time = -1 }, "time"},
+		{"past the time limit", func(s *Score) { s.Time = 181 }, "time"},
+		{"negative moves", func(s *Score) { s.Moves = -1 }, "moves"},
+		{"fewer moves than pairs", func(s *Score) { s.Moves = 7 }, "moves"},
+		{"score too high", func(s *Score) { s.Score++ }, "score"},
+		{"score too low", func(s *Score) { s.Moves, s.Score = 10, s.Score-2*MissPenalty-1 }, "score"},
+		{"bad daily date", func(s *Score) { s.Daily = "18/10/2026" }, "daily"},
+		{"everything", func(s *Score) { *s = Score{Difficulty: "x", Time: -1, Moves: -1} }, "name difficulty time moves"},
+	}
+	for _, tt := range tests {
+		s := valid
+		tt.change(&s)
+		var fields []string
+		for _, fe := range s.Validate(p) {
+			fields = append(fields, fe.Field)
+		}
+		if got := strings.Join(fields, " "); got != tt.fields {
+			t.Errorf("%s: errors in %q, want %q", tt.desc, got, tt.fields)
+		}
+	}
+}
@@ -1,25 +1,24 @@
-// Emojis for cards
-const emojis = ['🎮', '🎵', '🎨', '🚀', '🐶', '🍎', '⚽', '🌟', '🍕', '🎂', '🌈', '🐱', '🎸', '🚲', '🍦', '🦄', '🍔', '🎃', '🌺', '🐸', '🍇', '⚡', '🔥', '🌙', '💎', '🎈', '🔔', '🎁', '🎊', '🍭', '🍪', '🥤'];
-
-const difficulties = {
-    easy: { rows: 4, cols: 4, pairs: 8, timeLimit: 300 }, // 5 min
-    medium: { rows: 6, cols: 6, pairs: 18, timeLimit: 240 }, // 4 min
-    hard: { rows: 8, cols: 8, pairs: 32, timeLimit: 180 } // 3 min
-};
-
-let currentDifficulty = null;
-let board = [];
-let flippedCards = [];
-let matchedPairs = 0;
+// The board, moves, time and score are kept by the server, which deals the
+// cards and reveals each one only when it is flipped.
+let gameId = null;
+let daily = null; // date of the daily challenge being played, if any
+let result = null; // signed result of a won game, for the leaderboard
+let flipping = false; // a flip request or mismatched pair is showing
 let moves = 0;
 let score = 0;
 let timer = 0;
 let interval = null;
 let startTime = null;
+let timeLimit = 0; // seconds from the first flip, or 0 for none
+
+// Leaderboard view: one entry per player, a page at a time
+const pageSize = 10;
+let lbDifficulty = 'all';
+let lbWindow = 'all';
+let lbDaily = ''; // '', 'today' or the date of a past daily challenge
+let lbOffset = 0;
+let lbTotal = 0;
 
-document.getElementById('easy-btn').addEventListener('click', () => selectDifficulty('easy'));
-document.getElementById('medium-btn').addEventListener('click', () => selectDifficulty('medium'));
-document.getElementById('hard-btn').addEventListener('click', () => selectDifficulty('hard'));
 document.getElementById('new-game').addEventListener('click', () => {
     document.getElementById('game-area').style.display = 'none';
     document.getElementById('difficulty-selection').style.display = 'flex';
@@ -27,152 +26,289 @@ document.getElementById('new-game').addEventListener('click', () => {
 });
 document.getElementById('submit-score').addEventListener('click', submitScore);
 document.getElementById('play-again').addEventListener('click', () => location.reload());
-document.querySelectorAll('.filter').forEach(btn => {
+document.querySelector('.filter[data-difficulty="all"]').addEventListener('click', e => selectFilter(e.target));
+document.querySelectorAll('.filter[data-window]').forEach(btn => {
     btn.addEventListener('click', () => {
-        document.querySelectorAll('.filter').forEach(b => b.classList.remove('active'));
+        document.querySelectorAll('.filter[data-window]').forEach(b => b.classList.remove('active'));
         btn.classList.add('active');
-        loadLeaderboard(btn.dataset.difficulty);
+        lbWindow = btn.dataset.window;
+        loadLeaderboard(lbDifficulty);
     });
 });
+document.getElementById('daily-select').addEventListener('change', e => {
+    lbDaily = e.target.value;
+    loadLeaderboard(lbDifficulty);
+});
+document.getElementById('prev-page').addEventListener('click', () => {
+    if (lbOffset > 0) loadLeaderboard(lbDifficulty, Math.max(0, lbOffset - pageSize));
+});
+document.getElementById('next-page').addEventListener('click', () => {
+    if (lbOffset + pageSize < lbTotal) loadLeaderboard(lbDifficulty, lbOffset + pageSize);
+});
 
-// Load leaderboard on start
+// Load the game settings, leaderboard and past daily challenges on start
+loadConfig();
 loadLeaderboard('all');
+loadDailyArchive();
+
+// loadConfig fills in the difficulties and card themes the server offers
+function loadConfig() {
+    fetch('/api/config')
+        .then(res => res.json())
+        .then(config => {
+            const buttons = document.getElementById('difficulty-buttons');
+            const filters = document.getElementById('leaderboard-filters');
+            const mpDifficulty = document.getElementById('mp-difficulty');
+            config.difficulties.forEach(d => {
+                const btn = document.createElement('button');
+                btn.textContent = d.name;
+                btn.addEventListener('click', () => selectDifficulty(d.id));
+                buttons.appendChild(btn);
+
+                const filter = document.createElement('button');
+                filter.className = 'filter';
+                filter.dataset.difficulty = d.id;
+                filter.textContent = d.name.replace(/ \(.*\)$/, '');
+                filter.addEventListener('click', () => selectFilter(filter));
+                filters.appendChild(filter);
+
+                mpDifficulty.appendChild(new Option(d.name, d.id));
+            });
+            const themes = document.getElementById('theme-select');
+            config.themes.forEach(theme => {
+                themes.appendChild(new Option(theme, theme, false, theme === config.defaultTheme));
+            });
+        });
+}
+
+function selectFilter(btn) {
+    document.querySelectorAll('.filter[data-difficulty]').forEach(b => b.classList.remove('active'));
+    btn.classList.add('active');
+    loadLeaderboard(btn.dataset.difficulty);
+}
 
 function selectDifficulty(diff) {
     document.getElementById('difficulty-selection').style.display = 'none';
     document.getElementById('game-area').style.display = 'flex';
-    startGame(diff);
+    startGame(diff, document.getElementById('daily-check').checked);
 }
 
-function startGame(diff) {
-    currentDifficulty = diff;
-    const { rows, cols, pairs } = difficulties[diff];
-    board = generateBoard(pairs);
-    renderBoard(rows, cols);
-    flippedCards = [];
-    matchedPairs = 0;
+function startGame(diff, playDaily) {
+    gameId = null;
+    daily = null;
+    result = null;
+    flipping = false;
     moves = 0;
     score = 0;
     timer = 0;
     startTime = null;
+    timeLimit = 0;
+    document.getElementById('game-message').textContent = '';
     updateStats();
     clearInterval(interval);
-    interval = setInterval(() => {
-        if (startTime) {
-            timer++;
+    document.getElementById('board').innerHTML = '';
+
+    fetch('/api/games', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            difficulty: diff,
+            theme: document.getElementById('theme-select').value,
+            daily: playDaily,
+            player: profile ? profile.id : '',
+            token: profile ? profile.token : ''
+        })
+    })
+        .then(async res => {
+            if (!res.ok) throw new Error((await res.text()).trim());
+            return res.json();
+        })
+        .then(game => {
+            gameId = game.id;
+            daily = game.daily || null;
+            timeLimit = game.timeLimit || 0;
             updateStats();
-            if (timer >= difficulties[currentDifficulty].timeLimit) {
-                // Time out, but for simplicity, just continue
-            }
-        }
-    }, 1000);
+            renderBoard(game.rows, game.cols);
+            interval = setInterval(() => {
+                if (startTime) {
+                    timer++;
+                    updateStats();
+                    if (timeLimit && timer > timeLimit) timeUp();
+                }
+            }, 1000);
+        })
+        .catch(err => {
+            // e.g. today's challenge was already played, or there's no profile
+            document.getElementById('game-message').textContent = err.message;
+        });
     loadLeaderboard('all');
 }
 
-function generateBoard(pairs) {
-    const symbols = [];
-    for (let i = 0; i < pairs; i++) {
-        symbols.push(emojis[i], emojis[i]);
-    }
-    return shuffle(symbols);
-}
-
-function shuffle(array) {
-    for (let i = array.length - 1; i > 0; i--) {
-        const j = Math.floor(Math.random() * (i + 1));
-        [array[i], array[j]] = [array[j], array[i]];
-    }
-    return array;
-}
-
-function renderBoard(rows, cols) {
+// renderBoard lays out face-down cards; clicking one calls onFlip
+function renderBoard(rows, cols, onFlip = flipCard) {
     const boardEl = document.getElementById('board');
     boardEl.style.gridTemplateColumns = `repeat(${cols}, 1fr)`;
     boardEl.innerHTML = '';
-    board.forEach((symbol, index) => {
+    for (let index = 0; index < rows * cols; index++) {
         const card = document.createElement('div');
         card.className = 'card';
         card.dataset.index = index;
         card.innerHTML = `
             <div class="card-back">?</div>
-            <div class="card-front">${symbol}</div>
+            <div class="card-front"></div>
         `;
-        card.addEventListener('click', () => flipCard(card, index));
+        card.addEventListener('click', () => onFlip(card, index));
         boardEl.appendChild(card);
-    });
+    }
+}
+
+function cardAt(index) {
+    return document.querySelector(`.card[data-index="${index}"]`);
 }
 
 function flipCard(card, index) {
-    if (card.classList.contains('flipped') || card.classList.contains('matched') || flippedCards.length >= 2) return;
-    if (!startTime) startTime = Date.now();
-    card.classList.add('flipped');
-    flippedCards.push({ card, index });
-    if (flippedCards.length === 2) {
-        moves++;
-        setTimeout(checkMatch, 1000);
-    }
+    if (!gameId || flipping || card.classList.contains('flipped') || card.classList.contains('matched')) return;
+    flipping = true;
+    fetch(`/api/games/${gameId}/flip`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ index })
+    })
+        .then(async res => {
+            if (!res.ok) {
+                const message = (await res.text()).trim();
+                if (message === 'time is up') timeUp();
+                throw new Error(`flip failed: ${message}`);
+            }
+            return res.json();
+        })
+        .then(flip => {
+            if (!startTime) startTime = Date.now();
+            card.querySelector('.card-front').textContent = flip.symbol;
+            card.classList.add('flipped');
+            moves = flip.moves;
+            score = flip.score;
+            updateStats();
+            if (!flip.pair) {
+                flipping = false;
+            } else if (flip.matched) {
+                flip.pair.forEach(i => cardAt(i).classList.add('matched'));
+                flipping = false;
+                if (flip.finished) {
+                    timer = flip.time || 0;
+                    result = flip.result;
+                    win();
+                }
+            } else {
+                // Show the pair for a moment before turning it back over
+                setTimeout(() => {
+                    flip.pair.forEach(i => cardAt(i).classList.remove('flipped'));
+                    flipping = false;
+                }, 1000);
+            }
+        })
+        .catch(err => {
+            console.error(err);
+            flipping = false;
+        });
 }
 
-function checkMatch() {
-    const [card1, card2] = flippedCards;
-    if (board[card1.index] === board[card2.index]) {
-        card1.card.classList.add('matched');
-        card2.card.classList.add('matched');
-        matchedPairs++;
-        score += 10; // base score
-        if (matchedPairs === difficulties[currentDifficulty].pairs) {
-            score += Math.max(0, 100 - timer); // bonus for speed
-            win();
-        }
-    } else {
-        card1.card.classList.remove('flipped');
-        card2.card.classList.remove('flipped');
-        score = Math.max(0, score - 2); // penalty
-    }
-    flippedCards = [];
-    updateStats();
+// timeUp ends a game whose time limit the server says has passed
+function timeUp() {
+    clearInterval(interval);
+    gameId = null;
+    document.getElementById('game-message').textContent = "⏰ Time's up! Press NEW GAME to try again.";
+    loadStats();
 }
 
 function win() {
     clearInterval(interval);
+    updateStats();
     document.getElementById('win-time').textContent = formatTime(timer);
     document.getElementById('win-moves').textContent = moves;
     document.getElementById('win-score').textContent = score;
     document.getElementById('win-modal').style.display = 'flex';
+    loadStats();
 }
 
 function submitScore() {
-    const name = document.getElementById('player-name').value;
-    if (!name) return;
+    const name = document.getElementById('player-name').value.trim();
+    const errorEl = document.getElementById('submit-error');
+    if (!name || !result) return;
+    errorEl.textContent = '';
     fetch('/api/game/result', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({
-            name,
-            difficulty: currentDifficulty,
-            time: timer,
-            moves,
-            score
-        })
-    }).then(() => {
-        loadLeaderboard(document.querySelector('.filter.active').dataset.difficulty);
+        body: JSON.stringify({ name, result })
+    }).then(async res => {
+        if (res.status === 400) {
+            const body = await res.json();
+            errorEl.textContent = body.fields.map(f => `${f.field} ${f.message}`).join('. ');
+            return;
+        }
+        if (!res.ok) {
+            errorEl.textContent = (await res.text()).trim();
+            return;
+        }
+        result = null;
+        if (daily) {
+            // Show the board the score went on
+            lbDaily = 'today';
+            document.getElementById('daily-select').value = 'today';
+        }
+        loadLeaderboard(lbDifficulty);
         document.getElementById('win-modal').style.display = 'none';
     });
 }
 
-function loadLeaderboard(difficulty) {
-    fetch(`/api/leaderboard?difficulty=${difficulty}`)
+function loadLeaderboard(difficulty, offset = 0) {
+    lbDifficulty = difficulty;
+    const params = new URLSearchParams({
+        difficulty,
+        window: lbWindow,
+        daily: lbDaily,
+        unique: 'true',
+        limit: pageSize,
+        offset
+    });
+    fetch(`/api/leaderboard?${params}`)
         .then(res => res.json())
         .then(data => {
+            lbOffset = offset;
+            lbTotal = data.total;
             const content = document.getElementById('leaderboard-content');
-            content.innerHTML = data.map((s, i) =>
-                `<p>${i+1}. ${s.name}: ${s.score} pts (${formatTime(s.time)}, ${s.moves} moves) - ${s.difficulty}</p>`
-            ).join('');
+            // Only the first page shows the podium
+            content.classList.toggle('first-page', offset === 0);
+            // Names are typed by players, so they are only ever set as text
+            content.replaceChildren(...data.scores.map(s => {
+                const line = document.createElement('p');
+                line.textContent = `${s.rank}. ${s.name}: ${s.score} pts (${formatTime(s.time)}, ${s.moves} moves) - ${s.difficulty}`;
+                return line;
+            }));
+            const pages = Math.max(1, Math.ceil(lbTotal / pageSize));
+            document.getElementById('page-info').textContent = `${Math.floor(offset / pageSize) + 1} / ${pages}`;
+            document.getElementById('prev-page').disabled = offset === 0;
+            document.getElementById('next-page').disabled = offset + pageSize >= lbTotal;
+        });
+}
+
+function loadDailyArchive() {
+    fetch('/api/daily')
+        .then(res => res.json())
+        .then(data => {
+            const select = document.getElementById('daily-select');
+            data.archive.forEach(day => {
+                const option = document.createElement('option');
+                option.value = day;
+                option.textContent = `Challenge of ${day}`;
+                select.appendChild(option);
+            });
         });
 }
 
 function updateStats() {
-    document.getElementById('timer').textContent = formatTime(timer);
+    // With a time limit, the timer counts down
+    document.getElementById('timer').textContent = formatTime(timeLimit ? Math.max(0, timeLimit - timer) : timer);
     document.getElementById('moves').textContent = moves;
     document.getElementById('score').textContent = score;
 }
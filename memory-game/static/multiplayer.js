@@ -0,0 +1,152 @@
+// Multiplayer matches are played over a websocket. The server deals one
+// board for the match, takes turns in order and tells every player about
+// each flip; a player who finds a pair goes again.
+let socket = null;
+let mySeat = -1;
+let matchTurn = 0;
+let matchPlayers = [];
+
+document.getElementById('mp-join').addEventListener('click', joinMatch);
+document.getElementById('new-game').addEventListener('click', leaveMatch);
+
+loadMatches();
+
+function joinMatch() {
+    const name = document.getElementById('mp-name').value.trim();
+    const errorEl = document.getElementById('mp-error');
+    if (!name) {
+        errorEl.textContent = 'Enter your name to play';
+        return;
+    }
+    errorEl.textContent = '';
+    const params = new URLSearchParams({
+        name,
+        difficulty: document.getElementById('mp-difficulty').value,
+        players: document.getElementById('mp-players').value,
+        theme: document.getElementById('theme-select').value
+    });
+    const code = document.getElementById('mp-code').value.trim();
+    if (code) params.set('match', code);
+
+    const proto = location.protocol === 'https:' ? 'wss' : 'ws';
+    let opened = false;
+    socket = new WebSocket(`${proto}://${location.host}/api/matches/ws?${params}`);
+    socket.onopen = () => {
+        opened = true;
+        mySeat = -1;
+        document.getElementById('difficulty-selection').style.display = 'none';
+        document.getElementById('game-area').style.display = 'flex';
+        document.getElementById('stats').style.display = 'none';
+        document.getElementById('mp-panel').style.display = 'block';
+        document.getElementById('board').innerHTML = '';
+    };
+    socket.onmessage = e => handleMatchEvent(JSON.parse(e.data));
+    socket.onclose = () => {
+        if (!opened) {
+            errorEl.textContent = 'Could not join: the match is full, the name is taken or the code is wrong';
+        }
+        socket = null;
+    };
+}
+
+function leaveMatch() {
+    if (socket) socket.close();
+    document.getElementById('stats').style.display = '';
+    document.getElementById('mp-panel').style.display = 'none';
+}
+
+function handleMatchEvent(ev) {
+    switch (ev.type) {
+        case 'waiting':
+            if (!document.getElementById('board').children.length) {
+                renderBoard(ev.rows, ev.cols, matchFlip);
+            }
+            showPlayers(ev);
+            setMatchStatus(`Waiting for players (${ev.players.length}/${ev.size}). Match code: ${ev.match}`);
+            break;
+        case 'start':
+            mySeat = ev.you;
+            if (!document.getElementById('board').children.length) {
+                renderBoard(ev.rows, ev.cols, matchFlip);
+            }
+            showPlayers(ev);
+            break;
+        case 'flip':
+            hideCards(ev.hidden);
+            const card = cardAt(ev.index);
+            card.querySelector('.card-front').textContent = ev.symbol;
+            card.classList.add('flipped');
+            if (ev.matched) {
+                ev.pair.forEach(i => cardAt(i).classList.add('matched'));
+            } else if (ev.pair) {
+                // Show the pair for a moment before turning it back over
+                setTimeout(() => hideCards(ev.pair), 1000);
+            }
+            showPlayers(ev);
+            break;
+        case 'left':
+            hideCards(ev.hidden);
+            showPlayers(ev);
+            break;
+        case 'timeout':
+            // The player in turn didn't flip within ev.turnLimit seconds
+            hideCards(ev.hidden);
+            showPlayers(ev);
+            const who = ev.player === mySeat ? 'You' : ev.players[ev.player].name;
+            setMatchStatus(`${who} took too long. ${document.getElementById('mp-status').textContent}`);
+            break;
+        case 'end':
+            const winners = ev.result.winners;
+            setMatchStatus(winners.length > 1 ? `Tie between ${winners.join(' and ')}!` : `${winners[0]} wins!`);
+            loadMatches();
+            break;
+        case 'error':
+            setMatchStatus(ev.message);
+            break;
+    }
+}
+
+function matchFlip(card, index) {
+    if (!socket || mySeat !== matchTurn || card.classList.contains('matched')) return;
+    socket.send(JSON.stringify({ type: 'flip', index }));
+}
+
+function hideCards(indexes) {
+    (indexes || []).forEach(i => {
+        if (!cardAt(i).classList.contains('matched')) cardAt(i).classList.remove('flipped');
+    });
+}
+
+function showPlayers(ev) {
+    matchPlayers = ev.players;
+    matchTurn = ev.turn;
+    // Names are typed by players, so they are only ever set as text
+    document.getElementById('mp-standings').replaceChildren(...matchPlayers.map((p, seat) => {
+        const item = document.createElement('li');
+        item.textContent = `${p.name}: ${p.pairs}`;
+        item.classList.toggle('me', seat === mySeat);
+        item.classList.toggle('left', !!p.left);
+        item.classList.toggle('turn', ev.status === 'playing' && seat === matchTurn);
+        return item;
+    }));
+    if (ev.status === 'playing') {
+        setMatchStatus(matchTurn === mySeat ? 'Your turn' : `${matchPlayers[matchTurn].name}'s turn`);
+    }
+}
+
+function setMatchStatus(text) {
+    document.getElementById('mp-status').textContent = text;
+}
+
+function loadMatches() {
+    fetch('/api/matches?limit=5')
+        .then(res => res.json())
+        .then(data => {
+            document.getElementById('matches-content').replaceChildren(...data.matches.map(m => {
+                const line = document.createElement('p');
+                const players = m.players.map(p => `${p.name} ${p.pairs}`).join(' · ');
+                line.textContent = `${players} (${m.difficulty}) - won by ${m.winners.join(' & ')}`;
+                return line;
+            }));
+        });
+}
@@ -0,0 +1,78 @@
+// A profile links a player's games together so the server can keep their
+// stats. Its ID and token are kept in the browser.
+let profile = JSON.parse(localStorage.getItem('memoryProfile') || 'null');
+
+document.getElementById('profile-create').addEventListener('click', createProfile);
+
+if (profile) {
+    // Forget a profile the server no longer knows
+    fetch(`/api/players/${profile.id}`).then(res => {
+        if (res.status === 404) {
+            profile = null;
+            localStorage.removeItem('memoryProfile');
+        }
+        showProfile();
+    });
+}
+
+function createProfile() {
+    const name = document.getElementById('profile-name').value.trim();
+    const errorEl = document.getElementById('profile-error');
+    errorEl.textContent = '';
+    fetch('/api/players', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ name })
+    }).then(async res => {
+        if (res.status === 400) {
+            const body = await res.json();
+            errorEl.textContent = body.fields.map(f => `${f.field} ${f.message}`).join('. ');
+            return;
+        }
+        if (!res.ok) {
+            errorEl.textContent = (await res.text()).trim();
+            return;
+        }
+        profile = await res.json();
+        localStorage.setItem('memoryProfile', JSON.stringify(profile));
+        showProfile();
+    });
+}
+
+function showProfile() {
+    if (!profile) return;
+    document.getElementById('profile-new').style.display = 'none';
+    document.getElementById('profile-info').style.display = 'block';
+    document.getElementById('profile-player').textContent = profile.name;
+    // Sign scores and matches with the profile's name by default
+    ['player-name', 'mp-name'].forEach(id => {
+        const input = document.getElementById(id);
+        if (!input.value) input.value = profile.name;
+    });
+    loadStats();
+}
+
+function loadStats() {
+    if (!profile) return;
+    fetch(`/api/players/${profile.id}/stats`)
+        .then(res => res.json())
+        .then(data => {
+            const rows = Object.entries(data.stats).map(([difficulty, s]) => {
+                const row = document.createElement('tr');
+                [
+                    difficulty,
+                    s.played,
+                    `${Math.round(s.winRate * 100)}%`,
+                    s.won ? formatTime(s.bestTime) : '-',
+                    s.won ? formatTime(Math.round(s.averageTime)) : '-',
+                    s.won ? s.averageMoves.toFixed(1) : '-'
+                ].forEach(value => {
+                    const cell = document.createElement('td');
+                    cell.textContent = value;
+                    row.appendChild(cell);
+                });
+                return row;
+            });
+            document.querySelector('#profile-stats tbody').replaceChildren(...rows);
+        });
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"memory-game/models"
+	"os"
+	"path/filepath"
+)
+
+// JSONLStore is a ScoreStore backed by an append-only file with one JSON
+// score per line. Scores are also kept in memory, loaded from the file when
+// it is opened.
+//
+// A line left half written by a crash, or a duplicate, is skipped on load,
+// and the file is then compacted: rewritten with only the scores that were
+// loaded.
+type JSONLStore struct {
+	mem  *MemoryStore
+	path string
+	f    *os.File
+}
+
+// OpenJSONLStore loads the scores in the file at path, creating it if
+// needed, and appends new scores to it
+func OpenJSONLStore(path string) (*JSONLStore, error) {
+	s := &JSONLStore{mem: NewMemoryStore(), path: path}
+	skipped, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d bad lines in %s; compacting", skipped, path)
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	s.f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the file into memory and returns how many lines it skipped
+func (s *JSONLStore) load() (skipped int, err error) {
+	return readJSONL(s.path, func(line []byte) error {
+		var score models.Score
+		if err := json.Unmarshal(line, &score); err != nil {
+			return err
+		}
+		return s.mem.add(score)
+	})
+}
+
+// compact replaces the file with the scores held in memory
+func (s *JSONLStore) compact() error {
+	return rewriteJSONL(s.path, s.mem.scores)
+}
+
+// readJSONL calls add with each non-blank line of the file at path, if it
+// exists, and returns how many lines add rejected
+func readJSONL(path string, add func(line []byte) error) (skipped int, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if add(line) != nil {
+			skipped++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return skipped, nil
+}
+
+// rewriteJSONL replaces the file at path with items, one per line. The new
+// file is written beside the old one and renamed over it, so a crash leaves
+// one or the other.
+func rewriteJSONL[T any](path string, items []T) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Add appends score to the file before adding it in memory, so a score
+// that is reported saved survives a restart
+func (s *JSONLStore) Add(score models.Score) error {
+	line, err := json.Marshal(score)
+	if err != nil {
+		return err
+	}
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	if err := s.mem.check(score); err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.mem.add(score)
+}
+
+func (s *JSONLStore) Query(q Query) (Page, error) {
+	return s.mem.Query(q)
+}
+
+func (s *JSONLStore) Rank(name string, q Query) (Ranked, error) {
+	return s.mem.Rank(name, q)
+}
+
+func (s *JSONLStore) Days() ([]string, error) {
+	return s.mem.Days()
+}
+
+func (s *JSONLStore) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/json"
+	"memory-game/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// score returns a score for game gameID
+func score(name, gameID string, points int) models.Score {
+	return models.Score{
+		Name:       name,
+		Difficulty: "easy",
+		Time:       30,
+		Moves:      10,
+		Score:      points,
+		GameID:     gameID,
+		Date:       time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// names lists the names of the scores on a page, best first
+func names(page Page) string {
+	var names []string
+	for _, r := range page.Scores {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestJSONLStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.jsonl")
+	s, err := OpenJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sc := range []models.Score{score("alice", "g1", 100), score("bob", "g2", 150)} {
+		if err := s.Add(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = OpenJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	page, _ := s.Query(Query{})
+	if got := names(page); got != "bob alice" {
+		t.Fatalf("after reopening, leaderboard is %q, want %q", got, "bob alice")
+	}
+	if err := s.Add(score("carol", "g1", 10)); err != ErrDuplicate {
+		t.Fatalf("adding a loaded game again: %v, want %v", err, ErrDuplicate)
+	}
+}
+
+func TestJSONLStoreCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.jsonl")
+	var lines []string
+	for _, sc := range []models.Score{score("alice", "g1", 100), score("mallory", "g1", 200), score("bob", "g2", 150)} {
+		line, _ := json.Marshal(sc)
+		lines = append(lines, string(line))
+	}
+	// A duplicate of g1, a blank line and a line cut short by a crash
+	data := lines[0] + "\n" + lines[1] + "\n\n" + lines[2] + "\n" + lines[2][:20]
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := s.Query(Query{})
+	if got := names(page); got != "bob alice" {
+		t.Fatalf("loaded %q, want %q", got, "bob alice")
+	}
+	if err := s.Add(score("carol", "g3", 50)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	data2, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lines[0] + "\n" + lines[2] + "\n"
+	if got := string(data2); !strings.HasPrefix(got, want) || strings.Count(got, "\n") != 3 {
+		t.Fatalf("compacted file:\n%s\nwant the good lines then the new score", got)
+	}
+}
+
+func TestOpenJSONLStoreCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.jsonl")
+	s, err := OpenJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("file not created: %v", err)
+	}
+	if page, _ := s.Query(Query{}); page.Total != 0 || page.Scores == nil {
+		t.Fatalf("new store has %+v", page)
+	}
+}
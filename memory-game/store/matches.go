@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"memory-game/models"
+	"os"
+	"sync"
+)
+
+// MatchStore keeps the results of multiplayer matches, apart from the solo
+// leaderboard. Implementations are safe for concurrent use.
+type MatchStore interface {
+	// Add saves a match result. Results are unique by MatchID.
+	Add(result models.MatchResult) error
+	// Recent returns up to limit results, newest first.
+	Recent(limit int) ([]models.MatchResult, error)
+	// Close releases the store's resources.
+	Close() error
+}
+
+// MatchLog is a MatchStore that keeps results in memory and, if opened with
+// OpenMatchLog, appends them to a file with one JSON result per line
+type MatchLog struct {
+	mu      sync.Mutex
+	results []models.MatchResult // oldest first
+	matches map[string]bool
+	f       *os.File // nil if kept in memory only
+}
+
+// NewMatchLog returns an empty MatchLog kept in memory only
+func NewMatchLog() *MatchLog {
+	return &MatchLog{matches: make(map[string]bool)}
+}
+
+// OpenMatchLog loads the results in the file at path, creating it if
+// needed, and appends new results to it. Like OpenJSONLStore, it skips bad
+// or duplicate lines and compacts the file if there were any.
+func OpenMatchLog(path string) (*MatchLog, error) {
+	l := NewMatchLog()
+	skipped, err := readJSONL(path, func(line []byte) error {
+		var result models.MatchResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			return err
+		}
+		return l.add(result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d bad lines in %s; compacting", skipped, path)
+		if err := rewriteJSONL(path, l.results); err != nil {
+			return nil, err
+		}
+	}
+	l.f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// add keeps result unless its match already has one. l.mu must be held.
+func (l *MatchLog) add(result models.MatchResult) error {
+	if result.MatchID == "" {
+		return errors.New("result has no match ID")
+	}
+	if l.matches[result.MatchID] {
+		return ErrDuplicate
+	}
+	l.matches[result.MatchID] = true
+	l.results = append(l.results, result)
+	return nil
+}
+
+func (l *MatchLog) Add(result models.MatchResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.matches[result.MatchID] {
+		return ErrDuplicate
+	}
+	if l.f != nil {
+		line, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		if _, err := l.f.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return l.add(result)
+}
+
+func (l *MatchLog) Recent(limit int) ([]models.MatchResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := []models.MatchResult{}
+	for i := len(l.results) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, l.results[i])
+	}
+	return recent, nil
+}
+
+func (l *MatchLog) Close() error {
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
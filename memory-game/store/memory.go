@@ -0,0 +1,310 @@
+package store
+
+import (
+	"memory-game/models"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a ScoreStore that keeps scores in memory, so they are lost
+// when the server stops.
+//
+// Scores are kept sorted as they are added, in an index for each
+// difficulty and order, so a page of the leaderboard or a player's rank is
+// found without sorting or scanning every score. Only queries limited to
+// recent scores scan an index. Each daily challenge has indexes of its own,
+// apart from the regular leaderboard.
+type MemoryStore struct {
+	mu      sync.Mutex
+	scores  []models.Score // in the order they were added
+	games   map[string]bool
+	days    map[string]bool // daily challenges with scores
+	dailies map[string]bool // names and players with a daily score, by dailyKeys
+	indexes map[indexKey]*index
+}
+
+type indexKey struct {
+	daily      string // "" for regular games
+	difficulty string // "" for every difficulty
+	sort       string
+}
+
+// entry is a score in an index. seq, the order scores were added in,
+// breaks ties so that every entry has its own place.
+type entry struct {
+	models.Score
+	seq int
+}
+
+// orders compare entries for each sort: a before b if a ranks higher
+var orders = map[string]func(a, b *entry) bool{
+	SortScore: func(a, b *entry) bool {
+		if a.Score.Score != b.Score.Score {
+			return a.Score.Score > b.Score.Score
+		}
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		return a.seq < b.seq
+	},
+	SortTime: func(a, b *entry) bool {
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		if a.Moves != b.Moves {
+			return a.Moves < b.Moves
+		}
+		return a.seq < b.seq
+	},
+	SortMoves: func(a, b *entry) bool {
+		if a.Moves != b.Moves {
+			return a.Moves < b.Moves
+		}
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		return a.seq < b.seq
+	},
+}
+
+// index holds the scores at one difficulty in one order
+type index struct {
+	less     func(a, b *entry) bool
+	all      []*entry          // every score, best first
+	best     []*entry          // each player's best score, best first
+	byPlayer map[string]*entry // each player's best score, by playerKey
+}
+
+// playerKey identifies a player on the leaderboard by name, ignoring case,
+// so that "Alice" and "alice" share one place
+func playerKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// position returns where e is, or would be, in list
+func (ix *index) position(list []*entry, e *entry) int {
+	return sort.Search(len(list), func(i int) bool { return !ix.less(list[i], e) })
+}
+
+func (ix *index) insert(e *entry) {
+	ix.all = insertAt(ix.all, ix.position(ix.all, e), e)
+	key := playerKey(e.Name)
+	if old := ix.byPlayer[key]; old != nil {
+		if !ix.less(e, old) {
+			return
+		}
+		i := ix.position(ix.best, old)
+		ix.best = append(ix.best[:i], ix.best[i+1:]...)
+	}
+	ix.byPlayer[key] = e
+	ix.best = insertAt(ix.best, ix.position(ix.best, e), e)
+}
+
+func insertAt(list []*entry, i int, e *entry) []*entry {
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = e
+	return list
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		games:   make(map[string]bool),
+		days:    make(map[string]bool),
+		dailies: make(map[string]bool),
+		indexes: make(map[indexKey]*index),
+	}
+}
+
+func (s *MemoryStore) Add(score models.Score) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(score)
+}
+
+// check returns the error adding score would fail with. s.mu must be held.
+func (s *MemoryStore) check(score models.Score) error {
+	if score.GameID != "" && s.games[score.GameID] {
+		return ErrDuplicate
+	}
+	for _, key := range dailyKeys(score) {
+		if s.dailies[key] {
+			return ErrDailyPlayed
+		}
+	}
+	return nil
+}
+
+// dailyKeys identifies the name and player, if any, that played a daily
+// challenge score's board
+func dailyKeys(score models.Score) []string {
+	if score.Daily == "" {
+		return nil
+	}
+	board := score.Daily + " " + score.Difficulty + " "
+	keys := []string{board + "name " + playerKey(score.Name)}
+	if score.PlayerID != "" {
+		keys = append(keys, board+"player "+score.PlayerID)
+	}
+	return keys
+}
+
+// add indexes score unless check rejects it. s.mu must be held.
+func (s *MemoryStore) add(score models.Score) error {
+	if err := s.check(score); err != nil {
+		return err
+	}
+	if score.GameID != "" {
+		s.games[score.GameID] = true
+	}
+	e := &entry{Score: score, seq: len(s.scores)}
+	s.scores = append(s.scores, score)
+	if score.Daily != "" {
+		s.days[score.Daily] = true
+	}
+	for _, key := range dailyKeys(score) {
+		s.dailies[key] = true
+	}
+	for _, difficulty := range []string{"", score.Difficulty} {
+		for order, less := range orders {
+			key := indexKey{score.Daily, difficulty, order}
+			ix := s.indexes[key]
+			if ix == nil {
+				ix = &index{less: less, byPlayer: make(map[string]*entry)}
+				s.indexes[key] = ix
+			}
+			ix.insert(e)
+		}
+	}
+	return nil
+}
+
+// index returns the index q reads, or nil if it has no scores
+func (s *MemoryStore) index(q Query) *index {
+	difficulty, order := q.Difficulty, q.Sort
+	if difficulty == "all" {
+		difficulty = ""
+	}
+	if order == "" {
+		order = SortScore
+	}
+	return s.indexes[indexKey{q.Daily, difficulty, order}]
+}
+
+func (s *MemoryStore) Query(q Query) (Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	page := Page{Scores: []Ranked{}}
+	ix := s.index(q)
+	if ix == nil {
+		return page, nil
+	}
+	end := func(total int) int {
+		if q.Limit > 0 && q.Offset+q.Limit < total {
+			return q.Offset + q.Limit
+		}
+		return total
+	}
+
+	if q.Since.IsZero() {
+		list := ix.all
+		if q.Unique {
+			list = ix.best
+		}
+		page.Total = len(list)
+		for i := q.Offset; i < end(len(list)); i++ {
+			page.Scores = append(page.Scores, Ranked{Rank: i + 1, Score: list[i].Score})
+		}
+		return page, nil
+	}
+
+	s.scan(ix, q, func(rank int, e *entry) bool {
+		if rank > q.Offset && (q.Limit == 0 || rank <= q.Offset+q.Limit) {
+			page.Scores = append(page.Scores, Ranked{Rank: rank, Score: e.Score})
+		}
+		page.Total = rank
+		return true
+	})
+	return page, nil
+}
+
+func (s *MemoryStore) Rank(name string, q Query) (Ranked, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ix := s.index(q)
+	if ix == nil {
+		return Ranked{}, ErrNotFound
+	}
+	if q.Since.IsZero() {
+		e := ix.byPlayer[playerKey(name)]
+		if e == nil {
+			return Ranked{}, ErrNotFound
+		}
+		list := ix.all
+		if q.Unique {
+			list = ix.best
+		}
+		return Ranked{Rank: ix.position(list, e) + 1, Score: e.Score}, nil
+	}
+
+	var found Ranked
+	key := playerKey(name)
+	s.scan(ix, q, func(rank int, e *entry) bool {
+		if playerKey(e.Name) == key {
+			found = Ranked{Rank: rank, Score: e.Score}
+			return false
+		}
+		return true
+	})
+	if found.Rank == 0 {
+		return Ranked{}, ErrNotFound
+	}
+	return found, nil
+}
+
+// scan calls fn with the rank of each score in ix since q.Since, best first,
+// until fn returns false. With q.Unique only each player's best is ranked.
+func (s *MemoryStore) scan(ix *index, q Query, fn func(rank int, e *entry) bool) {
+	var seen map[string]bool
+	if q.Unique {
+		seen = make(map[string]bool)
+	}
+	rank := 0
+	for _, e := range ix.all {
+		if e.Date.Before(q.Since) {
+			continue
+		}
+		if seen != nil {
+			key := playerKey(e.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		rank++
+		if !fn(rank, e) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) Days() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days := make([]string, 0, len(s.days))
+	for day := range s.days {
+		days = append(days, day)
+	}
+	// dates sort as strings
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	return days, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"fmt"
+	"memory-game/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	day1  = time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	day2  = time.Date(2026, 10, 12, 12, 0, 0, 0, time.UTC)
+	since = time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC)
+)
+
+// testStore returns a store with scores that tie in each order, so the
+// tie breaks decide their places
+func testStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore()
+	for i, sc := range []models.Score{
+		{Name: "alice", Difficulty: "easy", Score: 100, Time: 40, Moves: 12, Date: day1},
+		{Name: "bob", Difficulty: "easy", Score: 100, Time: 30, Moves: 14, Date: day2},
+		{Name: "alice", Difficulty: "hard", Score: 150, Time: 60, Moves: 20, Date: day2},
+		{Name: "carol", Difficulty: "easy", Score: 80, Time: 30, Moves: 10, Date: day1},
+		{Name: "dave", Difficulty: "easy", Score: 100, Time: 40, Moves: 12, Date: day2},
+		{Name: "alice", Difficulty: "easy", Score: 500, Time: 10, Moves: 8, Date: day2, Daily: "2026-10-12"},
+		{Name: "bob", Difficulty: "easy", Score: 400, Time: 10, Moves: 8, Date: day1, Daily: "2026-10-10"},
+	} {
+		sc.GameID = fmt.Sprint("g", i)
+		if err := s.Add(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// ranks formats a page as name:rank pairs
+func ranks(page Page) string {
+	var out []string
+	for _, r := range page.Scores {
+		out = append(out, fmt.Sprintf("%s:%d", r.Name, r.Rank))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestMemoryStoreQuery(t *testing.T) {
+	s := testStore(t)
+	tests := []struct {
+		desc  string
+		q     Query
+		want  string
+		total int
+	}{
+		{"by score, ties by time then order added", Query{},
+			"alice:1 bob:2 alice:3 dave:4 carol:5", 5},
+		{"one difficulty", Query{Difficulty: "easy"},
+			"bob:1 alice:2 dave:3 carol:4", 4},
+		{"all difficulties", Query{Difficulty: "all", Unique: true},
+			"alice:1 bob:2 dave:3 carol:4", 4},
+		{"by time, ties by moves", Query{Sort: SortTime},
+			"carol:1 bob:2 alice:3 dave:4 alice:5", 5},
+		{"by moves, ties by time", Query{Sort: SortMoves},
+			"carol:1 alice:2 dave:3 bob:4 alice:5", 5},
+		{"offset and limit", Query{Offset: 1, Limit: 2},
+			"bob:2 alice:3", 5},
+		{"limit past the end", Query{Offset: 4, Limit: 10},
+			"carol:5", 5},
+		{"offset past the end", Query{Offset: 9}, "", 5},
+		{"since", Query{Since: since},
+			"alice:1 bob:2 dave:3", 3},
+		{"since, one difficulty", Query{Since: since, Difficulty: "easy"},
+			"bob:1 dave:2", 2},
+		{"since, windowed", Query{Since: since, Offset: 1, Limit: 1},
+			"bob:2", 3},
+		{"since, unique", Query{Since: since, Unique: true, Sort: SortTime},
+			"bob:1 dave:2 alice:3", 3},
+		{"daily challenge", Query{Daily: "2026-10-12"}, "alice:1", 1},
+		{"no scores", Query{Difficulty: "medium"}, "", 0},
+	}
+	for _, tt := range tests {
+		page, err := s.Query(tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ranks(page); got != tt.want || page.Total != tt.total {
+			t.Errorf("%s: got %q of %d, want %q of %d", tt.desc, got, page.Total, tt.want, tt.total)
+		}
+	}
+}
+
+func TestMemoryStoreRank(t *testing.T) {
+	s := testStore(t)
+	tests := []struct {
+		desc string
+		name string
+		q    Query
+		want int // 0 for ErrNotFound
+	}{
+		{"best score counts", "alice", Query{}, 1},
+		{"behind a tie", "dave", Query{Difficulty: "easy"}, 3},
+		{"unique", "dave", Query{Unique: true}, 3},
+		{"every score", "carol", Query{}, 5},
+		{"by moves", "alice", Query{Sort: SortMoves}, 2},
+		{"ignores the window", "carol", Query{Offset: 1, Limit: 1}, 5},
+		{"since", "dave", Query{Since: since}, 3},
+		{"no scores since", "alice", Query{Since: since, Difficulty: "easy"}, 0},
+		{"daily challenge", "bob", Query{Daily: "2026-10-10"}, 1},
+		{"not in the daily challenge", "alice", Query{Daily: "2026-10-10"}, 0},
+		{"unknown player", "erin", Query{}, 0},
+		{"no scores", "alice", Query{Difficulty: "medium"}, 0},
+	}
+	for _, tt := range tests {
+		r, err := s.Rank(tt.name, tt.q)
+		if tt.want == 0 {
+			if err != ErrNotFound {
+				t.Errorf("%s: got rank %d, %v, want %v", tt.desc, r.Rank, err, ErrNotFound)
+			}
+			continue
+		}
+		if err != nil || r.Rank != tt.want || r.Name != tt.name {
+			t.Errorf("%s: got %s at %d, %v, want %s at %d", tt.desc, r.Name, r.Rank, err, tt.name, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreNameCase(t *testing.T) {
+	s := NewMemoryStore()
+	for _, sc := range []models.Score{
+		score("Alice", "g1", 100),
+		score("alice", "g2", 300),
+		score("bob", "g3", 200),
+		score("ALICE", "g4", 50),
+	} {
+		if err := s.Add(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := s.Query(Query{Unique: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ranks(page); got != "alice:1 bob:2" {
+		t.Errorf("unique leaderboard %q, want one place for every case of alice", got)
+	}
+	page, _ = s.Query(Query{Unique: true, Since: day1})
+	if got := ranks(page); got != "alice:1 bob:2" {
+		t.Errorf("unique leaderboard since %v: %q", day1, got)
+	}
+	for _, q := range []Query{{}, {Since: day1}} {
+		if r, err := s.Rank("ALICE", q); err != nil || r.Name != "alice" || r.Rank != 1 {
+			t.Errorf("rank of ALICE with %+v: %s at %d, %v; want alice's best at 1", q, r.Name, r.Rank, err)
+		}
+	}
+}
+
+func TestMemoryStoreDuplicate(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.Add(score("alice", "g1", 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(score("bob", "g1", 200)); err != ErrDuplicate {
+		t.Fatalf("second score for g1: %v, want %v", err, ErrDuplicate)
+	}
+	// Scores without a game aren't checked
+	for range 2 {
+		if err := s.Add(score("carol", "", 50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if page, _ := s.Query(Query{}); names(page) != "alice carol carol" {
+		t.Fatalf("got %q", names(page))
+	}
+}
+
+func TestMemoryStoreDays(t *testing.T) {
+	s := testStore(t)
+	days, err := s.Days()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2026-10-12", "2026-10-10"}; !slices.Equal(days, want) {
+		t.Fatalf("got %v, want %v", days, want)
+	}
+}
+
+func TestMemoryStoreDailyOnce(t *testing.T) {
+	s := NewMemoryStore()
+	daily := func(name, gameID, playerID, difficulty, date string) models.Score {
+		sc := score(name, gameID, 100)
+		sc.PlayerID, sc.Difficulty, sc.Daily = playerID, difficulty, date
+		return sc
+	}
+	for _, sc := range []models.Score{
+		daily("alice", "g1", "", "easy", "2026-10-18"),
+		daily("bob", "g2", "p1", "easy", "2026-10-18"),
+		daily("alice", "g3", "", "hard", "2026-10-18"),
+		daily("alice", "g4", "", "easy", "2026-10-17"),
+		score("alice", "g5", 100),
+	} {
+		if err := s.Add(sc); err != nil {
+			t.Fatalf("adding %s: %v", sc.GameID, err)
+		}
+	}
+	for _, sc := range []models.Score{
+		daily("alice", "g6", "", "easy", "2026-10-18"),
+		daily("Alice", "g7", "", "easy", "2026-10-18"),
+		daily("carol", "g8", "p1", "easy", "2026-10-18"),
+	} {
+		if err := s.Add(sc); err != ErrDailyPlayed {
+			t.Errorf("adding %s: %v, want %v", sc.GameID, err, ErrDailyPlayed)
+		}
+	}
+}
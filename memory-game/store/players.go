@@ -0,0 +1,212 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"memory-game/models"
+	"os"
+	"slices"
+	"sync"
+)
+
+// ErrNoPlayer is returned for a player ID with no profile
+var ErrNoPlayer = errors.New("no such player")
+
+// PlayerStore keeps player profiles and how each of their games ended.
+// Implementations are safe for concurrent use.
+type PlayerStore interface {
+	// Create saves a new profile.
+	Create(player models.Player) error
+	// Player returns the profile with the given ID, or ErrNoPlayer.
+	Player(id string) (models.Player, error)
+	// AddGame records a game of a player's. Records are unique by GameID.
+	AddGame(record models.GameRecord) error
+	// Games returns the player's last limit games at difficulty, or at
+	// every difficulty if it is "", oldest first. A limit of 0 means all of
+	// them. It returns ErrNoPlayer if there is no profile.
+	Games(id, difficulty string, limit int) ([]models.GameRecord, error)
+	// StartDaily records that a player has been dealt the daily challenge
+	// board of date at difficulty. It returns ErrDailyPlayed if they have
+	// been dealt it before, or ErrNoPlayer if there is no profile.
+	StartDaily(id, date, difficulty string) error
+	// Close releases the store's resources.
+	Close() error
+}
+
+// PlayerLog is a PlayerStore that keeps profiles, game records and daily
+// challenge boards dealt in memory and, if opened with OpenPlayerLog,
+// appends them to a file with one JSON object per line: {"player": {...}},
+// {"game": {...}} or {"daily": {...}}
+type PlayerLog struct {
+	mu      sync.Mutex
+	lines   []playerLine // in the order they were added
+	players map[string]models.Player
+	games   map[string][]models.GameRecord // by player ID, oldest first
+	seen    map[string]bool                // game IDs recorded
+	dailies map[dailyBoard]bool            // daily challenge boards dealt
+	f       *os.File                       // nil if kept in memory only
+}
+
+type playerLine struct {
+	Player *models.Player     `json:"player,omitempty"`
+	Game   *models.GameRecord `json:"game,omitempty"`
+	Daily  *dailyBoard        `json:"daily,omitempty"`
+}
+
+// dailyBoard is a daily challenge board dealt to a player
+type dailyBoard struct {
+	PlayerID   string `json:"playerId"`
+	Date       string `json:"date"`
+	Difficulty string `json:"difficulty"`
+}
+
+// NewPlayerLog returns an empty PlayerLog kept in memory only
+func NewPlayerLog() *PlayerLog {
+	return &PlayerLog{
+		players: make(map[string]models.Player),
+		games:   make(map[string][]models.GameRecord),
+		seen:    make(map[string]bool),
+		dailies: make(map[dailyBoard]bool),
+	}
+}
+
+// OpenPlayerLog loads the profiles and records in the file at path, creating
+// it if needed, and appends new ones to it. Like OpenJSONLStore, it skips
+// bad or duplicate lines and compacts the file if there were any.
+func OpenPlayerLog(path string) (*PlayerLog, error) {
+	l := NewPlayerLog()
+	skipped, err := readJSONL(path, func(data []byte) error {
+		var line playerLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return err
+		}
+		return l.add(line)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d bad lines in %s; compacting", skipped, path)
+		if err := rewriteJSONL(path, l.lines); err != nil {
+			return nil, err
+		}
+	}
+	l.f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// check returns why line can't be added, if it can't. l.mu must be held.
+func (l *PlayerLog) check(line playerLine) error {
+	switch {
+	case line.Player != nil:
+		if _, ok := l.players[line.Player.ID]; ok || line.Player.ID == "" {
+			return ErrDuplicate
+		}
+	case line.Game != nil:
+		if _, ok := l.players[line.Game.PlayerID]; !ok {
+			return ErrNoPlayer
+		}
+		if l.seen[line.Game.GameID] {
+			return ErrDuplicate
+		}
+	case line.Daily != nil:
+		if _, ok := l.players[line.Daily.PlayerID]; !ok {
+			return ErrNoPlayer
+		}
+		if l.dailies[*line.Daily] {
+			return ErrDailyPlayed
+		}
+	default:
+		return errors.New("empty line")
+	}
+	return nil
+}
+
+// add keeps line if check allows it. l.mu must be held.
+func (l *PlayerLog) add(line playerLine) error {
+	if err := l.check(line); err != nil {
+		return err
+	}
+	switch {
+	case line.Player != nil:
+		l.players[line.Player.ID] = *line.Player
+	case line.Game != nil:
+		l.seen[line.Game.GameID] = true
+		l.games[line.Game.PlayerID] = append(l.games[line.Game.PlayerID], *line.Game)
+	default:
+		l.dailies[*line.Daily] = true
+	}
+	l.lines = append(l.lines, line)
+	return nil
+}
+
+// write appends line to the file, if there is one, then keeps it, so a
+// line reported saved survives a restart
+func (l *PlayerLog) write(line playerLine) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.check(line); err != nil {
+		return err
+	}
+	if l.f != nil {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err := l.f.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return l.add(line)
+}
+
+func (l *PlayerLog) Create(player models.Player) error {
+	return l.write(playerLine{Player: &player})
+}
+
+func (l *PlayerLog) Player(id string) (models.Player, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p, ok := l.players[id]
+	if !ok {
+		return models.Player{}, ErrNoPlayer
+	}
+	return p, nil
+}
+
+func (l *PlayerLog) AddGame(record models.GameRecord) error {
+	return l.write(playerLine{Game: &record})
+}
+
+func (l *PlayerLog) Games(id, difficulty string, limit int) ([]models.GameRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.players[id]; !ok {
+		return nil, ErrNoPlayer
+	}
+	// Collect the newest first, then put them back in order
+	games := []models.GameRecord{}
+	all := l.games[id]
+	for i := len(all) - 1; i >= 0 && (limit == 0 || len(games) < limit); i-- {
+		if difficulty == "" || all[i].Difficulty == difficulty {
+			games = append(games, all[i])
+		}
+	}
+	slices.Reverse(games)
+	return games, nil
+}
+
+func (l *PlayerLog) StartDaily(id, date, difficulty string) error {
+	return l.write(playerLine{Daily: &dailyBoard{PlayerID: id, Date: date, Difficulty: difficulty}})
+}
+
+func (l *PlayerLog) Close() error {
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"fmt"
+	"memory-game/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// record returns a game record of a player's
+func record(playerID, gameID, difficulty string, won bool) models.GameRecord {
+	return models.GameRecord{
+		PlayerID:   playerID,
+		GameID:     gameID,
+		Difficulty: difficulty,
+		Won:        won,
+		Date:       time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// gameIDs lists the IDs of records, in order
+func gameIDs(records []models.GameRecord) string {
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r.GameID)
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestPlayerLogGames(t *testing.T) {
+	l := NewPlayerLog()
+	for _, id := range []string{"p1", "p2"} {
+		if err := l.Create(models.Player{ID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, difficulty := range []string{"easy", "hard", "easy", "easy", "hard"} {
+		if err := l.AddGame(record("p1", fmt.Sprint("g", i), difficulty, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		id, difficulty string
+		limit          int
+		want           string
+	}{
+		{"p1", "", 0, "g0 g1 g2 g3 g4"},
+		{"p1", "", 2, "g3 g4"},
+		{"p1", "easy", 0, "g0 g2 g3"},
+		{"p1", "easy", 2, "g2 g3"},
+		{"p1", "hard", 10, "g1 g4"},
+		{"p1", "medium", 0, ""},
+		{"p2", "", 0, ""},
+	}
+	for _, tt := range tests {
+		games, err := l.Games(tt.id, tt.difficulty, tt.limit)
+		if err != nil || games == nil || gameIDs(games) != tt.want {
+			t.Errorf("Games(%s, %q, %d) = %q, %v; want %q", tt.id, tt.difficulty, tt.limit, gameIDs(games), err, tt.want)
+		}
+	}
+	if _, err := l.Games("nobody", "", 0); err != ErrNoPlayer {
+		t.Errorf("games of an unknown player: %v, want %v", err, ErrNoPlayer)
+	}
+}
+
+func TestPlayerLogRejects(t *testing.T) {
+	l := NewPlayerLog()
+	l.Create(models.Player{ID: "p1", Name: "alice"})
+	l.AddGame(record("p1", "g1", "easy", true))
+	l.StartDaily("p1", "2026-10-18", "easy")
+	tests := []struct {
+		desc string
+		err  error
+		want error
+	}{
+		{"profile ID taken", l.Create(models.Player{ID: "p1", Name: "bob"}), ErrDuplicate},
+		{"profile without an ID", l.Create(models.Player{Name: "bob"}), ErrDuplicate},
+		{"game recorded twice", l.AddGame(record("p1", "g1", "easy", false)), ErrDuplicate},
+		{"game of an unknown player", l.AddGame(record("p2", "g2", "easy", false)), ErrNoPlayer},
+		{"daily board dealt twice", l.StartDaily("p1", "2026-10-18", "easy"), ErrDailyPlayed},
+		{"daily board of an unknown player", l.StartDaily("p2", "2026-10-18", "easy"), ErrNoPlayer},
+		{"another day's board", l.StartDaily("p1", "2026-10-19", "easy"), nil},
+		{"another difficulty's board", l.StartDaily("p1", "2026-10-18", "hard"), nil},
+	}
+	for _, tt := range tests {
+		if tt.err != tt.want {
+			t.Errorf("%s: %v, want %v", tt.desc, tt.err, tt.want)
+		}
+	}
+}
+
+func TestOpenPlayerLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.jsonl")
+	l, err := OpenPlayerLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Create(models.Player{ID: "p1", Name: "alice", Token: "secret"})
+	l.AddGame(record("p1", "g1", "easy", true))
+	l.AddGame(record("p1", "g2", "hard", false))
+	l.StartDaily("p1", "2026-10-18", "easy")
+	l.Close()
+
+	// A duplicate, a record of an unknown player and a half written line
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	fmt.Fprintln(f, `{"game": {"playerId": "p1", "gameId": "g1", "difficulty": "easy"}}`)
+	fmt.Fprintln(f, `{"game": {"playerId": "p9", "gameId": "g3", "difficulty": "easy"}}`)
+	fmt.Fprint(f, `{"game": {"playerId": "p1", "ga`)
+	f.Close()
+
+	l, err = OpenPlayerLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := l.Player("p1")
+	if err != nil || p.Token != "secret" {
+		t.Fatalf("reloaded profile %+v, %v", p, err)
+	}
+	if games, _ := l.Games("p1", "", 0); gameIDs(games) != "g1 g2" || !games[0].Won {
+		t.Fatalf("reloaded games %+v", games)
+	}
+	if err := l.StartDaily("p1", "2026-10-18", "easy"); err != ErrDailyPlayed {
+		t.Fatalf("daily board dealt again after reloading: %v, want %v", err, ErrDailyPlayed)
+	}
+	l.AddGame(record("p1", "g4", "easy", true))
+	l.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 5 {
+		t.Fatalf("compacted file has %d lines, want 5:\n%s", lines, data)
+	}
+}
@@ -0,0 +1,69 @@
+// Package store keeps leaderboard scores.
+package store
+
+import (
+	"errors"
+	"memory-game/models"
+	"time"
+)
+
+// ErrDuplicate is returned by Add for a score whose game is already on the
+// leaderboard, or a match result already recorded
+var ErrDuplicate = errors.New("score for this game already submitted")
+
+// ErrDailyPlayed is returned by Add for a daily challenge score from a name
+// or player that already has a score for that day's board, and by
+// StartDaily for a board already dealt to the player
+var ErrDailyPlayed = errors.New("daily challenge already played")
+
+// ErrNotFound is returned by Rank for a player with no matching score
+var ErrNotFound = errors.New("no score for this player")
+
+// Orders the leaderboard can be sorted in
+const (
+	SortScore = "score" // highest score first
+	SortTime  = "time"  // fastest first
+	SortMoves = "moves" // fewest moves first
+)
+
+// Query selects part of the leaderboard
+type Query struct {
+	Difficulty string    // "" or "all" for every difficulty
+	Daily      string    // date of a daily challenge, or "" for regular games
+	Since      time.Time // if set, only scores submitted since then
+	Sort       string    // SortScore, SortTime or SortMoves; "" means SortScore
+	Unique     bool      // only each player's best score
+	Offset     int
+	Limit      int // 0 means no limit
+}
+
+// Ranked is a score and its place on the leaderboard, from 1
+type Ranked struct {
+	Rank int `json:"rank"`
+	models.Score
+}
+
+// Page is the part of the leaderboard a Query selected
+type Page struct {
+	Scores []Ranked `json:"scores"`
+	Total  int      `json:"total"` // scores matching the query, ignoring Offset and Limit
+}
+
+// ScoreStore keeps leaderboard scores. Implementations are safe for
+// concurrent use.
+type ScoreStore interface {
+	// Add saves a score. Scores with a GameID are unique by it, and each
+	// name and player gets one score per daily challenge board.
+	Add(score models.Score) error
+	// Query returns the scores q selects, best first.
+	Query(q Query) (Page, error)
+	// Rank returns the named player's best score and its rank among the
+	// scores q selects, ignoring q's Offset and Limit. It returns
+	// ErrNotFound if the player has no score.
+	Rank(name string, q Query) (Ranked, error)
+	// Days returns the dates of the daily challenges with scores, newest
+	// first.
+	Days() ([]string, error)
+	// Close releases the store's resources.
+	Close() error
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+var adminToken = flag.String("admin-token", "", "bearer token for the /admin API, which is disabled if empty (default $TICTACTOE_ADMIN_TOKEN)")
+
+// configuredAdminToken returns the -admin-token flag, or if it isn't set
+// the TICTACTOE_ADMIN_TOKEN environment variable. The variable isn't the
+// flag's default so that -h doesn't print the token.
+func configuredAdminToken() string {
+	if *adminToken != "" {
+		return *adminToken
+	}
+	return os.Getenv("TICTACTOE_ADMIN_TOKEN")
+}
+
+// RoomSummary is a room as listed by GET /admin/rooms.
+type RoomSummary struct {
+	ID         string        `json:"id"`
+	Players    []*RoomPlayer `json:"players"`
+	Status     string        `json:"status"`
+	Game       int           `json:"game"`
+	BestOf     int           `json:"bestOf"`
+	Moves      int           `json:"moves"`
+	Spectators int           `json:"spectators"`
+	StartedAt  time.Time     `json:"startedAt"`
+}
+
+// registerAdminHandlers adds the admin API to mux. Every endpoint requires
+// the configured token in an "Authorization: Bearer" header.
+func (s *Server) registerAdminHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/rooms", s.requireAdmin(s.handleAdminRooms))
+	mux.HandleFunc("/admin/rooms/", s.requireAdmin(s.handleAdminRoom))
+	mux.HandleFunc("/admin/queue", s.requireAdmin(s.handleAdminQueue))
+	mux.HandleFunc("/admin/players/", s.requireAdmin(s.handleAdminPlayer))
+	mux.HandleFunc("/admin/metrics", s.requireAdmin(s.handleAdminMetrics))
+	mux.HandleFunc("/admin/tournaments", s.requireAdmin(s.handleAdminTournaments))
+	mux.HandleFunc("/admin/tournaments/", s.requireAdmin(s.handleAdminTournament))
+}
+
+// requireAdmin wraps h so it is only called for requests carrying the admin
+// token.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + s.cfg.AdminToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tictactoe admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleAdminRooms serves GET /admin/rooms with a summary of every room.
+func (s *Server) handleAdminRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rooms, err := s.rooms.Rooms()
+	if err != nil {
+		log.Printf("Listing rooms: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	list := make([]RoomSummary, 0, len(rooms))
+	for _, room := range rooms {
+		list = append(list, RoomSummary{
+			ID:         room.ID,
+			Players:    room.Players,
+			Status:     room.Status,
+			Game:       room.GameNumber,
+			BestOf:     room.BestOf,
+			Moves:      len(room.Moves),
+			Spectators: len(room.Spectators),
+			StartedAt:  room.StartedAt,
+		})
+	}
+	writeJSON(w, list)
+}
+
+// handleAdminRoom serves GET /admin/rooms/{id} with the room's full state,
+// and DELETE /admin/rooms/{id} to close it.
+func (s *Server) handleAdminRoom(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/admin/rooms/")
+	switch r.Method {
+	case http.MethodGet:
+		room, err := s.rooms.Get(id)
+		if err == ErrRoomNotFound {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Reading room %s: %v", id, err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, room)
+	case http.MethodDelete:
+		if err := s.closeRoom(id, "The room was closed by an administrator"); err == ErrRoomNotFound {
+			http.Error(w, "Room not found", http.StatusNotFound)
+		} else if err != nil {
+			log.Printf("Closing room %s: %v", id, err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminQueue serves GET /admin/queue with the players waiting for a
+// match.
+func (s *Server) handleAdminQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	queue, err := s.rooms.Queue()
+	if err != nil {
+		log.Printf("Reading queue: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if queue == nil {
+		queue = []QueueEntry{}
+	}
+	writeJSON(w, queue)
+}
+
+// handleAdminPlayer serves POST /admin/players/{id}/kick, which disconnects
+// the player from whichever node they are connected to. A player kicked
+// mid-game forfeits it.
+func (s *Server) handleAdminPlayer(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/players/"), "/")
+	if id == "" || action != "kick" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	log.Printf("Kicking player %s", id)
+	s.publish(id, &protocol.Kicked{Message: "You were disconnected by an administrator"})
+	// The player may be connected to another node, so all we know is that
+	// the request was sent.
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleAdminMetrics serves GET /admin/metrics. Connected players and the
+// game and queue wait figures are for this node only; room and waiting
+// player counts cover every node sharing its RoomStore.
+func (s *Server) handleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var snap MetricsSnapshot
+	s.metrics.snapshot(&snap)
+
+	s.mu.Lock()
+	snap.ConnectedPlayers = len(s.players)
+	s.mu.Unlock()
+
+	rooms, err := s.rooms.Rooms()
+	if err != nil {
+		log.Printf("Listing rooms: %v", err)
+	}
+	snap.ActiveRooms = len(rooms)
+	queue, err := s.rooms.Queue()
+	if err != nil {
+		log.Printf("Reading queue: %v", err)
+	}
+	snap.WaitingPlayers = len(queue)
+
+	writeJSON(w, snap)
+}
+
+// closeRoom ends a room without a result, telling its players and
+// spectators why, and deletes it. An unfinished tournament match is
+// scheduled again.
+func (s *Server) closeRoom(id, reason string) error {
+	var replay *Room
+	err := s.rooms.Update(id, func(room *Room) error {
+		s.stopClock(room)
+		s.publishRoom(room, &protocol.RoomClosed{RoomID: room.ID, Message: reason})
+		if room.TournamentID != "" && !room.MatchSettled {
+			replay = room
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Room %s closed: %s", id, reason)
+	if err := s.rooms.Delete(id); err != nil {
+		return err
+	}
+	if replay != nil {
+		s.replayMatch(replay.TournamentID, replay.MatchID, replay.ID)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tictactoe/protocol"
+)
+
+// adminRequest makes an admin API request to the node at base, decoding a
+// JSON response into v if it is not nil, and returns the status code.
+func adminRequest(t *testing.T, method, url, token string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAdminAPI(t *testing.T) {
+	const token = "secret"
+	wsURL := startNodeConfig(t, Config{BestOf: 1, AdminToken: token}, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+	base := "http" + strings.TrimSuffix(strings.TrimPrefix(wsURL, "ws"), "/ws") + "/admin"
+
+	for _, tok := range []string{"", "wrong"} {
+		if code := adminRequest(t, "GET", base+"/rooms", tok, nil); code != http.StatusUnauthorized {
+			t.Fatalf("token %q: status %d, want 401", tok, code)
+		}
+	}
+
+	alice := dialPlayer(t, wsURL)
+	expect[*protocol.Waiting](alice)
+	bob := dialPlayer(t, wsURL)
+	room := expect[*protocol.Matched](alice).RoomID
+	expect[*protocol.Matched](bob)
+	carol := dialPlayer(t, wsURL)
+	carolID := expect[*protocol.Connected](carol).PlayerID
+	expect[*protocol.Waiting](carol)
+
+	var rooms []RoomSummary
+	if code := adminRequest(t, "GET", base+"/rooms", token, &rooms); code != http.StatusOK || len(rooms) != 1 || rooms[0].ID != room {
+		t.Fatalf("rooms: status %d, %+v", code, rooms)
+	}
+	var queue []QueueEntry
+	if code := adminRequest(t, "GET", base+"/queue", token, &queue); code != http.StatusOK || len(queue) != 1 || queue[0].PlayerID != carolID {
+		t.Fatalf("queue: status %d, %+v", code, queue)
+	}
+	var got Room
+	if code := adminRequest(t, "GET", base+"/rooms/"+room, token, &got); code != http.StatusOK || len(got.Players) != 2 {
+		t.Fatalf("room: status %d, %+v", code, got)
+	}
+	if code := adminRequest(t, "GET", base+"/rooms/room_none", token, nil); code != http.StatusNotFound {
+		t.Fatalf("unknown room: status %d", code)
+	}
+
+	var snap MetricsSnapshot
+	adminRequest(t, "GET", base+"/metrics", token, &snap)
+	if snap.ConnectedPlayers != 3 || snap.WaitingPlayers != 1 || snap.ActiveRooms != 1 || snap.GamesStarted != 1 {
+		t.Fatalf("metrics: %+v", snap)
+	}
+
+	if code := adminRequest(t, "DELETE", base+"/rooms/"+room, token, nil); code != http.StatusNoContent {
+		t.Fatalf("closing room: status %d", code)
+	}
+	expect[*protocol.RoomClosed](alice)
+	expect[*protocol.RoomClosed](bob)
+	if code := adminRequest(t, "GET", base+"/rooms/"+room, token, nil); code != http.StatusNotFound {
+		t.Fatalf("closed room: status %d", code)
+	}
+
+	if code := adminRequest(t, "POST", base+"/players/"+carolID+"/kick", token, nil); code != http.StatusAccepted {
+		t.Fatalf("kick: status %d", code)
+	}
+	expect[*protocol.Kicked](carol)
+	var env protocol.Envelope
+	if err := carol.conn.ReadJSON(&env); err == nil {
+		t.Fatalf("kicked player still connected, got %q", env.Type)
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	wsURL := startNode(t, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+	base := "http" + strings.TrimSuffix(strings.TrimPrefix(wsURL, "ws"), "/ws")
+	if code := adminRequest(t, "GET", base+"/admin/rooms", "", nil); code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", code)
+	}
+}
+
+func TestAdminTokenFromEnv(t *testing.T) {
+	t.Setenv("TICTACTOE_ADMIN_TOKEN", "from-env")
+	if def := flag.Lookup("admin-token").DefValue; def != "" {
+		t.Fatalf("-admin-token default is %q, want empty so -h doesn't show it", def)
+	}
+	if got := configuredAdminToken(); got != "from-env" {
+		t.Fatalf("without the flag, token is %q, want from-env", got)
+	}
+	*adminToken = "from-flag"
+	t.Cleanup(func() { *adminToken = "" })
+	if got := configuredAdminToken(); got != "from-flag" {
+		t.Fatalf("with the flag, token is %q, want from-flag", got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tictactoe/protocol"
+)
+
+const (
+	// maxChatLength is the longest chat message accepted, in characters.
+	maxChatLength = 200
+	// chatHistory is how many recent chat messages a room keeps for
+	// players and spectators who join later.
+	chatHistory = 20
+
+	// Each player may send chatLimit messages and reactionLimit reactions
+	// in any rateWindow.
+	chatLimit     = 5
+	reactionLimit = 10
+	rateWindow    = 10 * time.Second
+)
+
+// reactions are the quick reactions players can send, by name.
+var reactions = map[string]string{
+	"thumbs_up": "👍",
+	"laugh":     "😂",
+	"wow":       "😮",
+	"sad":       "😢",
+	"fire":      "🔥",
+	"clap":      "👏",
+}
+
+// A ChatFilter cleans up chat text before it is delivered, for example by
+// masking profanity.
+type ChatFilter interface {
+	Filter(text string) string
+}
+
+// WordFilter is a ChatFilter that masks whole words from a list,
+// ignoring case.
+type WordFilter struct {
+	re *regexp.Regexp
+}
+
+// NewWordFilter returns a WordFilter masking the given words.
+func NewWordFilter(words ...string) *WordFilter {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	return &WordFilter{re: regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+func (f *WordFilter) Filter(text string) string {
+	return f.re.ReplaceAllStringFunc(text, func(w string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(w))
+	})
+}
+
+// defaultBannedWords is the word list main gives the default filter.
+var defaultBannedWords = []string{"damn", "hell", "crap", "shit", "fuck", "bastard", "bitch", "asshole"}
+
+// allowRate reports whether another event may happen now given the times of
+// earlier ones, allowing limit events per rateWindow. It returns the times
+// still inside the window, including now if the event is allowed.
+func allowRate(sent []time.Time, now time.Time, limit int) ([]time.Time, bool) {
+	recent := sent[:0]
+	for _, t := range sent {
+		if now.Sub(t) < rateWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		return recent, false
+	}
+	return append(recent, now), true
+}
+
+// cleanChat trims text and removes control characters. It returns "" if
+// nothing is left.
+func cleanChat(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.TrimSpace(text)
+}
+
+// handleChat delivers a chat message from a player or spectator to everyone
+// in the room.
+func (s *Server) handleChat(player *Player, room *Room, msg *protocol.Chat) {
+	text := cleanChat(msg.Text)
+	if text == "" {
+		return
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		sendError(player.Client, protocol.Invalid, "Message is too long")
+		return
+	}
+
+	if room.ChatSent == nil {
+		room.ChatSent = make(map[string][]time.Time)
+	}
+	now := time.Now()
+	sent, ok := allowRate(room.ChatSent[player.ID], now, chatLimit)
+	room.ChatSent[player.ID] = sent
+	if !ok {
+		sendError(player.Client, protocol.RateLimited, "You're sending messages too quickly")
+		return
+	}
+
+	if s.cfg.ChatFilter != nil {
+		text = s.cfg.ChatFilter.Filter(text)
+	}
+	chat := protocol.ChatMessage{From: player.ID, Name: player.Name, Text: text, SentAt: now}
+	room.Chat = append(room.Chat, chat)
+	if len(room.Chat) > chatHistory {
+		room.Chat = room.Chat[len(room.Chat)-chatHistory:]
+	}
+
+	s.publishRoom(room, &protocol.ChatPosted{RoomID: room.ID, ChatMessage: chat})
+}
+
+// handleReaction relays a quick reaction. Reactions are not kept in the
+// room's chat history.
+func (s *Server) handleReaction(player *Player, room *Room, msg *protocol.Reaction) {
+	if _, ok := reactions[msg.Reaction]; !ok {
+		sendError(player.Client, protocol.Invalid, "Unknown reaction")
+		return
+	}
+
+	if room.ReactionSent == nil {
+		room.ReactionSent = make(map[string][]time.Time)
+	}
+	sent, ok := allowRate(room.ReactionSent[player.ID], time.Now(), reactionLimit)
+	room.ReactionSent[player.ID] = sent
+	if !ok {
+		return // Excess reactions are dropped silently.
+	}
+
+	s.publishRoom(room, &protocol.ReactionSent{
+		RoomID:   room.ID,
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Reaction: msg.Reaction,
+	})
+}
+
+// publishRoom sends msg to every player and spectator in room.
+func (s *Server) publishRoom(room *Room, msg protocol.Payload) {
+	for _, p := range room.Players {
+		s.publish(p.ID, msg)
+	}
+	for _, id := range room.Spectators {
+		s.publish(id, msg)
+	}
+}
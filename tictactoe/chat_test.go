@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+func TestWordFilter(t *testing.T) {
+	f := NewWordFilter("darn", "heck")
+	tests := []struct {
+		in, want string
+	}{
+		{"good game", "good game"},
+		{"darn it", "**** it"},
+		{"DARN, what the Heck", "****, what the ****"},
+		{"darning socks", "darning socks"},
+	}
+	for _, tt := range tests {
+		if got := f.Filter(tt.in); got != tt.want {
+			t.Errorf("Filter(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAllowRate(t *testing.T) {
+	start := time.Now()
+	var sent []time.Time
+	for i := 0; i < 3; i++ {
+		var ok bool
+		if sent, ok = allowRate(sent, start, 3); !ok {
+			t.Fatalf("event %d refused", i)
+		}
+	}
+	if _, ok := allowRate(sent, start.Add(time.Second), 3); ok {
+		t.Fatal("fourth event in window allowed")
+	}
+	if _, ok := allowRate(sent, start.Add(rateWindow), 3); !ok {
+		t.Fatal("event after window refused")
+	}
+}
+
+func TestChat(t *testing.T) {
+	url := startNode(t, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+
+	alice := dialPlayer(t, url+"?name=alice&secret=alice-secret")
+	expect[*protocol.Waiting](alice)
+	bob := dialPlayer(t, url)
+	room := expect[*protocol.Matched](alice).RoomID
+	expect[*protocol.Matched](bob)
+
+	alice.send(&protocol.Chat{Text: "  good luck  "})
+	for _, p := range []*testPlayer{alice, bob} {
+		msg := expect[*protocol.ChatPosted](p)
+		if msg.Text != "good luck" || msg.Name != "alice" {
+			t.Fatalf("chat: %+v", msg)
+		}
+	}
+
+	// A spectator sees recent chat on joining and can take part.
+	carol := dialPlayer(t, url+"?watch="+room)
+	if msg := expect[*protocol.Spectating](carol); len(msg.Chat) != 1 || msg.Chat[0].Text != "good luck" {
+		t.Fatalf("spectating: chat %+v", msg.Chat)
+	}
+	carol.send(&protocol.Reaction{Reaction: "clap"})
+	for _, p := range []*testPlayer{alice, bob, carol} {
+		if msg := expect[*protocol.ReactionSent](p); msg.Reaction != "clap" {
+			t.Fatalf("reaction %q, want clap", msg.Reaction)
+		}
+	}
+
+	// Spectators follow the game but can't play.
+	alice.send(&protocol.Move{Row: 1, Col: 1})
+	if msg := expect[*protocol.Update](carol); msg.Board[1][1] != "X" || msg.Status != "O's turn" {
+		t.Fatalf("spectator update: board %v, status %q", msg.Board, msg.Status)
+	}
+	carol.send(&protocol.Move{Row: 0, Col: 0})
+	if msg := expect[*protocol.Error](carol); msg.Code != protocol.Forbidden {
+		t.Fatalf("spectator move: error %q", msg.Code)
+	}
+
+	bob.send(&protocol.Chat{Text: strings.Repeat("a", maxChatLength+1)})
+	if msg := expect[*protocol.Error](bob); msg.Code != protocol.Invalid {
+		t.Fatalf("long chat: error %q", msg.Code)
+	}
+	for i := 0; i < chatLimit; i++ {
+		bob.send(&protocol.Chat{Text: "spam"})
+		expect[*protocol.ChatPosted](bob)
+	}
+	bob.send(&protocol.Chat{Text: "spam"})
+	if msg := expect[*protocol.Error](bob); msg.Code != protocol.RateLimited {
+		t.Fatalf("rate limit: error %q", msg.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"tictactoe/protocol"
+)
+
+const (
+	// writeWait is how long a single write may take.
+	writeWait = 10 * time.Second
+	// pongWait is how long to wait for any message, including a pong,
+	// before treating the peer as dead.
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait so a healthy peer always has
+	// time to answer.
+	pingPeriod = pongWait * 9 / 10
+	// defaultMaxMessageSize is the largest message accepted from a peer
+	// unless Config.MaxMessageSize says otherwise.
+	defaultMaxMessageSize = 4096
+	// sendBufferSize is how many outbound messages may be queued for a
+	// client before it is considered too slow and disconnected.
+	sendBufferSize = 32
+)
+
+// Client owns a websocket connection. gorilla/websocket allows only one
+// concurrent writer, so every outbound message goes through the send
+// channel to the client's own writer goroutine; any goroutine may call Send.
+// Reads happen on the goroutine serving the connection.
+type Client struct {
+	conn *websocket.Conn
+	send chan protocol.Envelope
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// drain is closed by SendAndClose to have writePump close the client
+	// once the send buffer is empty.
+	drain     chan struct{}
+	drainOnce sync.Once
+}
+
+// newClient wraps conn and configures its keepalive and the largest
+// message, in bytes, it will read, or defaultMaxMessageSize if readLimit is
+// zero. The caller must start writePump.
+func newClient(conn *websocket.Conn, readLimit int64) *Client {
+	c := &Client{
+		conn:  conn,
+		send:  make(chan protocol.Envelope, sendBufferSize),
+		done:  make(chan struct{}),
+		drain: make(chan struct{}),
+	}
+	if readLimit <= 0 {
+		readLimit = defaultMaxMessageSize
+	}
+	conn.SetReadLimit(readLimit)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	return c
+}
+
+// Send queues msg for delivery without blocking. A client whose buffer is
+// full is disconnected rather than allowed to stall the game for everyone.
+func (c *Client) Send(msg protocol.Envelope) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		log.Printf("Client %s is too slow, disconnecting", c.conn.RemoteAddr())
+		c.Close()
+	}
+}
+
+// ReadMessage returns the next message from the peer, undecoded, extending
+// the read deadline on success.
+func (c *Client) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return data, c.conn.SetReadDeadline(time.Now().Add(pongWait))
+}
+
+// SendAndClose queues msg like Send and closes the client once it and
+// every message queued before it have been written.
+func (c *Client) SendAndClose(msg protocol.Envelope) {
+	c.Send(msg)
+	c.drainOnce.Do(func() { close(c.drain) })
+}
+
+// Reject writes msg to the peer and closes the connection. It must be called
+// instead of starting writePump.
+func (c *Client) Reject(msg protocol.Payload) {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	c.conn.WriteJSON(protocol.Encode(msg))
+	c.Close()
+}
+
+// Close shuts the connection down. The reader sees an error and runs its
+// disconnect handling. Close may be called more than once.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// writePump writes queued messages and periodic pings until the client is
+// closed or a write fails.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			if !c.write(msg) {
+				return
+			}
+		case <-c.drain:
+			for {
+				select {
+				case msg := <-c.send:
+					if !c.write(msg) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// write writes one message and reports whether it succeeded.
+func (c *Client) write(msg protocol.Envelope) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Printf("Write error: %v", err)
+		return false
+	}
+	return true
+}
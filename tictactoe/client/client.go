@@ -0,0 +1,278 @@
+// Package client is a Go client for the tictactoe server's websocket
+// protocol, for bots, tests and load generators.
+//
+// A typical game:
+//
+//	c, err := client.Dial(ctx, "ws://localhost:8080/ws", nil)
+//	...
+//	m, err := c.WaitMatch(ctx)
+//	if m.Symbol == m.Turn {
+//		err = c.Move(1, 1)
+//	}
+//	u, err := c.WaitUpdate(ctx)
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"tictactoe/protocol"
+)
+
+// Options configure a connection. The zero value connects an anonymous
+// player.
+type Options struct {
+	// Name gives the player a persistent rating. The first connection with
+	// a name claims it with Secret, which later connections must repeat.
+	Name   string
+	Secret string
+	// Watch, if set, is the ID of a room to watch instead of joining the
+	// queue.
+	Watch string
+	// Tournament, if set, is the ID of a tournament to play in instead of
+	// joining the queue. It requires a Name.
+	Tournament string
+	// Dialer is used to open the connection, or websocket.DefaultDialer if
+	// nil.
+	Dialer *websocket.Dialer
+	// Header is sent with the opening handshake, for example to set Origin.
+	Header http.Header
+}
+
+// Conn is a connection to the server. Its methods may be called from any
+// goroutine, but messages are delivered in order to a single reader.
+type Conn struct {
+	// PlayerID, Name and Rating are from the server's "connected" message.
+	PlayerID string
+	Name     string
+	Rating   int
+
+	ws *websocket.Conn
+
+	writeMu sync.Mutex
+
+	msgs chan protocol.Payload
+	done chan struct{} // closed when the read loop stops
+	err  error         // why the read loop stopped; valid after done is closed
+
+	closing   chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// ErrClosed is returned by a Conn once the connection has closed.
+var ErrClosed = errors.New("client: connection closed")
+
+// Dial connects to the server's websocket endpoint at rawURL, such as
+// "ws://localhost:8080/ws", and waits for the server to accept the
+// connection.
+func Dial(ctx context.Context, rawURL string, opts *Options) (*Conn, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("protocol", strconv.Itoa(protocol.Version))
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+		q.Set("secret", opts.Secret)
+	}
+	if opts.Watch != "" {
+		q.Set("watch", opts.Watch)
+	}
+	if opts.Tournament != "" {
+		q.Set("tournament", opts.Tournament)
+	}
+	u.RawQuery = q.Encode()
+
+	dialer := opts.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	ws, resp, err := dialer.DialContext(ctx, u.String(), opts.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("client: dialing %s: %v (%s)", rawURL, err, resp.Status)
+		}
+		return nil, fmt.Errorf("client: dialing %s: %v", rawURL, err)
+	}
+
+	c := &Conn{
+		ws:      ws,
+		msgs:    make(chan protocol.Payload, 64),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	go c.readLoop()
+
+	connected, err := Wait[*protocol.Connected](ctx, c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if connected.Protocol != protocol.Version {
+		c.Close()
+		return nil, fmt.Errorf("client: server speaks protocol %d, want %d", connected.Protocol, protocol.Version)
+	}
+	c.PlayerID, c.Name, c.Rating = connected.PlayerID, connected.Name, connected.Rating
+	return c, nil
+}
+
+// readLoop decodes messages from the server until the connection fails.
+func (c *Conn) readLoop() {
+	defer close(c.done)
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		msg, err := protocol.DecodeServer(data)
+		var perr *protocol.Error
+		if errors.As(err, &perr) && perr.Code == protocol.UnknownType {
+			// Sent by a newer server; skip it.
+			continue
+		}
+		if err != nil {
+			c.err = fmt.Errorf("client: decoding message: %v", err)
+			return
+		}
+		select {
+		case c.msgs <- msg:
+		case <-c.closing:
+			return
+		}
+	}
+}
+
+// Next returns the next message from the server.
+func (c *Conn) Next(ctx context.Context) (protocol.Payload, error) {
+	select {
+	case msg := <-c.msgs:
+		return msg, nil
+	default:
+	}
+	select {
+	case msg := <-c.msgs:
+		return msg, nil
+	case <-c.done:
+		// Deliver anything read before the connection failed.
+		select {
+		case msg := <-c.msgs:
+			return msg, nil
+		default:
+		}
+		if c.err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrClosed, c.err)
+		}
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Wait returns the next message of type T, skipping others. An error
+// message from the server is returned as a *protocol.Error unless T is
+// *protocol.Error.
+func Wait[T protocol.Payload](ctx context.Context, c *Conn) (T, error) {
+	var zero T
+	for {
+		msg, err := c.Next(ctx)
+		if err != nil {
+			return zero, err
+		}
+		if m, ok := msg.(T); ok {
+			return m, nil
+		}
+		if e, ok := msg.(*protocol.Error); ok {
+			return zero, e
+		}
+	}
+}
+
+// WaitMatch waits for a game to start.
+func (c *Conn) WaitMatch(ctx context.Context) (*protocol.Matched, error) {
+	return Wait[*protocol.Matched](ctx, c)
+}
+
+// WaitUpdate waits for the next state of the game.
+func (c *Conn) WaitUpdate(ctx context.Context) (*protocol.Update, error) {
+	return Wait[*protocol.Update](ctx, c)
+}
+
+// Send sends a message to the server.
+func (c *Conn) Send(msg protocol.Payload) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteJSON(protocol.Encode(msg))
+}
+
+// Move places the player's symbol at row, col.
+func (c *Conn) Move(row, col int) error {
+	return c.Send(&protocol.Move{Row: row, Col: col})
+}
+
+// Rematch asks for another game once the current one has finished.
+func (c *Conn) Rematch() error {
+	return c.Send(&protocol.Rematch{})
+}
+
+// OfferDraw offers the opponent a draw.
+func (c *Conn) OfferDraw() error {
+	return c.Send(&protocol.OfferDraw{})
+}
+
+// RequestUndo asks the opponent to let the player take back their last move.
+func (c *Conn) RequestUndo() error {
+	return c.Send(&protocol.RequestUndo{})
+}
+
+// AcceptOffer accepts the opponent's pending draw offer or undo request.
+func (c *Conn) AcceptOffer() error {
+	return c.Send(&protocol.AcceptOffer{})
+}
+
+// DeclineOffer declines the opponent's pending draw offer or undo request.
+func (c *Conn) DeclineOffer() error {
+	return c.Send(&protocol.DeclineOffer{})
+}
+
+// Resign concedes the current game.
+func (c *Conn) Resign() error {
+	return c.Send(&protocol.Resign{})
+}
+
+// Chat posts a message to the room.
+func (c *Conn) Chat(text string) error {
+	return c.Send(&protocol.Chat{Text: text})
+}
+
+// React sends a quick reaction, such as "thumbs_up", to the room.
+func (c *Conn) React(reaction string) error {
+	return c.Send(&protocol.Reaction{Reaction: reaction})
+}
+
+// Close closes the connection. The server treats it as the player leaving.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		c.writeMu.Lock()
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		c.writeMu.Unlock()
+		err = c.ws.Close()
+		<-c.done
+	})
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"tictactoe/protocol"
+)
+
+// dialTestClient returns a server-side Client connected to a websocket
+// peer. writePump is not started.
+func dialTestClient(t *testing.T) (*Client, *websocket.Conn) {
+	t.Helper()
+	clients := make(chan *Client, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		clients <- newClient(conn, 0)
+	}))
+	t.Cleanup(srv.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { peer.Close() })
+	c := <-clients
+	t.Cleanup(c.Close)
+	return c, peer
+}
+
+func TestClientDeliversInOrder(t *testing.T) {
+	c, peer := dialTestClient(t)
+	go c.writePump()
+
+	for i := 0; i < 10; i++ {
+		c.Send(protocol.Encode(&protocol.Waiting{Message: strconv.Itoa(i)}))
+	}
+	peer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 10; i++ {
+		var env protocol.Envelope
+		if err := peer.ReadJSON(&env); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf(`{"message":"%d"}`, i); string(env.Payload) != want {
+			t.Fatalf("message %d has payload %s", i, env.Payload)
+		}
+	}
+}
+
+func TestClientSlowConsumerDisconnected(t *testing.T) {
+	c, _ := dialTestClient(t)
+
+	// Without a writer draining the buffer, one message too many must close
+	// the client instead of blocking the sender.
+	for i := 0; i <= sendBufferSize; i++ {
+		c.Send(protocol.Encode(&protocol.Waiting{}))
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("client still open after overflowing its send buffer")
+	}
+
+	// Sending to a closed client is a no-op.
+	c.Send(protocol.Encode(&protocol.Waiting{}))
+}
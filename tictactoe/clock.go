@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+// Clock settings applied to newly created rooms. Zero disables a limit.
+var (
+	moveTime = flag.Duration("move-time", 30*time.Second, "time allowed per move (0 for no limit)")
+	gameTime = flag.Duration("game-time", 5*time.Minute, "total time each player has for a game (0 for no limit)")
+)
+
+// resetClocks gives both symbols a full game clock and starts the clock for
+// the player to move.
+func (s *Server) resetClocks(room *Room) {
+	room.Clocks = map[string]time.Duration{"X": room.GameTime, "O": room.GameTime}
+	s.startClock(room)
+}
+
+// startClock starts timing the current turn, replacing any running timer.
+func (s *Server) startClock(room *Room) {
+	s.stopClock(room)
+	room.TurnStarted = time.Now()
+
+	limit := turnLimit(room)
+	if limit <= 0 {
+		return
+	}
+	id, seq := room.ID, room.ClockSeq
+	s.startTimer(id, limit, func() {
+		s.handleTimeout(id, seq)
+	})
+}
+
+// stopClock cancels the running turn timer. A timer that is already firing,
+// or that another node started, sees a stale sequence number and does
+// nothing.
+func (s *Server) stopClock(room *Room) {
+	room.ClockSeq++
+	s.stopTimer(room.ID)
+}
+
+// chargeClock deducts the time spent on the current turn from symbol's game
+// clock.
+func chargeClock(room *Room, symbol string) {
+	if room.GameTime <= 0 {
+		return
+	}
+	room.Clocks[symbol] -= time.Since(room.TurnStarted)
+	if room.Clocks[symbol] < 0 {
+		room.Clocks[symbol] = 0
+	}
+}
+
+// turnLimit reports how long the player to move has left, from the start of
+// the turn, before losing on time. It is zero if the room has no clocks.
+func turnLimit(room *Room) time.Duration {
+	var limit time.Duration
+	if room.MoveTime > 0 {
+		limit = room.MoveTime
+	}
+	if room.GameTime > 0 {
+		if left := room.Clocks[room.Turn]; limit == 0 || left < limit {
+			limit = left
+		}
+	}
+	return limit
+}
+
+// clockState returns the time remaining for the current move and on each
+// symbol's game clock as of now.
+func clockState(room *Room) protocol.Clock {
+	var clock protocol.Clock
+	if room.Status != "playing" {
+		return clock
+	}
+	elapsed := time.Since(room.TurnStarted)
+
+	if limit := turnLimit(room); limit > 0 {
+		clock.MoveTimeLeft = max(limit-elapsed, 0).Milliseconds()
+	}
+	if room.GameTime > 0 {
+		clock.Clocks = make(map[string]int64, len(room.Clocks))
+		for symbol, left := range room.Clocks {
+			if symbol == room.Turn {
+				left -= elapsed
+			}
+			clock.Clocks[symbol] = max(left, 0).Milliseconds()
+		}
+	}
+	return clock
+}
+
+// errStaleTimer aborts a RoomStore update made by a timer for a turn that
+// has already ended.
+var errStaleTimer = errors.New("stale timer")
+
+// handleTimeout ends the game as a loss for the player who let their clock
+// run out. seq identifies the turn the timer was started for.
+func (s *Server) handleTimeout(roomID string, seq int) {
+	err := s.rooms.Update(roomID, func(room *Room) error {
+		if room.Status != "playing" || seq != room.ClockSeq {
+			return errStaleTimer
+		}
+
+		loser := room.Turn
+		chargeClock(room, loser)
+		room.Forfeit(loser)
+		s.finishGame(room, "timeout")
+
+		log.Printf("Room %s: %s ran out of time", room.ID, loser)
+
+		s.broadcastUpdate(room, "timeout")
+		return nil
+	})
+	if err != nil && err != errStaleTimer && err != ErrRoomNotFound {
+		log.Printf("Timing out room %s: %v", roomID, err)
+	}
+}
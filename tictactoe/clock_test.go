@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+// startClockGame matches two players on a node with the given clocks.
+func startClockGame(t *testing.T, moveTime, gameTime time.Duration) (x, o *testPlayer, mx, mo *protocol.Matched) {
+	t.Helper()
+	cfg := Config{BestOf: 1, MoveTime: moveTime, GameTime: gameTime}
+	url := startNodeConfig(t, cfg, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+	x = dialPlayer(t, url)
+	expect[*protocol.Waiting](x)
+	o = dialPlayer(t, url)
+	return x, o, expect[*protocol.Matched](x), expect[*protocol.Matched](o)
+}
+
+func TestMoveClock(t *testing.T) {
+	const moveTime = 300 * time.Millisecond
+	x, o, mx, mo := startClockGame(t, moveTime, 0)
+	for _, m := range []*protocol.Matched{mx, mo} {
+		if m.MoveTimeLeft <= 0 || m.MoveTimeLeft > moveTime.Milliseconds() || m.Clocks != nil {
+			t.Fatalf("clock at the start: %+v", m.Clock)
+		}
+	}
+
+	// Each move restarts the move clock for the other player, and both
+	// players are told.
+	x.send(&protocol.Move{Row: 1, Col: 1})
+	for _, p := range []*testPlayer{x, o} {
+		u := expect[*protocol.Update](p)
+		if u.Turn != "O" || u.MoveTimeLeft <= 0 || u.MoveTimeLeft > moveTime.Milliseconds() {
+			t.Fatalf("after X moved: turn %q, clock %+v", u.Turn, u.Clock)
+		}
+	}
+
+	// O never moves and loses on time.
+	for _, p := range []*testPlayer{x, o} {
+		u := expect[*protocol.Update](p)
+		if u.Winner != "X" || u.Reason != "timeout" || u.MoveTimeLeft != 0 {
+			t.Fatalf("after O's move time: winner %q, reason %q, clock %+v", u.Winner, u.Reason, u.Clock)
+		}
+	}
+}
+
+func TestGameClock(t *testing.T) {
+	const gameTime = 400 * time.Millisecond
+	x, o, mx, _ := startClockGame(t, 0, gameTime)
+	// X's clock is already running.
+	if left := mx.Clocks["X"]; left <= 0 || left > gameTime.Milliseconds() || mx.Clocks["O"] != gameTime.Milliseconds() {
+		t.Fatalf("clocks at the start: %v", mx.Clocks)
+	}
+
+	// Only the player who moved is charged for their turn.
+	time.Sleep(100 * time.Millisecond)
+	x.send(&protocol.Move{Row: 0, Col: 0})
+	for _, p := range []*testPlayer{x, o} {
+		u := expect[*protocol.Update](p)
+		if left := u.Clocks["X"]; left <= 0 || left > gameTime.Milliseconds()-100 {
+			t.Fatalf("X's clock after a 100ms move: %v", u.Clocks)
+		}
+		if left := u.Clocks["O"]; left <= gameTime.Milliseconds()-50 || left > gameTime.Milliseconds() {
+			t.Fatalf("O's clock ran on X's turn: %v", u.Clocks)
+		}
+	}
+
+	o.send(&protocol.Move{Row: 1, Col: 1})
+	expect[*protocol.Update](x)
+	expect[*protocol.Update](o)
+
+	// X's game clock runs out on a later move, with no move limit.
+	for _, p := range []*testPlayer{x, o} {
+		u := expect[*protocol.Update](p)
+		if u.Winner != "O" || u.Reason != "timeout" || u.Clocks != nil {
+			t.Fatalf("after X's game time: winner %q, reason %q, clocks %v", u.Winner, u.Reason, u.Clocks)
+		}
+	}
+}
+
+func TestStaleTimeout(t *testing.T) {
+	s := NewServer(Config{BestOf: 1, MoveTime: time.Hour}, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory(), NewAccountStore(""))
+	t.Cleanup(s.Close)
+	room := s.newRoom(&RoomPlayer{ID: "x"}, &RoomPlayer{ID: "o"})
+	if err := s.openRoom(room); err != nil {
+		t.Fatal(err)
+	}
+	stale := room.ClockSeq
+
+	// X moves, which starts O's turn on a new clock.
+	err := s.rooms.Update(room.ID, func(room *Room) error {
+		if _, err := room.Play("X", 0, 0); err != nil {
+			return err
+		}
+		room.MoveTimes = append(room.MoveTimes, time.Now())
+		s.startClock(room)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.handleTimeout(room.ID, stale)
+	if room, _ = s.rooms.Get(room.ID); room.Status != "playing" || room.Winner != "" {
+		t.Fatalf("a timer for X's turn ended the game: status %q, winner %q", room.Status, room.Winner)
+	}
+
+	s.handleTimeout(room.ID, room.ClockSeq)
+	if room, _ = s.rooms.Get(room.ID); room.Status != "finished" || room.Winner != "X" {
+		t.Fatalf("O's timeout: status %q, winner %q", room.Status, room.Winner)
+	}
+}
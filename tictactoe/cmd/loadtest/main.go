@@ -0,0 +1,260 @@
+// Command loadtest plays games between pairs of bots against a tictactoe
+// server and reports how long matchmaking and moves took.
+//
+// Usage:
+//
+//	go run ./cmd/loadtest -url ws://localhost:8080/ws -pairs 50 -games 3
+//
+// The server's abuse limits apply to the bots, which all connect from one
+// address and move as fast as they can, so start it with
+// -max-conns-per-ip 0 -message-rate 0 unless those limits are what you
+// mean to test.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"tictactoe/client"
+	"tictactoe/protocol"
+)
+
+var (
+	serverURL = flag.String("url", "ws://localhost:8080/ws", "server websocket URL")
+	pairs     = flag.Int("pairs", 10, "number of bot pairs")
+	games     = flag.Int("games", 1, "games each pair plays, using rematches")
+	think     = flag.Duration("think", 0, "delay before each move")
+	ramp      = flag.Duration("ramp", time.Second, "time over which bots connect")
+	timeout   = flag.Duration("timeout", time.Minute, "time limit for the whole run")
+)
+
+func main() {
+	flag.Parse()
+	if *pairs < 1 || *games < 1 {
+		log.Fatal("-pairs and -games must be at least 1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var stats Stats
+	start := time.Now()
+	var wg sync.WaitGroup
+	bots := 2 * *pairs
+	for i := 0; i < bots; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Spread connections over the ramp so they don't all land at once.
+			select {
+			case <-time.After(*ramp * time.Duration(i) / time.Duration(bots)):
+			case <-ctx.Done():
+				return
+			}
+			runBot(ctx, &stats)
+		}(i)
+	}
+	wg.Wait()
+
+	stats.Report(os.Stdout, time.Since(start))
+}
+
+// runBot connects one bot and plays games until it has played -games or
+// something goes wrong.
+func runBot(ctx context.Context, stats *Stats) {
+	c, err := client.Dial(ctx, *serverURL, nil)
+	if err != nil {
+		stats.Error("dial", err)
+		return
+	}
+	defer c.Close()
+
+	queued := time.Now()
+	m, err := c.WaitMatch(ctx)
+	if err != nil {
+		stats.Error("match", err)
+		return
+	}
+	stats.Matched(time.Since(queued))
+
+	for game := 1; ; game++ {
+		if err := playGame(ctx, c, m, stats); err != nil {
+			stats.Error("game", err)
+			return
+		}
+		if game == *games {
+			// Give the opponent a moment to see the result before leaving,
+			// so it doesn't count as a disconnect.
+			time.Sleep(100 * time.Millisecond)
+			return
+		}
+		if err := c.Rematch(); err != nil {
+			stats.Error("rematch", err)
+			return
+		}
+		if m, err = c.WaitMatch(ctx); err != nil {
+			stats.Error("rematch", err)
+			return
+		}
+	}
+}
+
+// playGame plays the game that m started, moving to random empty cells.
+func playGame(ctx context.Context, c *client.Conn, m *protocol.Matched, stats *Stats) error {
+	board, turn := m.Board, m.Turn
+	for {
+		if turn == m.Symbol {
+			if *think > 0 {
+				time.Sleep(*think)
+			}
+			row, col := randomEmpty(board)
+			sent := time.Now()
+			if err := c.Move(row, col); err != nil {
+				return err
+			}
+			u, err := c.WaitUpdate(ctx)
+			if err != nil {
+				return err
+			}
+			stats.Moved(time.Since(sent))
+			board, turn = u.Board, u.Turn
+			if u.Winner != "" {
+				stats.Finished()
+				return nil
+			}
+			continue
+		}
+
+		u, err := c.WaitUpdate(ctx)
+		if err != nil {
+			return err
+		}
+		board, turn = u.Board, u.Turn
+		if u.Winner != "" {
+			return nil
+		}
+	}
+}
+
+func randomEmpty(board [3][3]string) (row, col int) {
+	var empty [][2]int
+	for r := range board {
+		for c := range board[r] {
+			if board[r][c] == "" {
+				empty = append(empty, [2]int{r, c})
+			}
+		}
+	}
+	cell := empty[rand.Intn(len(empty))]
+	return cell[0], cell[1]
+}
+
+// Stats collects measurements from every bot.
+type Stats struct {
+	mu       sync.Mutex
+	matches  []time.Duration
+	moves    []time.Duration
+	finished int
+	errors   map[string]int
+}
+
+// Matched records how long a bot waited to be matched.
+func (s *Stats) Matched(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.matches = append(s.matches, d)
+}
+
+// Moved records the time between sending a move and seeing its update.
+func (s *Stats) Moved(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.moves = append(s.moves, d)
+}
+
+// Finished counts a completed game. Only the bot making the last move
+// calls it, so each game is counted once.
+func (s *Stats) Finished() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished++
+}
+
+// Error counts a failure during the given stage. Errors from the server
+// are counted by their code.
+func (s *Stats) Error(stage string, err error) {
+	key := stage
+	var perr *protocol.Error
+	switch {
+	case errors.As(err, &perr):
+		key += ": " + perr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		key += ": timeout"
+	case errors.Is(err, client.ErrClosed):
+		key += ": connection closed"
+	default:
+		key += ": " + err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errors == nil {
+		s.errors = make(map[string]int)
+	}
+	s.errors[key]++
+}
+
+// Report writes a summary of the run to w.
+func (s *Stats) Report(w io.Writer, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "Run time:        %v\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Games finished:  %d\n", s.finished)
+	fmt.Fprintf(w, "Matchmaking:     %s\n", summarize(s.matches))
+	fmt.Fprintf(w, "Move round trip: %s\n", summarize(s.moves))
+
+	total := 0
+	keys := make([]string, 0, len(s.errors))
+	for k, n := range s.errors {
+		keys = append(keys, k)
+		total += n
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "Errors:          %d\n", total)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-40s %d\n", k, s.errors[k])
+	}
+}
+
+// summarize describes the distribution of ds.
+func summarize(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return "no samples"
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+	return fmt.Sprintf("n=%d min=%v mean=%v p50=%v p95=%v p99=%v max=%v",
+		len(sorted), round(sorted[0]), round(sum/time.Duration(len(sorted))),
+		round(percentile(sorted, 50)), round(percentile(sorted, 95)), round(percentile(sorted, 99)),
+		round(sorted[len(sorted)-1]))
+}
+
+// percentile returns the p-th percentile of sorted, using the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
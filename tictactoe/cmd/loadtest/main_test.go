@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+func TestPercentile(t *testing.T) {
+	var ds []time.Duration
+	for i := 1; i <= 100; i++ {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p    int
+		want time.Duration
+	}{
+		{1, 1 * time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{95, 95 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(ds, tt.p); got != tt.want {
+			t.Errorf("percentile(%d) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(ds[:1], 99); got != time.Millisecond {
+		t.Errorf("percentile of one sample = %v", got)
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	var s Stats
+	s.Matched(10 * time.Millisecond)
+	s.Moved(2 * time.Millisecond)
+	s.Finished()
+	s.Error("game", &protocol.Error{Code: protocol.RateLimited})
+	s.Error("game", &protocol.Error{Code: protocol.RateLimited})
+	s.Error("dial", errors.New("refused"))
+
+	var b strings.Builder
+	s.Report(&b, time.Second)
+	for _, want := range []string{"Games finished:  1", "Errors:          3", "game: rate_limited", "dial: refused"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, b.String())
+		}
+	}
+}
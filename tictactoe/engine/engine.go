@@ -0,0 +1,199 @@
+// Package engine implements the rules of tic-tac-toe, independent of how
+// moves arrive or how the board is shown.
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Size is the width and height of the board.
+const Size = 3
+
+// Symbols placed on the board, and the Winner of a drawn game.
+const (
+	X    = "X"
+	O    = "O"
+	Draw = "draw"
+)
+
+// Errors returned by Game methods for actions that break the rules.
+var (
+	ErrGameOver    = errors.New("game is over")
+	ErrNotYourTurn = errors.New("not your turn")
+	ErrOutOfBounds = errors.New("coordinates out of bounds")
+	ErrOccupied    = errors.New("cell already occupied")
+	ErrNoMoves     = errors.New("no moves to undo")
+)
+
+// Board holds X, O or "" in each cell, indexed by row then column.
+type Board [Size][Size]string
+
+// Winner returns the symbol that has three in a row, or "" if neither does.
+func (b Board) Winner() string {
+	lines := [][3][2]int{
+		{{0, 0}, {0, 1}, {0, 2}},
+		{{1, 0}, {1, 1}, {1, 2}},
+		{{2, 0}, {2, 1}, {2, 2}},
+		{{0, 0}, {1, 0}, {2, 0}},
+		{{0, 1}, {1, 1}, {2, 1}},
+		{{0, 2}, {1, 2}, {2, 2}},
+		{{0, 0}, {1, 1}, {2, 2}},
+		{{0, 2}, {1, 1}, {2, 0}},
+	}
+	for _, l := range lines {
+		first := b[l[0][0]][l[0][1]]
+		if first != "" && first == b[l[1][0]][l[1][1]] && first == b[l[2][0]][l[2][1]] {
+			return first
+		}
+	}
+	return ""
+}
+
+// Full reports whether every cell is taken.
+func (b Board) Full() bool {
+	for _, row := range b {
+		for _, cell := range row {
+			if cell == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Move is a symbol placed at a cell.
+type Move struct {
+	Symbol string `json:"symbol"`
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+}
+
+// Result is the outcome of a game so far.
+type Result int
+
+const (
+	InProgress Result = iota
+	Won
+	Drawn
+)
+
+func (r Result) String() string {
+	switch r {
+	case InProgress:
+		return "in progress"
+	case Won:
+		return "won"
+	case Drawn:
+		return "drawn"
+	}
+	return fmt.Sprintf("Result(%d)", int(r))
+}
+
+// Game is a single game of tic-tac-toe. The zero value is not ready for
+// use; call New.
+type Game struct {
+	Board  Board  `json:"board"`
+	Turn   string `json:"turn"`   // symbol to move, or "" once the game is over
+	Winner string `json:"winner"` // X, O or Draw once the game is over
+	Moves  []Move `json:"moves"`
+}
+
+// New returns a game with an empty board and X to move.
+func New() *Game {
+	return &Game{Turn: X}
+}
+
+// Over reports whether the game has finished.
+func (g *Game) Over() bool {
+	return g.Winner != ""
+}
+
+// Result returns the outcome of the game so far.
+func (g *Game) Result() Result {
+	switch g.Winner {
+	case "":
+		return InProgress
+	case Draw:
+		return Drawn
+	}
+	return Won
+}
+
+// Play places symbol at (row, col) and passes the turn to the other player,
+// or ends the game if the move wins or fills the board. An illegal move
+// returns one of the package's errors and leaves the game unchanged.
+func (g *Game) Play(symbol string, row, col int) (Result, error) {
+	if g.Over() {
+		return g.Result(), ErrGameOver
+	}
+	if symbol != g.Turn {
+		return InProgress, ErrNotYourTurn
+	}
+	if row < 0 || row >= Size || col < 0 || col >= Size {
+		return InProgress, ErrOutOfBounds
+	}
+	if g.Board[row][col] != "" {
+		return InProgress, ErrOccupied
+	}
+
+	g.Board[row][col] = symbol
+	g.Moves = append(g.Moves, Move{Symbol: symbol, Row: row, Col: col})
+
+	if w := g.Board.Winner(); w != "" {
+		g.end(w)
+	} else if g.Board.Full() {
+		g.end(Draw)
+	} else {
+		g.Turn = Other(symbol)
+	}
+	return g.Result(), nil
+}
+
+// Forfeit ends the game as a loss for symbol, for example when that player
+// runs out of time or leaves.
+func (g *Game) Forfeit(symbol string) error {
+	if g.Over() {
+		return ErrGameOver
+	}
+	g.end(Other(symbol))
+	return nil
+}
+
+// AgreeDraw ends the game as a draw, when both players agree to one.
+func (g *Game) AgreeDraw() error {
+	if g.Over() {
+		return ErrGameOver
+	}
+	g.end(Draw)
+	return nil
+}
+
+// Undo takes back the last move and gives the turn back to the player who
+// made it. Finished games can't be undone.
+func (g *Game) Undo() (Move, error) {
+	if g.Over() {
+		return Move{}, ErrGameOver
+	}
+	if len(g.Moves) == 0 {
+		return Move{}, ErrNoMoves
+	}
+	last := g.Moves[len(g.Moves)-1]
+	g.Moves = g.Moves[:len(g.Moves)-1]
+	g.Board[last.Row][last.Col] = ""
+	g.Turn = last.Symbol
+	return last, nil
+}
+
+func (g *Game) end(winner string) {
+	g.Winner = winner
+	g.Turn = ""
+}
+
+// Other returns the opponent of symbol.
+func Other(symbol string) string {
+	if symbol == X {
+		return O
+	}
+	return X
+}
@@ -0,0 +1,312 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// parseBoard reads a board written as three rows of X, O or '.'.
+func parseBoard(t *testing.T, rows ...string) Board {
+	t.Helper()
+	var b Board
+	for i, row := range rows {
+		for j, c := range row {
+			if c != '.' {
+				b[i][j] = string(c)
+			}
+		}
+	}
+	return b
+}
+
+func TestBoardWinner(t *testing.T) {
+	tests := []struct {
+		name  string
+		board []string
+		want  string
+	}{
+		{"empty", []string{"...", "...", "..."}, ""},
+		{"top row", []string{"XXX", "OO.", "..."}, X},
+		{"middle row", []string{"X.X", "OOO", "X.."}, O},
+		{"bottom row", []string{"O.O", "...", "XXX"}, X},
+		{"left column", []string{"OX.", "OX.", "O.X"}, O},
+		{"middle column", []string{"OX.", ".X.", "OX."}, X},
+		{"right column", []string{"X.O", "X.O", ".XO"}, O},
+		{"diagonal", []string{"X.O", "OX.", "..X"}, X},
+		{"anti-diagonal", []string{"X.O", "XO.", "O.."}, O},
+		{"no line", []string{"XOX", "XOO", "OXX"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseBoard(t, tt.board...).Winner(); got != tt.want {
+				t.Errorf("Winner() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoardFull(t *testing.T) {
+	if parseBoard(t, "XOX", "XOO", "OX.").Full() {
+		t.Error("board with an empty cell reported full")
+	}
+	if !parseBoard(t, "XOX", "XOO", "OXX").Full() {
+		t.Error("full board reported not full")
+	}
+}
+
+func TestPlay(t *testing.T) {
+	tests := []struct {
+		name       string
+		moves      []Move // all but the last must be legal
+		wantResult Result
+		wantErr    error
+		wantWinner string
+		wantTurn   string
+	}{
+		{
+			name:       "first move",
+			moves:      []Move{{X, 1, 1}},
+			wantResult: InProgress,
+			wantTurn:   O,
+		},
+		{
+			name:       "O cannot open",
+			moves:      []Move{{O, 0, 0}},
+			wantResult: InProgress,
+			wantErr:    ErrNotYourTurn,
+			wantTurn:   X,
+		},
+		{
+			name:       "same player twice",
+			moves:      []Move{{X, 0, 0}, {X, 0, 1}},
+			wantResult: InProgress,
+			wantErr:    ErrNotYourTurn,
+			wantTurn:   O,
+		},
+		{
+			name:       "negative row",
+			moves:      []Move{{X, -1, 0}},
+			wantResult: InProgress,
+			wantErr:    ErrOutOfBounds,
+			wantTurn:   X,
+		},
+		{
+			name:       "column too large",
+			moves:      []Move{{X, 0, 3}},
+			wantResult: InProgress,
+			wantErr:    ErrOutOfBounds,
+			wantTurn:   X,
+		},
+		{
+			name:       "occupied",
+			moves:      []Move{{X, 2, 2}, {O, 2, 2}},
+			wantResult: InProgress,
+			wantErr:    ErrOccupied,
+			wantTurn:   O,
+		},
+		{
+			name:       "X wins",
+			moves:      []Move{{X, 0, 0}, {O, 1, 0}, {X, 0, 1}, {O, 1, 1}, {X, 0, 2}},
+			wantResult: Won,
+			wantWinner: X,
+		},
+		{
+			name:       "O wins",
+			moves:      []Move{{X, 0, 0}, {O, 1, 1}, {X, 0, 1}, {O, 0, 2}, {X, 2, 2}, {O, 2, 0}},
+			wantResult: Won,
+			wantWinner: O,
+		},
+		{
+			name: "draw",
+			moves: []Move{
+				{X, 0, 0}, {O, 0, 1}, {X, 0, 2},
+				{O, 1, 1}, {X, 1, 0}, {O, 1, 2},
+				{X, 2, 1}, {O, 2, 0}, {X, 2, 2},
+			},
+			wantResult: Drawn,
+			wantWinner: Draw,
+		},
+		{
+			name:       "move after win",
+			moves:      []Move{{X, 0, 0}, {O, 1, 0}, {X, 0, 1}, {O, 1, 1}, {X, 0, 2}, {O, 2, 2}},
+			wantResult: Won,
+			wantErr:    ErrGameOver,
+			wantWinner: X,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New()
+			last := len(tt.moves) - 1
+			for _, m := range tt.moves[:last] {
+				if _, err := g.Play(m.Symbol, m.Row, m.Col); err != nil {
+					t.Fatalf("Play(%v): %v", m, err)
+				}
+			}
+			before := *g
+
+			m := tt.moves[last]
+			result, err := g.Play(m.Symbol, m.Row, m.Col)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Play(%v) error = %v, want %v", m, err, tt.wantErr)
+			}
+			if result != tt.wantResult {
+				t.Errorf("Play(%v) = %v, want %v", m, result, tt.wantResult)
+			}
+			if g.Winner != tt.wantWinner {
+				t.Errorf("Winner = %q, want %q", g.Winner, tt.wantWinner)
+			}
+			if g.Turn != tt.wantTurn {
+				t.Errorf("Turn = %q, want %q", g.Turn, tt.wantTurn)
+			}
+			if err != nil && (g.Board != before.Board || len(g.Moves) != len(before.Moves)) {
+				t.Error("illegal move changed the game")
+			}
+		})
+	}
+}
+
+func TestForfeit(t *testing.T) {
+	g := New()
+	g.Play(X, 0, 0)
+	if err := g.Forfeit(O); err != nil {
+		t.Fatal(err)
+	}
+	if g.Winner != X || g.Turn != "" || g.Result() != Won {
+		t.Errorf("after O forfeits: Winner %q, Turn %q, Result %v", g.Winner, g.Turn, g.Result())
+	}
+	if err := g.Forfeit(X); err != ErrGameOver {
+		t.Errorf("second Forfeit error = %v, want ErrGameOver", err)
+	}
+}
+
+func TestAgreeDraw(t *testing.T) {
+	g := New()
+	g.Play(X, 1, 1)
+	if err := g.AgreeDraw(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Winner != Draw || g.Turn != "" || g.Result() != Drawn {
+		t.Errorf("after agreeing a draw: Winner %q, Turn %q, Result %v", g.Winner, g.Turn, g.Result())
+	}
+	if err := g.AgreeDraw(); err != ErrGameOver {
+		t.Errorf("second AgreeDraw error = %v, want ErrGameOver", err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	g := New()
+	if _, err := g.Undo(); err != ErrNoMoves {
+		t.Errorf("Undo on an empty board: error = %v, want ErrNoMoves", err)
+	}
+	g.Play(X, 0, 0)
+	g.Play(O, 1, 1)
+	m, err := g.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != (Move{Symbol: O, Row: 1, Col: 1}) {
+		t.Errorf("Undo returned %+v", m)
+	}
+	if g.Board[1][1] != "" || g.Turn != O || len(g.Moves) != 1 {
+		t.Errorf("after Undo: board %s, Turn %q, %d moves", dump(g.Board), g.Turn, len(g.Moves))
+	}
+	if _, err := g.Play(O, 2, 2); err != nil {
+		t.Errorf("O moving again after Undo: %v", err)
+	}
+
+	for _, move := range [][2]int{{0, 1}, {1, 1}, {0, 2}} {
+		g.Play(g.Turn, move[0], move[1])
+	}
+	if _, err := g.Undo(); err != ErrGameOver || g.Winner != X {
+		t.Errorf("Undo after X won: error = %v, Winner %q", err, g.Winner)
+	}
+}
+
+// FuzzPlay plays arbitrary move sequences and checks that the game never
+// reaches an impossible state.
+func FuzzPlay(f *testing.F) {
+	f.Add([]byte{0, 4, 1, 3, 2})
+	f.Add([]byte{0, 1, 2, 4, 3, 5, 7, 6, 8})
+	f.Add([]byte{4, 4, 9, 255, 0})
+	f.Fuzz(func(t *testing.T, cells []byte) {
+		g := New()
+		for i, c := range cells {
+			// Alternate between the correct symbol and a random one so
+			// wrong-turn moves are exercised too.
+			symbol := g.Turn
+			if c&0x80 != 0 {
+				symbol = Other(symbol)
+			}
+			row, col := int(c&0x7f)/Size, int(c&0x7f)%Size
+			wasOver := g.Over()
+			before := *g
+
+			_, err := g.Play(symbol, row, col)
+			if wasOver && err != ErrGameOver {
+				t.Fatalf("move %d after game over: err = %v", i, err)
+			}
+			if err != nil && g.Board != before.Board {
+				t.Fatalf("move %d rejected with %v but changed the board", i, err)
+			}
+			checkInvariants(t, g)
+		}
+	})
+}
+
+func checkInvariants(t *testing.T, g *Game) {
+	t.Helper()
+	var xs, os int
+	for _, row := range g.Board {
+		for _, cell := range row {
+			switch cell {
+			case X:
+				xs++
+			case O:
+				os++
+			case "":
+			default:
+				t.Fatalf("bad cell %q", cell)
+			}
+		}
+	}
+	if xs != os && xs != os+1 {
+		t.Fatalf("%d X and %d O on the board", xs, os)
+	}
+	if len(g.Moves) != xs+os {
+		t.Fatalf("%d moves recorded for %d marks", len(g.Moves), xs+os)
+	}
+	switch {
+	case g.Over() && g.Turn != "":
+		t.Fatalf("game over but Turn = %q", g.Turn)
+	case !g.Over() && g.Turn != X && g.Turn != O:
+		t.Fatalf("game in progress but Turn = %q", g.Turn)
+	case g.Winner == X || g.Winner == O:
+		if g.Board.Winner() != g.Winner {
+			t.Fatalf("Winner %q but board winner %q:\n%s", g.Winner, g.Board.Winner(), dump(g.Board))
+		}
+	case g.Winner == Draw:
+		if !g.Board.Full() || g.Board.Winner() != "" {
+			t.Fatalf("draw declared on\n%s", dump(g.Board))
+		}
+	case g.Winner == "":
+		if g.Board.Winner() != "" || g.Board.Full() {
+			t.Fatalf("game not over on\n%s", dump(g.Board))
+		}
+	}
+}
+
+func dump(b Board) string {
+	var sb strings.Builder
+	for _, row := range b {
+		for _, cell := range row {
+			if cell == "" {
+				cell = "."
+			}
+			sb.WriteString(cell)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
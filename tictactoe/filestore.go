@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+const (
+	// lockTimeout is how long FileStore waits for another node to release
+	// a lock before giving up.
+	lockTimeout = 5 * time.Second
+	// staleLock is the age after which a lock file is assumed to belong to
+	// a node that crashed while holding it.
+	staleLock = 10 * time.Second
+	// filePollInterval is how often FileBus checks topics for new messages.
+	filePollInterval = 20 * time.Millisecond
+)
+
+// FileStore is a RoomStore kept in a directory, so that several server
+// processes on one machine can share rooms, and rooms survive a restart.
+// Each room and tournament is a JSON file; the waiting queue is queue.json.
+// Access is serialized with lock files created exclusively next to the data.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	for _, sub := range []string{"rooms", "tournaments"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) roomPath(id string) (string, error) {
+	if !validFileID(id) {
+		return "", ErrRoomNotFound
+	}
+	return filepath.Join(s.dir, "rooms", id+".json"), nil
+}
+
+func (s *FileStore) tournamentPath(id string) (string, error) {
+	if !validFileID(id) {
+		return "", ErrTournamentNotFound
+	}
+	return filepath.Join(s.dir, "tournaments", id+".json"), nil
+}
+
+// validFileID reports whether id can safely name a file in the store.
+func validFileID(id string) bool {
+	return id != "" && filepath.Base(id) == id && !strings.HasPrefix(id, ".")
+}
+
+func (s *FileStore) Create(room *Room) error {
+	path, err := s.roomPath(room.ID)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeJSONFile(path, room)
+}
+
+// Get and Rooms read without taking locks; writeJSONFile replaces files
+// atomically, so they always see a whole room.
+func (s *FileStore) Get(id string) (*Room, error) {
+	path, err := s.roomPath(id)
+	if err != nil {
+		return nil, err
+	}
+	var room Room
+	if err := readJSONFile(path, &room); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func (s *FileStore) Rooms() ([]*Room, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "rooms", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]*Room, 0, len(paths))
+	for _, path := range paths {
+		var room Room
+		if err := readJSONFile(path, &room); errors.Is(err, os.ErrNotExist) {
+			continue // deleted since the directory was listed
+		} else if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, &room)
+	}
+	return rooms, nil
+}
+
+func (s *FileStore) Update(id string, fn func(*Room) error) error {
+	path, err := s.roomPath(id)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var room Room
+	if err := readJSONFile(path, &room); errors.Is(err, os.ErrNotExist) {
+		return ErrRoomNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := fn(&room); err != nil {
+		return err
+	}
+	return writeJSONFile(path, &room)
+}
+
+func (s *FileStore) Delete(id string) error {
+	path, err := s.roomPath(id)
+	if err != nil {
+		return nil
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) UpdateQueue(fn func([]QueueEntry) ([]QueueEntry, error)) error {
+	path := filepath.Join(s.dir, "queue.json")
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var queue []QueueEntry
+	if err := readJSONFile(path, &queue); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	queue, err = fn(queue)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(path, queue)
+}
+
+func (s *FileStore) Queue() ([]QueueEntry, error) {
+	var queue []QueueEntry
+	err := readJSONFile(filepath.Join(s.dir, "queue.json"), &queue)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func (s *FileStore) CreateTournament(t *Tournament) error {
+	path, err := s.tournamentPath(t.ID)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeJSONFile(path, t)
+}
+
+func (s *FileStore) GetTournament(id string) (*Tournament, error) {
+	path, err := s.tournamentPath(id)
+	if err != nil {
+		return nil, err
+	}
+	var t Tournament
+	if err := readJSONFile(path, &t); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTournamentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *FileStore) Tournaments() ([]*Tournament, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "tournaments", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Tournament, 0, len(paths))
+	for _, path := range paths {
+		var t Tournament
+		if err := readJSONFile(path, &t); err != nil {
+			return nil, err
+		}
+		list = append(list, &t)
+	}
+	return list, nil
+}
+
+func (s *FileStore) UpdateTournament(id string, fn func(*Tournament) error) error {
+	path, err := s.tournamentPath(id)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var t Tournament
+	if err := readJSONFile(path, &t); errors.Is(err, os.ErrNotExist) {
+		return ErrTournamentNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := fn(&t); err != nil {
+		return err
+	}
+	return writeJSONFile(path, &t)
+}
+
+// lockFile takes an exclusive lock on path by creating path.lock, waiting
+// up to lockTimeout for another holder to release it.
+func lockFile(path string) (unlock func(), err error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > staleLock {
+			log.Printf("Breaking stale lock %s", lock)
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", lock)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSONFile replaces path with the encoding of v, writing to a temporary
+// file first so readers never see a partial file.
+func writeJSONFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FileBus is a PubSub kept in a directory, for server processes on one
+// machine. Each topic is a file that publishers append JSON lines to and
+// subscribers poll.
+type FileBus struct {
+	dir string
+}
+
+// NewFileBus returns a FileBus in dir, creating the directory if needed.
+func NewFileBus(dir string) (*FileBus, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileBus{dir: dir}, nil
+}
+
+func (b *FileBus) topicPath(topic string) string {
+	return filepath.Join(b.dir, strings.ReplaceAll(topic, string(filepath.Separator), "_")+".log")
+}
+
+func (b *FileBus) Publish(topic string, msg protocol.Envelope) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(b.topicPath(topic), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// A single O_APPEND write keeps lines from concurrent publishers whole.
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (b *FileBus) Subscribe(topic string, fn func(protocol.Envelope)) (func(), error) {
+	path := b.topicPath(topic)
+
+	// Only messages published from now on are delivered.
+	var offset int64
+	if fi, err := os.Stat(path); err == nil {
+		offset = fi.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(filePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			n, err := readLines(path, offset, fn)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Reading topic %s: %v", topic, err)
+			}
+			offset += n
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			// Player topics are never reused, so nothing else needs it.
+			os.Remove(path)
+		})
+	}, nil
+}
+
+// readLines calls fn for each complete line in path after offset and
+// returns the number of bytes consumed.
+func readLines(path string, offset int64, fn func(protocol.Envelope)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var consumed int64
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			// Leave a partially written line for the next poll.
+			return consumed, nil
+		}
+		var msg protocol.Envelope
+		if err := json.Unmarshal(data[:i], &msg); err != nil {
+			log.Printf("Skipping bad message in %s: %v", path, err)
+		} else {
+			fn(msg)
+		}
+		consumed += int64(i + 1)
+		data = data[i+1:]
+	}
+}
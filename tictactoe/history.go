@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var historyPath = flag.String("history", "games.jsonl", "file finished games are appended to")
+
+// ErrGameNotFound is returned by HistoryStore.Get for unknown game IDs.
+var ErrGameNotFound = errors.New("game not found")
+
+// MoveRecord is a single move in a recorded game.
+type MoveRecord struct {
+	Symbol string    `json:"symbol"`
+	Row    int       `json:"row"`
+	Col    int       `json:"col"`
+	At     time.Time `json:"at"`
+}
+
+// PlayerRecord identifies who played a recorded game and with which symbol.
+type PlayerRecord struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Symbol string `json:"symbol"`
+}
+
+// GameRecord is a finished game as kept in the history store.
+type GameRecord struct {
+	ID        string         `json:"id"`
+	RoomID    string         `json:"roomId"`
+	Game      int            `json:"game"` // position within the room's series
+	Players   []PlayerRecord `json:"players"`
+	Moves     []MoveRecord   `json:"moves"`
+	Winner    string         `json:"winner"`           // "X", "O" or "draw"
+	Reason    string         `json:"reason,omitempty"` // "timeout", "disconnect", "resignation" or "agreement" if not decided on the board
+	StartedAt time.Time      `json:"startedAt"`
+	EndedAt   time.Time      `json:"endedAt"`
+}
+
+// Boards returns the board after each move of the game, for replaying it
+// step by step. The first entry is the empty starting board.
+func (g GameRecord) Boards() [][3][3]string {
+	boards := make([][3][3]string, 1, len(g.Moves)+1)
+	for _, m := range g.Moves {
+		next := boards[len(boards)-1]
+		next[m.Row][m.Col] = m.Symbol
+		boards = append(boards, next)
+	}
+	return boards
+}
+
+// A HistoryStore persists finished games.
+type HistoryStore interface {
+	// Save records a finished game.
+	Save(game GameRecord) error
+	// Recent returns up to n games, most recently finished first.
+	Recent(n int) ([]GameRecord, error)
+	// Get returns the game with the given ID, or ErrGameNotFound.
+	Get(id string) (GameRecord, error)
+}
+
+// MemoryHistory is a HistoryStore that keeps games in memory only.
+type MemoryHistory struct {
+	mu    sync.Mutex
+	games []GameRecord
+	byID  map[string]int
+}
+
+// NewMemoryHistory returns an empty MemoryHistory.
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{byID: make(map[string]int)}
+}
+
+func (h *MemoryHistory) Save(game GameRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(game)
+	return nil
+}
+
+func (h *MemoryHistory) add(game GameRecord) {
+	h.byID[game.ID] = len(h.games)
+	h.games = append(h.games, game)
+}
+
+func (h *MemoryHistory) Recent(n int) ([]GameRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n = min(n, len(h.games))
+	recent := make([]GameRecord, 0, n)
+	for i := len(h.games) - 1; i >= len(h.games)-n; i-- {
+		recent = append(recent, h.games[i])
+	}
+	return recent, nil
+}
+
+func (h *MemoryHistory) Get(id string) (GameRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i, ok := h.byID[id]
+	if !ok {
+		return GameRecord{}, ErrGameNotFound
+	}
+	return h.games[i], nil
+}
+
+// JSONLStore is a HistoryStore that appends each game as one JSON line to a
+// file. The file is read back into memory when the store is opened.
+type JSONLStore struct {
+	MemoryHistory
+	f *os.File
+}
+
+// OpenJSONLStore opens or creates the JSON-lines file at path and loads the
+// games already recorded in it.
+func OpenJSONLStore(path string) (*JSONLStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &JSONLStore{MemoryHistory: MemoryHistory{byID: make(map[string]int)}, f: f}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var game GameRecord
+		if err := json.Unmarshal(scanner.Bytes(), &game); err != nil {
+			// A torn final write shouldn't make the whole history unreadable.
+			log.Printf("%s:%d: skipping bad game record: %v", path, line, err)
+			continue
+		}
+		s.add(game)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONLStore) Save(game GameRecord) error {
+	data, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.add(game)
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *JSONLStore) Close() error {
+	return s.f.Close()
+}
+
+// startRecording notes the start of a new game in room.
+func startRecording(room *Room) {
+	room.StartedAt = time.Now()
+	room.MoveTimes = nil
+}
+
+// recordGame saves the room's just-finished game to the history store.
+func (s *Server) recordGame(room *Room, reason string) {
+	game := GameRecord{
+		ID:        "game_" + randomString(8),
+		RoomID:    room.ID,
+		Game:      room.GameNumber,
+		Winner:    room.Winner,
+		Reason:    reason,
+		StartedAt: room.StartedAt,
+		EndedAt:   time.Now(),
+	}
+	for _, p := range room.Players {
+		game.Players = append(game.Players, PlayerRecord{ID: p.ID, Name: p.Name, Symbol: p.Symbol})
+	}
+	for i, m := range room.Moves {
+		game.Moves = append(game.Moves, MoveRecord{Symbol: m.Symbol, Row: m.Row, Col: m.Col, At: room.MoveTimes[i]})
+	}
+	if err := s.history.Save(game); err != nil {
+		log.Printf("Failed to record game in room %s: %v", room.ID, err)
+	}
+}
+
+// handleGames serves GET /api/games?limit=N, listing recently finished games
+// without their moves.
+func (s *Server) handleGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	games, err := s.history.Recent(limit)
+	if err != nil {
+		log.Printf("Listing games: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := range games {
+		games[i].Moves = nil
+	}
+	writeJSON(w, games)
+}
+
+// handleGame serves GET /api/games/{id}: the full game record plus the board
+// after each move, for step-by-step replay.
+func (s *Server) handleGame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/games/")
+	game, err := s.history.Get(id)
+	if err == ErrGameNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Fetching game %s: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, struct {
+		GameRecord
+		Boards [][3][3]string `json:"boards"`
+	}{game, game.Boards()})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Write error: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONLStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+
+	s, err := OpenJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"game_1", "game_2", "game_3"} {
+		game := GameRecord{
+			ID:     id,
+			Moves:  []MoveRecord{{Symbol: "X", Row: 1, Col: 1}, {Symbol: "O", Row: 0, Col: 2}},
+			Winner: "draw",
+		}
+		if err := s.Save(game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening must load what was written.
+	s, err = OpenJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	recent, err := s.Recent(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].ID != "game_3" || recent[1].ID != "game_2" {
+		t.Errorf("Recent(2) = %v, want game_3, game_2", recent)
+	}
+
+	game, err := s.Get("game_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	boards := game.Boards()
+	if len(boards) != 3 {
+		t.Fatalf("got %d boards, want 3", len(boards))
+	}
+	if boards[1][1][1] != "X" || boards[1][0][2] != "" || boards[2][0][2] != "O" {
+		t.Errorf("unexpected replay boards: %v", boards)
+	}
+
+	if _, err := s.Get("game_missing"); err != ErrGameNotFound {
+		t.Errorf("Get(missing) error = %v, want ErrGameNotFound", err)
+	}
+}
+
+// getJSON serves a GET of url from h, decoding a 200 response into v, and
+// returns the status code.
+func getJSON(t *testing.T, h http.HandlerFunc, url string, v any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code
+}
+
+func TestGamesAPI(t *testing.T) {
+	history := NewMemoryHistory()
+	for i := 1; i <= 25; i++ {
+		history.Save(GameRecord{
+			ID:     fmt.Sprintf("game_%d", i),
+			Moves:  []MoveRecord{{Symbol: "X", Row: 0, Col: 0}, {Symbol: "O", Row: 2, Col: 2}},
+			Winner: "draw",
+		})
+	}
+	s := &Server{history: history}
+
+	tests := []struct {
+		url   string
+		code  int
+		n     int
+		first string
+	}{
+		{"/api/games", http.StatusOK, 20, "game_25"},
+		{"/api/games?limit=3", http.StatusOK, 3, "game_25"},
+		{"/api/games?limit=100", http.StatusOK, 25, "game_25"},
+		{"/api/games?limit=0", http.StatusBadRequest, 0, ""},
+		{"/api/games?limit=101", http.StatusBadRequest, 0, ""},
+		{"/api/games?limit=ten", http.StatusBadRequest, 0, ""},
+	}
+	for _, tt := range tests {
+		var games []GameRecord
+		code := getJSON(t, s.handleGames, tt.url, &games)
+		if code != tt.code || len(games) != tt.n || tt.n > 0 && games[0].ID != tt.first {
+			t.Errorf("%s: status %d, %d games; want %d, %d starting with %s", tt.url, code, len(games), tt.code, tt.n, tt.first)
+		}
+		for _, g := range games {
+			if g.Moves != nil {
+				t.Errorf("%s: listed %s with its moves", tt.url, g.ID)
+			}
+		}
+	}
+
+	var replay struct {
+		GameRecord
+		Boards [][3][3]string `json:"boards"`
+	}
+	if code := getJSON(t, s.handleGame, "/api/games/game_7", &replay); code != http.StatusOK {
+		t.Fatalf("game_7: status %d", code)
+	}
+	if replay.ID != "game_7" || len(replay.Moves) != 2 || len(replay.Boards) != 3 || replay.Boards[2][2][2] != "O" {
+		t.Errorf("game_7 replay: %+v", replay)
+	}
+	for _, url := range []string{"/api/games/game_99", "/api/games/", "/api/games/game_7/moves"} {
+		if code := getJSON(t, s.handleGame, url, &replay); code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", url, code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.handleGames(w, httptest.NewRequest(http.MethodPost, "/api/games", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/games: status %d, want 405", w.Code)
+	}
+}
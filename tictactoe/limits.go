@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Abuse limits applied to websocket connections.
+var (
+	allowedOrigins = flag.String("origins", "", `comma-separated origins allowed to open websockets, such as "https://example.com", or "*" for any; by default only the server's own origin`)
+	maxConnsPerIP  = flag.Int("max-conns-per-ip", 10, "most websocket connections from one IP address (0 for no limit)")
+	messageRate    = flag.Float64("message-rate", 5, "messages per second each connection may send on average (0 for no limit)")
+	messageBurst   = flag.Int("message-burst", 10, "messages a connection may send at once before -message-rate applies")
+	maxMessageSize = flag.Int64("max-message-size", defaultMaxMessageSize, "largest message in bytes a client may send")
+	maxViolations  = flag.Int("max-violations", 5, "malformed or rate-limited messages after which a client is disconnected (0 for no limit)")
+)
+
+// checkOrigin reports whether a websocket may be opened from r's origin.
+// Without a configured list only the server's own origin is allowed.
+// Browsers always send an Origin header, so requests without one come from
+// other programs, which the header can't protect against anyway.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(s.cfg.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range s.cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address r came from. X-Forwarded-For is not
+// trusted, so behind a proxy every client shares the proxy's limit.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// acquireConn counts a new connection from ip, reporting false if ip
+// already has the most connections allowed. Each successful call must be
+// matched by a call to releaseConn.
+func (s *Server) acquireConn(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.MaxConnsPerIP > 0 && s.conns[ip] >= s.cfg.MaxConnsPerIP {
+		return false
+	}
+	s.conns[ip]++
+	return true
+}
+
+func (s *Server) releaseConn(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns[ip]--; s.conns[ip] <= 0 {
+		delete(s.conns, ip)
+	}
+}
+
+// tokenBucket limits the rate of events to rate per second on average,
+// allowing bursts of up to burst events. It is not safe for concurrent use.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket, or nil, which allows everything, if
+// rate is not positive.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// allow takes a token if one is available at now.
+func (b *tokenBucket) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"tictactoe/protocol"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2, 3)
+	now := b.last
+	for i := 0; i < 3; i++ {
+		if !b.allow(now) {
+			t.Fatalf("burst event %d refused", i)
+		}
+	}
+	if b.allow(now) {
+		t.Fatal("event beyond burst allowed")
+	}
+	if !b.allow(now.Add(500 * time.Millisecond)) {
+		t.Fatal("event refused after refill")
+	}
+	if b.allow(now.Add(500 * time.Millisecond)) {
+		t.Fatal("refill allowed two events")
+	}
+
+	var unlimited *tokenBucket
+	if !unlimited.allow(now) {
+		t.Fatal("nil bucket refused an event")
+	}
+}
+
+func dialWithOrigin(url, origin string) (*websocket.Conn, *http.Response, error) {
+	h := http.Header{}
+	if origin != "" {
+		h.Set("Origin", origin)
+	}
+	return websocket.DefaultDialer.Dial(url, h)
+}
+
+func TestAbuseLimits(t *testing.T) {
+	cfg := Config{
+		BestOf:         1,
+		AllowedOrigins: []string{"https://good.example"},
+		MaxConnsPerIP:  2,
+		MessageRate:    0.1,
+		MessageBurst:   2,
+		MaxMessageSize: 256,
+		MaxViolations:  3,
+	}
+	url := startNodeConfig(t, cfg, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+
+	if _, resp, err := dialWithOrigin(url, "https://evil.example"); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("disallowed origin: err %v, resp %v", err, resp)
+	}
+	conn, _, err := dialWithOrigin(url, "https://good.example")
+	if err != nil {
+		t.Fatalf("allowed origin: %v", err)
+	}
+	alice := &testPlayer{t, conn}
+	t.Cleanup(func() { conn.Close() })
+	expect[*protocol.Waiting](alice)
+
+	bob := dialPlayer(t, url)
+	expect[*protocol.Matched](bob)
+	if _, resp, err := dialWithOrigin(url, ""); err == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("third connection: err %v, resp %v", err, resp)
+	}
+
+	// The burst is allowed, the next message is a violation, as are two
+	// malformed ones, which is enough to be disconnected.
+	bob.send(&protocol.Chat{Text: "one"})
+	bob.send(&protocol.Chat{Text: "two"})
+	bob.send(&protocol.Chat{Text: "three"})
+	if e := expect[*protocol.Error](bob); e.Code != protocol.RateLimited {
+		t.Fatalf("over rate: error %q", e.Code)
+	}
+	bob.sendRaw(`{"type":"move"}`)
+	expect[*protocol.Error](bob)
+	bob.sendRaw(`{"type":"nonsense"}`)
+	expect[*protocol.Error](bob)
+	var env protocol.Envelope
+	if err := bob.conn.ReadJSON(&env); err == nil {
+		t.Fatalf("client still connected after violations, got %q", env.Type)
+	}
+	expect[*protocol.OpponentDisconnected](alice)
+
+	// Messages over the size limit close the connection.
+	alice.sendRaw(`{"type":"chat","payload":{"text":"` + strings.Repeat("a", 300) + `"}}`)
+	if err := alice.conn.ReadJSON(&env); err == nil {
+		t.Fatalf("client still connected after oversized message, got %q", env.Type)
+	}
+}
@@ -3,273 +3,616 @@ package main
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
-	"sync"
-
-	"github.com/gorilla/websocket"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tictactoe/engine"
+	"tictactoe/protocol"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
+// Player is a player connected to this node.
+type Player struct {
+	ID     string  `json:"id"`
+	Client *Client `json:"-"`
+	RoomID string  `json:"roomId"` // guarded by Server.mu
+
+	// Name is empty for anonymous players, who play at defaultRating and
+	// whose results aren't saved.
+	Name   string `json:"name,omitempty"`
+	Rating int    `json:"rating"`
+
+	// Spectating is set for players who joined with ?watch= to follow a
+	// room rather than to play.
+	Spectating bool `json:"spectating,omitempty"`
+
+	// TournamentID is set for players who joined with ?tournament= to play
+	// their matches in a tournament rather than join the queue.
+	TournamentID string `json:"tournamentId,omitempty"`
 }
 
-type Player struct {
-	ID       string          `json:"id"`
-	Conn     *websocket.Conn `json:"-"`
-	Symbol   string          `json:"symbol"`
-	RoomID   string          `json:"roomId"`
-	IsReady  bool            `json:"isReady"`
+// RoomPlayer is a player's seat in a room. Rooms are shared between nodes,
+// so they refer to players by ID and reach them through the PubSub.
+type RoomPlayer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Rating int    `json:"rating"`
+	Symbol string `json:"symbol"`
 }
 
+// Room adapts an engine.Game, which holds the board and enforces the rules,
+// to a pair of players. Rooms are kept in a RoomStore, so every field that
+// must survive between messages is exported.
 type Room struct {
-	ID      string    `json:"id"`
-	Players []*Player `json:"players"`
-	Board   [3][3]string `json:"board"`
-	Turn    string    `json:"turn"`
-	Status  string    `json:"status"` // "waiting", "playing", "finished"
-	Winner  string    `json:"winner"`
-	mutex   sync.Mutex
+	*engine.Game
+
+	ID      string        `json:"id"`
+	Players []*RoomPlayer `json:"players"`
+	Status  string        `json:"status"` // "playing", "finished"
+
+	// Series state. Scores are keyed by player ID because symbols swap
+	// between games of the same series.
+	BestOf     int             `json:"bestOf"`
+	GameNumber int             `json:"gameNumber"`
+	Scores     map[string]int  `json:"scores"`
+	Rematch    map[string]bool `json:"rematch,omitempty"`
+
+	// Clock settings are fixed when the room is created; zero means no
+	// limit. Clocks holds each symbol's remaining game time. ClockSeq
+	// identifies the current turn, so a timer for an earlier turn, or
+	// started by another node, can tell it is stale.
+	MoveTime    time.Duration            `json:"moveTime"`
+	GameTime    time.Duration            `json:"gameTime"`
+	Clocks      map[string]time.Duration `json:"clocks"`
+	TurnStarted time.Time                `json:"turnStarted"`
+	ClockSeq    int                      `json:"clockSeq"`
+
+	// When the current game and each of its moves were played.
+	StartedAt time.Time   `json:"startedAt"`
+	MoveTimes []time.Time `json:"moveTimes"`
+
+	// Spectators are the IDs of players watching the room. Chat is the
+	// room's recent chat; ChatSent and ReactionSent hold when each player
+	// last sent chat and reactions, for rate limiting.
+	Spectators   []string               `json:"spectators,omitempty"`
+	Chat         []protocol.ChatMessage `json:"chat,omitempty"`
+	ChatSent     map[string][]time.Time `json:"chatSent,omitempty"`
+	ReactionSent map[string][]time.Time `json:"reactionSent,omitempty"`
+
+	// Offer is the draw offer or undo request waiting for an answer, if
+	// any. OfferSent holds when each player made offers, for rate limiting.
+	Offer     *PendingOffer          `json:"offer,omitempty"`
+	OfferSent map[string][]time.Time `json:"offerSent,omitempty"`
+
+	// Tournament rooms play one match of a tournament; see matchWinner.
+	// MatchSettled is set once the match's result has been reported.
+	TournamentID string `json:"tournamentId,omitempty"`
+	MatchID      string `json:"matchId,omitempty"`
+	DrawAllowed  bool   `json:"drawAllowed,omitempty"`
+	MatchSettled bool   `json:"matchSettled,omitempty"`
+}
+
+// player returns the seat of the player with the given ID, or nil.
+func (room *Room) player(id string) *RoomPlayer {
+	for _, p := range room.Players {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
 }
 
-type Message struct {
-	Type      string      `json:"type"`
-	PlayerID  string      `json:"playerId,omitempty"`
-	RoomID    string      `json:"roomId,omitempty"`
-	Symbol    string      `json:"symbol,omitempty"`
-	Row       int         `json:"row,omitempty"`
-	Col       int         `json:"col,omitempty"`
-	Board     [3][3]string `json:"board,omitempty"`
-	Turn      string      `json:"turn,omitempty"`
-	Status    string      `json:"status,omitempty"`
-	Winner    string      `json:"winner,omitempty"`
-	Error     string      `json:"error,omitempty"`
-	Message   string      `json:"message,omitempty"`
+// isSpectator reports whether the player with the given ID is watching room.
+func (room *Room) isSpectator(id string) bool {
+	for _, s := range room.Spectators {
+		if s == id {
+			return true
+		}
+	}
+	return false
 }
 
 var (
-	rooms    = make(map[string]*Room)
-	players  = make(map[string]*Player)
-	waitingQueue []*Player
-	roomMutex sync.Mutex
-	playerMutex sync.Mutex
-	roomCounter int
+	addr      = flag.String("addr", ":8080", "address to listen on")
+	bestOf    = flag.Int("bestof", 3, "number of games in a series")
+	storeKind = flag.String("store", "memory", `where rooms are kept: "memory" for a single node, or "file" to share -store-dir between nodes`)
+	storeDir  = flag.String("store-dir", "data", "directory for -store=file")
 )
 
 func main() {
-	http.HandleFunc("/ws", handleWebSocket)
-	http.HandleFunc("/", serveStatic)
+	flag.Parse()
+	if *bestOf < 1 {
+		log.Fatal("-bestof must be at least 1")
+	}
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
-}
+	var (
+		rooms RoomStore
+		bus   PubSub
+	)
+	switch *storeKind {
+	case "memory":
+		rooms, bus = NewMemoryStore(), NewMemoryBus()
+	case "file":
+		fs, err := NewFileStore(*storeDir)
+		if err != nil {
+			log.Fatalf("Opening room store: %v", err)
+		}
+		fb, err := NewFileBus(filepath.Join(*storeDir, "topics"))
+		if err != nil {
+			log.Fatalf("Opening message bus: %v", err)
+		}
+		rooms, bus = fs, fb
+	default:
+		log.Fatalf("Unknown -store %q", *storeKind)
+	}
 
-func serveStatic(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/" {
-		http.ServeFile(w, r, "index.html")
-	} else {
-		http.ServeFile(w, r, r.URL.Path[1:])
+	history, err := OpenJSONLStore(*historyPath)
+	if err != nil {
+		log.Fatalf("Opening game history: %v", err)
 	}
+	defer history.Close()
+
+	accounts, err := LoadAccountStore(*ratingsPath)
+	if err != nil {
+		log.Fatalf("Loading ratings: %v", err)
+	}
+
+	cfg := Config{
+		BestOf:     *bestOf,
+		MoveTime:   *moveTime,
+		GameTime:   *gameTime,
+		ChatFilter: NewWordFilter(defaultBannedWords...),
+		AdminToken: configuredAdminToken(),
+
+		MaxConnsPerIP:  *maxConnsPerIP,
+		MessageRate:    *messageRate,
+		MessageBurst:   *messageBurst,
+		MaxMessageSize: *maxMessageSize,
+		MaxViolations:  *maxViolations,
+
+		TournamentNoShow:   *tournamentNoShow,
+		TournamentNextGame: *tournamentNextGame,
+	}
+	for _, origin := range strings.Split(*allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
+	s := NewServer(cfg, rooms, bus, history, accounts)
+	defer s.Close()
+
+	var static fs.FS = embeddedStatic
+	if *staticDir != "" {
+		static = os.DirFS(*staticDir)
+	}
+	web, err := newStaticHandler(static, *staticDir != "")
+	if err != nil {
+		log.Fatalf("Loading web client: %v", err)
+	}
+
+	s.RegisterHandlers(http.DefaultServeMux)
+	http.Handle("/", web)
+
+	log.Printf("Server starting on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
 }
 
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Players may pick a name with ?name= and the ?secret= that claims it
+	// to get a persistent rating, and instead of joining the queue may
+	// follow a game with ?watch=<room ID> or, if named, play in a
+	// tournament with ?tournament=<tournament ID>.
+	name := r.URL.Query().Get("name")
+	watch := r.URL.Query().Get("watch")
+	tournament := r.URL.Query().Get("tournament")
+	if name != "" && !validName.MatchString(name) {
+		http.Error(w, "name must be 3-20 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+	if name != "" {
+		if err := s.accounts.Claim(name, r.URL.Query().Get("secret")); err != nil {
+			writeClaimError(w, name, err)
+			return
+		}
+	}
+	if tournament != "" && (name == "" || watch != "") {
+		http.Error(w, "tournament players need a name, and can't watch another game", http.StatusBadRequest)
+		return
+	}
+	version := protocol.Version
+	if v := r.URL.Query().Get("protocol"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "protocol must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ip := clientIP(r)
+	if !s.acquireConn(ip) {
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+	defer s.releaseConn(ip)
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
 	}
-	defer conn.Close()
+	client := newClient(conn, s.cfg.MaxMessageSize)
+	defer client.Close()
+
+	// Refuse clients asking for another version of the protocol.
+	if version != protocol.Version {
+		client.Reject(&protocol.Error{
+			Code:    protocol.UnsupportedVersion,
+			Message: fmt.Sprintf("Protocol version %d is not supported; this server speaks version %d", version, protocol.Version),
+		})
+		return
+	}
+	go client.writePump()
 
 	playerID := generatePlayerID()
 	player := &Player{
-		ID:      playerID,
-		Conn:    conn,
-		Symbol:  "",
-		RoomID:  "",
-		IsReady: false,
+		ID:         playerID,
+		Client:     client,
+		Name:       name,
+		Rating:     defaultRating,
+		Spectating: watch != "",
+
+		TournamentID: tournament,
+	}
+	if name != "" {
+		player.Rating = s.accounts.Rating(name)
 	}
 
-	playerMutex.Lock()
-	players[playerID] = player
-	playerMutex.Unlock()
+	// Subscribe before joining the queue so that a match made by another
+	// node can't be missed.
+	unsubscribe, err := s.bus.Subscribe(playerTopic(playerID), func(msg protocol.Envelope) {
+		s.deliver(player, msg)
+	})
+	if err != nil {
+		log.Printf("Subscribing player %s: %v", playerID, err)
+		return
+	}
+	defer unsubscribe()
+
+	s.mu.Lock()
+	s.players[playerID] = player
+	s.mu.Unlock()
 
-	log.Printf("Player %s connected", playerID)
+	log.Printf("Player %s connected (name %q, rating %d)", playerID, name, player.Rating)
 
 	// Send welcome message
-	sendMessage(conn, Message{
-		Type:    "connected",
+	sendMessage(client, &protocol.Connected{
+		Protocol: protocol.Version,
 		PlayerID: playerID,
-		Message: "Connected to server. Waiting for opponent...",
+		Name:     name,
+		Rating:   player.Rating,
+		Message:  "Connected to server. Waiting for opponent...",
 	})
 
-	// Try to match player
-	matchPlayer(player)
+	switch {
+	case player.Spectating:
+		s.spectate(player, watch)
+	case player.TournamentID != "":
+		s.joinTournament(player)
+	default:
+		s.matchPlayer(player)
+	}
 
-	// Handle incoming messages
+	// Handle incoming messages. Malformed messages and messages over the
+	// rate limit are violations; too many get the client disconnected.
+	limiter := newTokenBucket(s.cfg.MessageRate, s.cfg.MessageBurst)
+	violations := 0
 	for {
-		var msg Message
-		err := conn.ReadJSON(&msg)
+		data, err := client.ReadMessage()
 		if err != nil {
 			log.Printf("Read error for player %s: %v", playerID, err)
-			handleDisconnect(player)
+			s.handleDisconnect(player)
 			break
 		}
+		if s.cfg.MaxViolations > 0 && violations >= s.cfg.MaxViolations {
+			continue // Disconnecting; the next read fails.
+		}
 
-		handleMessage(player, msg)
+		msg, err := protocol.DecodeClient(data)
+		if err == nil && !limiter.allow(time.Now()) {
+			err = &protocol.Error{Code: protocol.RateLimited, Message: "Too many messages"}
+		}
+		if err != nil {
+			violations++
+			reply := protocol.Encode(err.(*protocol.Error))
+			if s.cfg.MaxViolations > 0 && violations >= s.cfg.MaxViolations {
+				log.Printf("Disconnecting player %s after %d protocol violations", playerID, violations)
+				client.SendAndClose(reply)
+			} else {
+				client.Send(reply)
+			}
+			continue
+		}
+		s.handleMessage(player, msg)
 	}
 }
 
-func matchPlayer(player *Player) {
-	roomMutex.Lock()
-	defer roomMutex.Unlock()
+// matchPlayer adds player to the waiting queue and pairs up whoever can be
+// matched now.
+func (s *Server) matchPlayer(player *Player) {
+	sendMessage(player.Client, &protocol.Waiting{
+		Message: "Waiting for another player...",
+	})
 
-	if len(waitingQueue) == 0 {
-		// No one waiting, add to queue
-		waitingQueue = append(waitingQueue, player)
-		sendMessage(player.Conn, Message{
-			Type:    "waiting",
-			Message: "Waiting for another player...",
-		})
-		log.Printf("Player %s added to waiting queue", player.ID)
+	entry := QueueEntry{
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Rating:   player.Rating,
+		QueuedAt: time.Now(),
+	}
+	err := s.rooms.UpdateQueue(func(queue []QueueEntry) ([]QueueEntry, error) {
+		return s.matchQueue(append(queue, entry)), nil
+	})
+	if err != nil {
+		log.Printf("Queueing player %s: %v", player.ID, err)
 		return
 	}
+	log.Printf("Player %s added to waiting queue", player.ID)
+}
 
-	// Match with waiting player
-	opponent := waitingQueue[0]
-	waitingQueue = waitingQueue[1:]
-
-	// Create new room
-	roomCounter++
-	roomID := generateRoomID(roomCounter)
-	room := &Room{
-		ID:      roomID,
-		Players: []*Player{opponent, player},
-		Board:   [3][3]string{{"", "", ""}, {"", "", ""}, {"", "", ""}},
-		Turn:    "X",
-		Status:  "playing",
-		Winner:  "",
+// createRoom starts a series between two players taken off the waiting
+// queue. x plays X in the first game.
+func (s *Server) createRoom(x, o QueueEntry) error {
+	room := s.newRoom(
+		&RoomPlayer{ID: x.PlayerID, Name: x.Name, Rating: x.Rating},
+		&RoomPlayer{ID: o.PlayerID, Name: o.Name, Rating: o.Rating},
+	)
+	if err := s.openRoom(room); err != nil {
+		return err
 	}
+	now := time.Now()
+	s.metrics.playerMatched(now.Sub(x.QueuedAt))
+	s.metrics.playerMatched(now.Sub(o.QueuedAt))
+	return nil
+}
 
-	// Assign symbols
-	opponent.Symbol = "X"
-	opponent.RoomID = roomID
-	opponent.IsReady = true
-	player.Symbol = "O"
-	player.RoomID = roomID
-	player.IsReady = true
+// newRoom returns a room, with the server's settings, for a series between
+// x and o, who play X and O in the first game.
+func (s *Server) newRoom(x, o *RoomPlayer) *Room {
+	x.Symbol, o.Symbol = engine.X, engine.O
+	return &Room{
+		Game:       engine.New(),
+		ID:         generateRoomID(),
+		Players:    []*RoomPlayer{x, o},
+		Status:     "playing",
+		BestOf:     s.cfg.BestOf,
+		GameNumber: 1,
+		Scores:     map[string]int{x.ID: 0, o.ID: 0},
+		MoveTime:   s.cfg.MoveTime,
+		GameTime:   s.cfg.GameTime,
+	}
+}
 
-	rooms[roomID] = room
+// openRoom saves a new room, starts its first game and tells the players.
+func (s *Server) openRoom(room *Room) error {
+	startRecording(room)
+	s.resetClocks(room)
+	if err := s.rooms.Create(room); err != nil {
+		s.stopClock(room)
+		return err
+	}
+	s.metrics.gameStarted()
 
-	log.Printf("Room %s created with players %s (X) and %s (O)", roomID, opponent.ID, player.ID)
+	x, o := room.Players[0], room.Players[1]
+	log.Printf("Room %s created with players %s (X, %d) and %s (O, %d)", room.ID, x.ID, x.Rating, o.ID, o.Rating)
 
 	// Notify both players
-	sendMessage(opponent.Conn, Message{
-		Type:    "matched",
-		PlayerID: opponent.ID,
-		RoomID:  roomID,
-		Symbol:  "X",
-		Board:   room.Board,
-		Turn:    room.Turn,
-		Status:  room.Status,
-		Message: "Game started! You are X. Your turn.",
-	})
-
-	sendMessage(player.Conn, Message{
-		Type:    "matched",
-		PlayerID: player.ID,
-		RoomID:  roomID,
-		Symbol:  "O",
-		Board:   room.Board,
-		Turn:    room.Turn,
-		Status:  room.Status,
-		Message: "Game started! You are O. Waiting for X...",
-	})
+	s.sendMatched(room)
+	return nil
 }
 
-func handleMessage(player *Player, msg Message) {
-	roomMutex.Lock()
-	defer roomMutex.Unlock()
+// sendMatched tells both players of room that a game has started and which
+// symbol they are playing. X always opens.
+func (s *Server) sendMatched(room *Room) {
+	clock := clockState(room)
+	for _, p := range room.Players {
+		text := fmt.Sprintf("Game started! You are %s. Waiting for X...", p.Symbol)
+		if p.Symbol == room.Turn {
+			text = fmt.Sprintf("Game started! You are %s. Your turn.", p.Symbol)
+		}
+		if room.BestOf > 1 {
+			text = fmt.Sprintf("Game %d of %d. %s", room.GameNumber, room.BestOf, text)
+		}
 
-	room, exists := rooms[player.RoomID]
-	if !exists {
-		sendMessage(player.Conn, Message{
-			Type:  "error",
-			Error: "Room not found",
+		s.publish(p.ID, &protocol.Matched{
+			RoomID:   room.ID,
+			PlayerID: p.ID,
+			Symbol:   p.Symbol,
+			Board:    room.Board,
+			Turn:     room.Turn,
+			Status:   room.Status,
+			Message:  text,
+			Series:   series(room),
+			Clock:    clock,
+
+			Name:           p.Name,
+			Rating:         p.Rating,
+			OpponentName:   opponentOf(room, p).Name,
+			OpponentRating: opponentOf(room, p).Rating,
+
+			Chat:         room.Chat,
+			TournamentID: room.TournamentID,
 		})
-		return
 	}
+	s.updateSpectators(room, "")
+}
 
-	switch msg.Type {
-	case "move":
-		handleMove(player, room, msg)
+// opponentOf returns the other player in p's room.
+func opponentOf(room *Room, p *RoomPlayer) *RoomPlayer {
+	for _, o := range room.Players {
+		if o != p {
+			return o
+		}
 	}
+	return p
 }
 
-func handleMove(player *Player, room *Room, msg Message) {
-	room.mutex.Lock()
-	defer room.mutex.Unlock()
+// series returns the state of room's series.
+func series(room *Room) protocol.Series {
+	return protocol.Series{BestOf: room.BestOf, Game: room.GameNumber, Scores: room.Scores}
+}
 
-	// Validate it's player's turn
-	if room.Turn != player.Symbol {
-		sendMessage(player.Conn, Message{
-			Type:  "error",
-			Error: "Not your turn",
-		})
-		return
+// handleMessage handles a decoded message from a player or spectator.
+func (s *Server) handleMessage(player *Player, msg protocol.Payload) {
+	err := s.rooms.Update(s.roomOf(player), func(room *Room) error {
+		seat := room.player(player.ID)
+		if seat == nil && !room.isSpectator(player.ID) {
+			return ErrRoomNotFound
+		}
+
+		switch msg := msg.(type) {
+		case *protocol.Move:
+			if seat == nil {
+				sendError(player.Client, protocol.Forbidden, "Spectators can't play")
+				return nil
+			}
+			s.handleMove(player, seat, room, msg)
+		case *protocol.Rematch:
+			if seat == nil {
+				sendError(player.Client, protocol.Forbidden, "Spectators can't play")
+				return nil
+			}
+			s.handleRematch(seat, room)
+		case *protocol.OfferDraw, *protocol.RequestUndo, *protocol.AcceptOffer, *protocol.DeclineOffer, *protocol.Resign:
+			if seat == nil {
+				sendError(player.Client, protocol.Forbidden, "Spectators can't play")
+				return nil
+			}
+			switch msg.(type) {
+			case *protocol.OfferDraw:
+				s.handleOffer(player, seat, room, drawOffer)
+			case *protocol.RequestUndo:
+				s.handleOffer(player, seat, room, undoRequest)
+			case *protocol.AcceptOffer:
+				s.handleAnswer(player, seat, room, true)
+			case *protocol.DeclineOffer:
+				s.handleAnswer(player, seat, room, false)
+			case *protocol.Resign:
+				s.handleResign(player, seat, room)
+			}
+		case *protocol.Chat:
+			s.handleChat(player, room, msg)
+		case *protocol.Reaction:
+			s.handleReaction(player, room, msg)
+		default:
+			// Every kind in protocol.ClientKinds is handled above.
+			sendError(player.Client, protocol.UnknownType, fmt.Sprintf("unexpected message type %q", msg.Kind()))
+		}
+		return nil
+	})
+	if err == ErrRoomNotFound {
+		sendError(player.Client, protocol.NotFound, "Room not found")
+	} else if err != nil {
+		log.Printf("Handling %q from player %s: %v", msg.Kind(), player.ID, err)
+		sendError(player.Client, protocol.ServerError, "Server error")
 	}
+}
 
-	// Validate move coordinates
-	if msg.Row < 0 || msg.Row > 2 || msg.Col < 0 || msg.Col > 2 {
-		sendMessage(player.Conn, Message{
-			Type:  "error",
-			Error: "Invalid coordinates",
-		})
-		return
+// moveErrors is the text shown to a player for each illegal move.
+var moveErrors = map[error]string{
+	engine.ErrGameOver:    "Game is not in progress",
+	engine.ErrNotYourTurn: "Not your turn",
+	engine.ErrOutOfBounds: "Invalid coordinates",
+	engine.ErrOccupied:    "Cell already occupied",
+}
+
+// handleMove applies a move by player, sitting at seat, to room.
+func (s *Server) handleMove(player *Player, seat *RoomPlayer, room *Room, msg *protocol.Move) {
+	// A move that arrives after the player's time ran out is rejected; the
+	// pending timer ends the game.
+	if room.Turn == seat.Symbol {
+		if limit := turnLimit(room); limit > 0 && time.Since(room.TurnStarted) >= limit {
+			sendError(player.Client, protocol.IllegalMove, "Out of time")
+			return
+		}
 	}
 
-	// Validate cell is empty
-	if room.Board[msg.Row][msg.Col] != "" {
-		sendMessage(player.Conn, Message{
-			Type:  "error",
-			Error: "Cell already occupied",
-		})
+	result, err := room.Play(seat.Symbol, msg.Row, msg.Col)
+	if err != nil {
+		sendError(player.Client, protocol.IllegalMove, moveErrors[err])
 		return
 	}
+	chargeClock(room, seat.Symbol)
+	room.MoveTimes = append(room.MoveTimes, time.Now())
+	s.expireOffer(room)
 
-	// Make move
-	room.Board[msg.Row][msg.Col] = player.Symbol
-
-	// Check for win or draw
-	winner := checkWinner(room.Board)
-	if winner != "" {
-		room.Status = "finished"
-		room.Winner = winner
-		room.Turn = ""
-	} else if isBoardFull(room.Board) {
-		room.Status = "finished"
-		room.Winner = "draw"
-		room.Turn = ""
+	if result == engine.InProgress {
+		s.startClock(room)
 	} else {
-		// Switch turn
-		if room.Turn == "X" {
-			room.Turn = "O"
-		} else {
-			room.Turn = "X"
+		s.finishGame(room, "")
+	}
+
+	s.broadcastUpdate(room, "")
+}
+
+// finishGame settles a game the engine has just ended: it marks the room
+// finished, credits the winner's series score, stops the clocks, expires
+// any pending offer, records the game and updates ratings. reason is as for
+// broadcastUpdate.
+func (s *Server) finishGame(room *Room, reason string) {
+	room.Status = "finished"
+	s.expireOffer(room)
+	for _, p := range room.Players {
+		if p.Symbol == room.Winner {
+			room.Scores[p.ID]++
 		}
 	}
+	s.stopClock(room)
+	s.metrics.gameFinished(time.Since(room.StartedAt))
+	s.recordGame(room, reason)
+	s.rateGame(room)
+	s.settleMatch(room, "")
+	if room.TournamentID != "" && !room.MatchSettled {
+		s.scheduleNextGame(room)
+	}
+}
 
-	// Broadcast update to both players
+// broadcastUpdate sends the room's current state to both players. reason is
+// empty for games decided on the board, "timeout" when a clock ran out,
+// "disconnect" when a player left mid-game, "resignation" or "agreement"
+// for a resigned or agreed game, and "undo" after a move was taken back.
+func (s *Server) broadcastUpdate(room *Room, reason string) {
+	clock := clockState(room)
 	for _, p := range room.Players {
 		statusMsg := room.Status
 		if room.Status == "finished" {
-			if room.Winner == "draw" {
+			if room.Winner == engine.Draw {
 				statusMsg = "Game ended in a draw!"
+				if reason == "agreement" {
+					statusMsg = "Draw agreed."
+				}
 			} else if room.Winner == p.Symbol {
 				statusMsg = "You won!"
+				switch reason {
+				case "timeout":
+					statusMsg = "Opponent ran out of time. You won!"
+				case "resignation":
+					statusMsg = "Opponent resigned. You won!"
+				}
 			} else {
 				statusMsg = "You lost!"
+				switch reason {
+				case "timeout":
+					statusMsg = "You ran out of time!"
+				case "resignation":
+					statusMsg = "You resigned."
+				}
 			}
 		} else if room.Turn == p.Symbol {
 			statusMsg = "Your turn"
@@ -277,101 +620,177 @@ func handleMove(player *Player, room *Room, msg Message) {
 			statusMsg = "Opponent's turn"
 		}
 
-		sendMessage(p.Conn, Message{
-			Type:    "update",
-			Board:   room.Board,
-			Turn:    room.Turn,
-			Status:  statusMsg,
-			Winner:  room.Winner,
-			Message: statusMsg,
+		s.publish(p.ID, &protocol.Update{
+			Board:        room.Board,
+			Turn:         room.Turn,
+			Status:       statusMsg,
+			Winner:       room.Winner,
+			Series:       series(room),
+			SeriesWinner: seriesWinner(room),
+			Clock:        clock,
+			Reason:       reason,
+			Rating:       p.Rating,
 		})
 	}
+	s.updateSpectators(room, reason)
 }
 
-func checkWinner(board [3][3]string) string {
-	// Check rows
-	for i := 0; i < 3; i++ {
-		if board[i][0] != "" && board[i][0] == board[i][1] && board[i][1] == board[i][2] {
-			return board[i][0]
-		}
+// handleRematch records player's vote for another game. Once every player in
+// the room has asked for a rematch the board is reset and the symbols are
+// swapped, so the player who moved second last game opens the next one.
+// A rematch after the series has been decided starts a new series, except
+// in tournament rooms, whose series is the match. Games of an undecided
+// tournament match also start without votes; see scheduleNextGame.
+func (s *Server) handleRematch(player *RoomPlayer, room *Room) {
+	if room.Status != "finished" {
+		s.publish(player.ID, &protocol.Error{
+			Code:    protocol.Invalid,
+			Message: "Game is still in progress",
+		})
+		return
+	}
+	if room.MatchSettled {
+		s.publish(player.ID, &protocol.Error{
+			Code:    protocol.Invalid,
+			Message: "The tournament match is over",
+		})
+		return
 	}
 
-	// Check columns
-	for i := 0; i < 3; i++ {
-		if board[0][i] != "" && board[0][i] == board[1][i] && board[1][i] == board[2][i] {
-			return board[0][i]
+	if room.Rematch == nil {
+		room.Rematch = make(map[string]bool)
+	}
+	room.Rematch[player.ID] = true
+
+	if len(room.Rematch) < len(room.Players) {
+		for _, p := range room.Players {
+			text := "Waiting for opponent to accept the rematch..."
+			if p.ID != player.ID {
+				text = "Opponent wants a rematch"
+			}
+			s.publish(p.ID, &protocol.RematchStatus{
+				RoomID:  room.ID,
+				Message: text,
+			})
 		}
+		return
 	}
 
-	// Check diagonals
-	if board[0][0] != "" && board[0][0] == board[1][1] && board[1][1] == board[2][2] {
-		return board[0][0]
+	if seriesWinner(room) != "" {
+		for id := range room.Scores {
+			room.Scores[id] = 0
+		}
+		room.GameNumber = 0
 	}
-	if board[0][2] != "" && board[0][2] == board[1][1] && board[1][1] == board[2][0] {
-		return board[0][2]
+	s.startNextGame(room)
+}
+
+// startNextGame resets the board of a finished room for the next game of
+// its series, swapping the players' symbols.
+func (s *Server) startNextGame(room *Room) {
+	for _, p := range room.Players {
+		p.Symbol = engine.Other(p.Symbol)
 	}
+	room.Game = engine.New()
+	room.Status = "playing"
+	room.GameNumber++
+	room.Rematch = nil
+	startRecording(room)
+	s.resetClocks(room)
 
-	return ""
+	s.metrics.gameStarted()
+	log.Printf("Room %s starting game %d of %d", room.ID, room.GameNumber, room.BestOf)
+
+	s.sendMatched(room)
 }
 
-func isBoardFull(board [3][3]string) bool {
-	for i := 0; i < 3; i++ {
-		for j := 0; j < 3; j++ {
-			if board[i][j] == "" {
-				return false
-			}
+// seriesWinner returns the ID of the player who has won a majority of the
+// room's best-of-N series, or "" if the series is still open.
+func seriesWinner(room *Room) string {
+	for id, wins := range room.Scores {
+		if wins > room.BestOf/2 {
+			return id
 		}
 	}
-	return true
+	return ""
 }
 
-func handleDisconnect(player *Player) {
-	roomMutex.Lock()
-	defer roomMutex.Unlock()
-
-	playerMutex.Lock()
-	delete(players, player.ID)
-	playerMutex.Unlock()
+func (s *Server) handleDisconnect(player *Player) {
+	s.mu.Lock()
+	delete(s.players, player.ID)
+	roomID := player.RoomID
+	s.mu.Unlock()
 
 	// Remove from waiting queue if present
-	for i, p := range waitingQueue {
-		if p.ID == player.ID {
-			waitingQueue = append(waitingQueue[:i], waitingQueue[i+1:]...)
-			break
+	err := s.rooms.UpdateQueue(func(queue []QueueEntry) ([]QueueEntry, error) {
+		for i, e := range queue {
+			if e.PlayerID == player.ID {
+				return append(queue[:i], queue[i+1:]...), nil
+			}
 		}
+		return queue, nil
+	})
+	if err != nil {
+		log.Printf("Removing player %s from queue: %v", player.ID, err)
+	}
+	if player.TournamentID != "" {
+		s.leaveTournament(player)
 	}
 
-	// Handle room cleanup
-	if player.RoomID != "" {
-		room, exists := rooms[player.RoomID]
-		if exists {
-			// Notify opponent
+	if player.Spectating {
+		s.leaveRoom(player, roomID)
+	} else if roomID != "" {
+		err := s.rooms.Update(roomID, func(room *Room) error {
+			s.stopClock(room)
+			// Leaving mid-game forfeits it.
+			if seat := room.player(player.ID); seat != nil && room.Forfeit(seat.Symbol) == nil {
+				s.finishGame(room, "disconnect")
+			}
+			s.settleMatch(room, player.ID)
+
+			// Notify opponent and spectators
 			for _, p := range room.Players {
 				if p.ID != player.ID {
-					sendMessage(p.Conn, Message{
-						Type:    "opponent_disconnected",
+					s.publish(p.ID, &protocol.OpponentDisconnected{
 						Message: "Opponent disconnected",
 					})
 				}
 			}
-			delete(rooms, player.RoomID)
+			for _, id := range room.Spectators {
+				s.publish(id, &protocol.RoomClosed{
+					RoomID:  room.ID,
+					Message: "A player left the game",
+				})
+			}
+			return nil
+		})
+		if err != nil && err != ErrRoomNotFound {
+			log.Printf("Closing room %s: %v", roomID, err)
+		}
+		if err := s.rooms.Delete(roomID); err != nil {
+			log.Printf("Deleting room %s: %v", roomID, err)
 		}
 	}
 
 	log.Printf("Player %s disconnected", player.ID)
 }
 
-func sendMessage(conn *websocket.Conn, msg Message) {
-	if err := conn.WriteJSON(msg); err != nil {
-		log.Printf("Write error: %v", err)
-	}
+// sendMessage queues msg for delivery to a client connected to this node.
+// It never blocks, so it is safe to call from inside RoomStore.Update.
+func sendMessage(client *Client, msg protocol.Payload) {
+	client.Send(protocol.Encode(msg))
+}
+
+// sendError sends an error reply with the given protocol error code.
+func sendError(client *Client, code, text string) {
+	sendMessage(client, &protocol.Error{Code: code, Message: text})
 }
 
 func generatePlayerID() string {
 	return "player_" + randomString(8)
 }
 
-func generateRoomID(counter int) string {
+func generateRoomID() string {
 	return "room_" + randomString(6)
 }
 
@@ -389,4 +808,3 @@ func randomString(length int) string {
 	}
 	return encoded
 }
-
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Waiting players are paired with the closest-rated opponent inside a rating
+// window. The window starts narrow and widens the longer a player waits, so
+// nobody is stuck in the queue for lack of an evenly matched opponent.
+const (
+	matchWindow     = 100
+	matchWidenBy    = 50
+	matchWidenEvery = 5 * time.Second
+	matchInterval   = time.Second
+)
+
+// matchWindowFor returns how far from e's rating an opponent may be, given
+// how long e has been waiting.
+func matchWindowFor(e QueueEntry, now time.Time) int {
+	return matchWindow + matchWidenBy*int(now.Sub(e.QueuedAt)/matchWidenEvery)
+}
+
+// matchQueue pairs up as many waiting players as the current windows allow,
+// creates a room for each pair and returns who is left waiting. The queue is
+// in arrival order, so the longest-waiting player picks first, using their
+// (widest) window. It must be called from inside RoomStore.UpdateQueue.
+func (s *Server) matchQueue(queue []QueueEntry) []QueueEntry {
+	now := time.Now()
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+		window := matchWindowFor(p, now)
+
+		best, bestDiff := -1, 0
+		for j := i + 1; j < len(queue); j++ {
+			diff := abs(p.Rating - queue[j].Rating)
+			if diff <= window && (best < 0 || diff < bestDiff) {
+				best, bestDiff = j, diff
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		opponent := queue[best]
+		if err := s.createRoom(p, opponent); err != nil {
+			log.Printf("Creating room for %s and %s: %v", p.PlayerID, opponent.PlayerID, err)
+			continue
+		}
+		queue = append(queue[:best], queue[best+1:]...)
+		queue = append(queue[:i], queue[i+1:]...)
+		i--
+	}
+	return queue
+}
+
+// runMatchmaker periodically retries matching so that widening windows
+// eventually pair players even when nobody new joins, and schedules
+// tournament matches so that players who don't turn up forfeit.
+func (s *Server) runMatchmaker() {
+	ticker := time.NewTicker(matchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		err := s.rooms.UpdateQueue(func(queue []QueueEntry) ([]QueueEntry, error) {
+			return s.matchQueue(queue), nil
+		})
+		if err != nil {
+			log.Printf("Matching players: %v", err)
+		}
+		s.sweepTournaments(time.Now())
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
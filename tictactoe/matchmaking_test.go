@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		waited time.Duration
+		want   int
+	}{
+		{0, 100},
+		{matchWidenEvery - time.Millisecond, 100},
+		{matchWidenEvery, 150},
+		{12 * time.Second, 200},
+		{time.Minute, 700},
+	}
+	for _, tt := range tests {
+		if got := matchWindowFor(QueueEntry{QueuedAt: now.Add(-tt.waited)}, now); got != tt.want {
+			t.Errorf("window after %v = %d, want %d", tt.waited, got, tt.want)
+		}
+	}
+}
+
+func TestMatchQueue(t *testing.T) {
+	type player struct {
+		id     string
+		rating int
+		waited time.Duration
+	}
+	tests := []struct {
+		desc    string
+		players []player
+		pairs   []string // "a-b" for a room with a as X and b as O
+		left    string
+	}{
+		{"within the window", []player{{"a", 1200, 0}, {"b", 1300, 0}}, []string{"a-b"}, ""},
+		{"outside the window", []player{{"a", 1200, 0}, {"b", 1301, 0}}, nil, "a b"},
+		{"window widened by waiting", []player{{"a", 1200, 11 * time.Second}, {"b", 1400, 0}}, []string{"a-b"}, ""},
+		{"window not yet widened", []player{{"a", 1200, 9 * time.Second}, {"b", 1400, 0}}, nil, "a b"},
+		{"closest opponent", []player{{"a", 1200, 0}, {"b", 1290, 0}, {"c", 1150, 0}}, []string{"a-c"}, "b"},
+		{"longest waiting picks first", []player{{"a", 1200, 5 * time.Second}, {"b", 1340, 0}, {"c", 1400, 0}}, []string{"a-b"}, "c"},
+		{"every pair", []player{{"a", 1200, 0}, {"b", 1900, 0}, {"c", 1210, 0}, {"d", 1850, 0}, {"e", 1500, 0}}, []string{"a-c", "b-d"}, "e"},
+	}
+	for _, tt := range tests {
+		s := NewServer(Config{BestOf: 1}, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory(), NewAccountStore(""))
+		now := time.Now()
+		var queue []QueueEntry
+		for _, p := range tt.players {
+			queue = append(queue, QueueEntry{PlayerID: p.id, Rating: p.rating, QueuedAt: now.Add(-p.waited)})
+		}
+
+		var left []string
+		for _, e := range s.matchQueue(queue) {
+			left = append(left, e.PlayerID)
+		}
+		rooms, err := s.rooms.Rooms()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pairs []string
+		for _, room := range rooms {
+			pairs = append(pairs, room.Players[0].ID+"-"+room.Players[1].ID)
+		}
+		sort.Strings(pairs)
+		if strings.Join(pairs, " ") != strings.Join(tt.pairs, " ") || strings.Join(left, " ") != tt.left {
+			t.Errorf("%s: paired %v, left %v; want %v, left %v", tt.desc, pairs, left, tt.pairs, tt.left)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics counts games and matches handled by one server node.
+type Metrics struct {
+	mu            sync.Mutex
+	gamesStarted  int64
+	gamesFinished int64
+	gameTime      time.Duration // total length of finished games
+	matched       int64         // players matched from the queue
+	queueWait     time.Duration // total time matched players waited
+}
+
+// MetricsSnapshot is the state of a node reported by GET /admin/metrics.
+type MetricsSnapshot struct {
+	ConnectedPlayers int     `json:"connectedPlayers"`
+	WaitingPlayers   int     `json:"waitingPlayers"`
+	ActiveRooms      int     `json:"activeRooms"`
+	GamesStarted     int64   `json:"gamesStarted"`
+	GamesFinished    int64   `json:"gamesFinished"`
+	AvgGameSeconds   float64 `json:"avgGameSeconds"`
+	AvgQueueSeconds  float64 `json:"avgQueueWaitSeconds"`
+}
+
+func (m *Metrics) gameStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gamesStarted++
+}
+
+func (m *Metrics) gameFinished(length time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gamesFinished++
+	m.gameTime += length
+}
+
+// playerMatched records how long a player waited in the queue.
+func (m *Metrics) playerMatched(wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matched++
+	m.queueWait += wait
+}
+
+// snapshot fills in the counters of snap.
+func (m *Metrics) snapshot(snap *MetricsSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap.GamesStarted = m.gamesStarted
+	snap.GamesFinished = m.gamesFinished
+	if m.gamesFinished > 0 {
+		snap.AvgGameSeconds = m.gameTime.Seconds() / float64(m.gamesFinished)
+	}
+	if m.matched > 0 {
+		snap.AvgQueueSeconds = m.queueWait.Seconds() / float64(m.matched)
+	}
+}
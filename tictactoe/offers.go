@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+// Kinds of offer a player can make to their opponent.
+const (
+	drawOffer   = "draw"
+	undoRequest = "undo"
+)
+
+// offerLimit is how many offers each player may make in any rateWindow, so
+// that a declined offer can't be repeated endlessly.
+const offerLimit = 3
+
+// PendingOffer is a draw offer or undo request waiting for the opponent's
+// answer. A room has at most one, and it expires when a move is made or
+// the game ends.
+type PendingOffer struct {
+	Kind string `json:"kind"` // drawOffer or undoRequest
+	From string `json:"from"` // ID of the player who made it
+}
+
+// offerMessages is the text shown for each kind of offer in each state, to
+// the player who made it and to their opponent.
+var offerMessages = map[string]map[string][2]string{
+	drawOffer: {
+		"pending":  {"Draw offered. Waiting for your opponent...", "Your opponent offers a draw"},
+		"accepted": {"Your opponent accepted the draw", "You accepted the draw"},
+		"declined": {"Your opponent declined the draw", "You declined the draw"},
+		"expired":  {"Your draw offer expired", "The draw offer expired"},
+	},
+	undoRequest: {
+		"pending":  {"Undo requested. Waiting for your opponent...", "Your opponent asks to take back their last move"},
+		"accepted": {"Your opponent let you take back your move", "You let your opponent take back their move"},
+		"declined": {"Your opponent declined the undo", "You declined the undo"},
+		"expired":  {"Your undo request expired", "The undo request expired"},
+	},
+}
+
+// handleOffer records a draw offer or undo request from player, sitting at
+// seat, and asks their opponent to answer it.
+func (s *Server) handleOffer(player *Player, seat *RoomPlayer, room *Room, kind string) {
+	switch {
+	case room.Status != "playing":
+		sendError(player.Client, protocol.Invalid, "Game is not in progress")
+		return
+	case room.Offer != nil:
+		sendError(player.Client, protocol.Invalid, "An offer is already waiting for an answer")
+		return
+	case kind == undoRequest && (len(room.Moves) == 0 || room.Turn == seat.Symbol):
+		// The last move is the player's own only while their opponent is
+		// to move.
+		sendError(player.Client, protocol.Invalid, "You can only take back your move before your opponent replies")
+		return
+	}
+
+	if room.OfferSent == nil {
+		room.OfferSent = make(map[string][]time.Time)
+	}
+	sent, ok := allowRate(room.OfferSent[seat.ID], time.Now(), offerLimit)
+	room.OfferSent[seat.ID] = sent
+	if !ok {
+		sendError(player.Client, protocol.RateLimited, "Too many offers. Wait a few seconds.")
+		return
+	}
+
+	room.Offer = &PendingOffer{Kind: kind, From: seat.ID}
+	s.publishOffer(room, room.Offer, "pending")
+}
+
+// handleAnswer accepts or declines the offer waiting for seat's answer.
+func (s *Server) handleAnswer(player *Player, seat *RoomPlayer, room *Room, accept bool) {
+	offer := room.Offer
+	if offer == nil || offer.From == seat.ID {
+		sendError(player.Client, protocol.Invalid, "There is no offer to answer")
+		return
+	}
+	room.Offer = nil
+	if !accept {
+		s.publishOffer(room, offer, "declined")
+		return
+	}
+	s.publishOffer(room, offer, "accepted")
+	log.Printf("Room %s: %s accepted %s offer", room.ID, seat.Symbol, offer.Kind)
+
+	switch offer.Kind {
+	case drawOffer:
+		room.AgreeDraw()
+		s.finishGame(room, "agreement")
+		s.broadcastUpdate(room, "agreement")
+	case undoRequest:
+		chargeClock(room, room.Turn)
+		room.Undo()
+		room.MoveTimes = room.MoveTimes[:len(room.MoveTimes)-1]
+		s.startClock(room)
+		s.broadcastUpdate(room, "undo")
+	}
+}
+
+// handleResign ends the game as a loss for the player at seat.
+func (s *Server) handleResign(player *Player, seat *RoomPlayer, room *Room) {
+	if room.Forfeit(seat.Symbol) != nil {
+		sendError(player.Client, protocol.Invalid, "Game is not in progress")
+		return
+	}
+	log.Printf("Room %s: %s resigned", room.ID, seat.Symbol)
+	s.finishGame(room, "resignation")
+	s.broadcastUpdate(room, "resignation")
+}
+
+// expireOffer withdraws the room's pending offer, if any, because a move
+// was made or the game ended.
+func (s *Server) expireOffer(room *Room) {
+	if room.Offer == nil {
+		return
+	}
+	offer := room.Offer
+	room.Offer = nil
+	s.publishOffer(room, offer, "expired")
+}
+
+// publishOffer tells both players that offer is now in the given state.
+func (s *Server) publishOffer(room *Room, offer *PendingOffer, status string) {
+	text := offerMessages[offer.Kind][status]
+	for _, p := range room.Players {
+		message := text[1]
+		if p.ID == offer.From {
+			message = text[0]
+		}
+		s.publish(p.ID, &protocol.Offer{
+			RoomID:  room.ID,
+			Offer:   offer.Kind,
+			From:    offer.From,
+			Status:  status,
+			Message: message,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"tictactoe/protocol"
+)
+
+func TestOffers(t *testing.T) {
+	url := startNode(t, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+
+	alice := dialPlayer(t, url)
+	expect[*protocol.Waiting](alice)
+	bob := dialPlayer(t, url)
+	expect[*protocol.Matched](alice)
+	expect[*protocol.Matched](bob)
+	both := []*testPlayer{alice, bob}
+
+	expectOffer := func(offer, status string) {
+		t.Helper()
+		for _, p := range both {
+			if msg := expect[*protocol.Offer](p); msg.Offer != offer || msg.Status != status {
+				t.Fatalf("offer %q %q, want %q %q", msg.Offer, msg.Status, offer, status)
+			}
+		}
+	}
+	expectError := func(p *testPlayer, code string) {
+		t.Helper()
+		if msg := expect[*protocol.Error](p); msg.Code != code {
+			t.Fatalf("error %q (%s), want %q", msg.Code, msg.Message, code)
+		}
+	}
+
+	alice.send(&protocol.Move{Row: 0, Col: 0})
+	expect[*protocol.Update](alice)
+	expect[*protocol.Update](bob)
+
+	// Only the player who moved last can ask to take it back.
+	bob.send(&protocol.RequestUndo{})
+	expectError(bob, protocol.Invalid)
+
+	alice.send(&protocol.RequestUndo{})
+	expectOffer("undo", "pending")
+	alice.send(&protocol.AcceptOffer{})
+	expectError(alice, protocol.Invalid)
+	bob.send(&protocol.DeclineOffer{})
+	expectOffer("undo", "declined")
+
+	alice.send(&protocol.RequestUndo{})
+	expectOffer("undo", "pending")
+	bob.send(&protocol.AcceptOffer{})
+	expectOffer("undo", "accepted")
+	for _, p := range both {
+		if u := expect[*protocol.Update](p); u.Board != ([3][3]string{}) || u.Turn != "X" || u.Reason != "undo" {
+			t.Fatalf("after undo: board %v, turn %q, reason %q", u.Board, u.Turn, u.Reason)
+		}
+	}
+
+	// A move expires a pending offer.
+	alice.send(&protocol.OfferDraw{})
+	expectOffer("draw", "pending")
+	alice.send(&protocol.Move{Row: 1, Col: 1})
+	expectOffer("draw", "expired")
+	expect[*protocol.Update](alice)
+	expect[*protocol.Update](bob)
+	bob.send(&protocol.AcceptOffer{})
+	expectError(bob, protocol.Invalid)
+
+	bob.send(&protocol.OfferDraw{})
+	expectOffer("draw", "pending")
+	bob.send(&protocol.OfferDraw{})
+	expectError(bob, protocol.Invalid)
+	alice.send(&protocol.AcceptOffer{})
+	expectOffer("draw", "accepted")
+	if u := expect[*protocol.Update](alice); u.Winner != "draw" || u.Status != "Draw agreed." || u.Reason != "agreement" {
+		t.Fatalf("after draw: winner %q, status %q, reason %q", u.Winner, u.Status, u.Reason)
+	}
+	expect[*protocol.Update](bob)
+	bob.send(&protocol.Resign{})
+	expectError(bob, protocol.Invalid)
+
+	// Symbols swap for the rematch, so bob is X.
+	alice.send(&protocol.Rematch{})
+	bob.send(&protocol.Rematch{})
+	expect[*protocol.Matched](alice)
+	expect[*protocol.Matched](bob)
+	bob.send(&protocol.Resign{})
+	if u := expect[*protocol.Update](alice); u.Winner != "O" || u.Status != "Opponent resigned. You won!" || u.Reason != "resignation" {
+		t.Fatalf("after resigning: winner %q, status %q, reason %q", u.Winner, u.Status, u.Reason)
+	}
+	if u := expect[*protocol.Update](bob); u.Status != "You resigned." {
+		t.Fatalf("resigner's status %q", u.Status)
+	}
+}
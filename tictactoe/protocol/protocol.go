@@ -0,0 +1,488 @@
+// Package protocol defines the messages exchanged between tictactoe clients
+// and the server over a websocket.
+//
+// Every message is a JSON Envelope naming its kind and carrying that kind's
+// payload:
+//
+//	{"type": "move", "payload": {"row": 0, "col": 2}}
+//
+// Each kind has its own payload type, listed in ClientKinds and ServerKinds.
+// Messages from clients are decoded strictly: unknown kinds, unknown fields
+// and missing required fields are errors. Messages from the server are
+// decoded leniently, ignoring unknown fields, so the server can add fields
+// without breaking older clients. PROTOCOL.md is generated from this
+// package; run go generate after changing it.
+package protocol
+
+//go:generate go test -run TestSpec -update
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version is the protocol version this package implements. The server
+// announces it in Connected; a client may ask for a version with the
+// ?protocol= query parameter when it connects, and is refused with an
+// UnsupportedVersion error if it isn't this one.
+const Version = 1
+
+// Envelope is the frame every message is sent in.
+type Envelope struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// A Payload is the body of one kind of message.
+type Payload interface {
+	Kind() string
+}
+
+// ClientKinds are the messages clients may send, by kind.
+var ClientKinds = map[string]func() Payload{
+	"move":          func() Payload { return new(Move) },
+	"rematch":       func() Payload { return new(Rematch) },
+	"chat":          func() Payload { return new(Chat) },
+	"reaction":      func() Payload { return new(Reaction) },
+	"offer_draw":    func() Payload { return new(OfferDraw) },
+	"request_undo":  func() Payload { return new(RequestUndo) },
+	"accept_offer":  func() Payload { return new(AcceptOffer) },
+	"decline_offer": func() Payload { return new(DeclineOffer) },
+	"resign":        func() Payload { return new(Resign) },
+}
+
+// ServerKinds are the messages the server sends, by kind.
+var ServerKinds = map[string]func() Payload{
+	"connected":             func() Payload { return new(Connected) },
+	"waiting":               func() Payload { return new(Waiting) },
+	"matched":               func() Payload { return new(Matched) },
+	"update":                func() Payload { return new(Update) },
+	"rematch":               func() Payload { return new(RematchStatus) },
+	"spectating":            func() Payload { return new(Spectating) },
+	"chat":                  func() Payload { return new(ChatPosted) },
+	"reaction":              func() Payload { return new(ReactionSent) },
+	"opponent_disconnected": func() Payload { return new(OpponentDisconnected) },
+	"room_closed":           func() Payload { return new(RoomClosed) },
+	"kicked":                func() Payload { return new(Kicked) },
+	"tournament":            func() Payload { return new(Tournament) },
+	"offer":                 func() Payload { return new(Offer) },
+	"error":                 func() Payload { return new(Error) },
+}
+
+// Encode wraps p in an Envelope.
+func Encode(p Payload) Envelope {
+	data, err := json.Marshal(p)
+	if err != nil {
+		// Payloads are plain structs that always marshal.
+		panic(fmt.Sprintf("protocol: encoding %s: %v", p.Kind(), err))
+	}
+	return Envelope{Type: p.Kind(), Payload: data}
+}
+
+// DecodeClient decodes a message sent by a client. Errors are *Error values
+// suitable for sending back to the client.
+func DecodeClient(data []byte) (Payload, error) {
+	var env Envelope
+	if err := decodeStrict(data, &env); err != nil {
+		return nil, &Error{Code: BadRequest, Message: fmt.Sprintf("invalid message: %v", err)}
+	}
+	if env.Type == "" {
+		return nil, &Error{Code: BadRequest, Message: "message has no type"}
+	}
+	return decode(env, ClientKinds, decodeStrict)
+}
+
+// DecodeServer decodes a message sent by the server. Fields it doesn't know
+// are ignored; a kind it doesn't know is an *Error with code UnknownType,
+// which clients may skip.
+func DecodeServer(data []byte) (Payload, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, &Error{Code: BadRequest, Message: fmt.Sprintf("invalid message: %v", err)}
+	}
+	return decode(env, ServerKinds, json.Unmarshal)
+}
+
+// decode returns the payload of env, which must be of a kind in kinds,
+// using unmarshal to decode it.
+func decode(env Envelope, kinds map[string]func() Payload, unmarshal func([]byte, any) error) (Payload, error) {
+	newPayload, ok := kinds[env.Type]
+	if !ok {
+		return nil, &Error{Code: UnknownType, Message: fmt.Sprintf("unknown message type %q", env.Type)}
+	}
+	p := newPayload()
+	payload := env.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	if err := unmarshal(payload, p); err != nil {
+		return nil, &Error{Code: BadRequest, Message: fmt.Sprintf("invalid %s payload: %v", env.Type, err)}
+	}
+	return p, nil
+}
+
+// decodeStrict decodes a single JSON value from data into v, rejecting
+// unknown fields and trailing data.
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after message")
+	}
+	return nil
+}
+
+// Error codes.
+const (
+	BadRequest         = "bad_request"         // malformed message
+	UnknownType        = "unknown_type"        // message kind not known
+	UnsupportedVersion = "unsupported_version" // requested protocol version not supported
+	NotFound           = "not_found"           // the room doesn't exist
+	IllegalMove        = "illegal_move"        // the move breaks the rules
+	Forbidden          = "forbidden"           // not allowed, e.g. a spectator moving
+	Invalid            = "invalid"             // well formed but unacceptable, e.g. chat too long
+	RateLimited        = "rate_limited"        // sending too quickly
+	ServerError        = "server_error"        // the server failed
+)
+
+// Client messages.
+
+// Move places the sender's symbol on the board.
+type Move struct {
+	// Row is the cell's row, from 0 to 2.
+	Row int `json:"row"`
+	// Col is the cell's column, from 0 to 2.
+	Col int `json:"col"`
+}
+
+func (*Move) Kind() string { return "move" }
+
+func (m *Move) UnmarshalJSON(data []byte) error {
+	// Decoding into pointers tells a missing coordinate from 0.
+	var v struct {
+		Row *int `json:"row"`
+		Col *int `json:"col"`
+	}
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if v.Row == nil || v.Col == nil {
+		return errors.New("row and col are required")
+	}
+	m.Row, m.Col = *v.Row, *v.Col
+	return nil
+}
+
+// Rematch asks for another game once the current one has finished.
+type Rematch struct{}
+
+func (*Rematch) Kind() string { return "rematch" }
+
+// Chat posts a message to everyone in the room.
+type Chat struct {
+	// Text is the message, at most 200 characters.
+	Text string `json:"text"`
+}
+
+func (*Chat) Kind() string { return "chat" }
+
+// Reaction sends a quick reaction to everyone in the room.
+type Reaction struct {
+	// Reaction is one of thumbs_up, laugh, wow, sad, fire or clap.
+	Reaction string `json:"reaction"`
+}
+
+func (*Reaction) Kind() string { return "reaction" }
+
+// OfferDraw offers the opponent a draw. The game ends drawn if they
+// accept before the next move.
+type OfferDraw struct{}
+
+func (*OfferDraw) Kind() string { return "offer_draw" }
+
+// RequestUndo asks the opponent to let the sender take back their last
+// move. It can only be sent while the opponent is to move.
+type RequestUndo struct{}
+
+func (*RequestUndo) Kind() string { return "request_undo" }
+
+// AcceptOffer accepts the opponent's pending draw offer or undo request.
+type AcceptOffer struct{}
+
+func (*AcceptOffer) Kind() string { return "accept_offer" }
+
+// DeclineOffer declines the opponent's pending draw offer or undo request.
+type DeclineOffer struct{}
+
+func (*DeclineOffer) Kind() string { return "decline_offer" }
+
+// Resign concedes the current game.
+type Resign struct{}
+
+func (*Resign) Kind() string { return "resign" }
+
+// Server messages.
+
+// Connected is the first message on every connection.
+type Connected struct {
+	// Protocol is the protocol version the server speaks.
+	Protocol int    `json:"protocol"`
+	PlayerID string `json:"playerId"`
+	// Name is empty for anonymous players.
+	Name    string `json:"name,omitempty"`
+	Rating  int    `json:"rating"`
+	Message string `json:"message"`
+}
+
+func (*Connected) Kind() string { return "connected" }
+
+// Waiting says the player is in the queue for an opponent.
+type Waiting struct {
+	Message string `json:"message"`
+}
+
+func (*Waiting) Kind() string { return "waiting" }
+
+// Series is the state of a best-of-N series.
+type Series struct {
+	BestOf int `json:"bestOf"`
+	// Game is the number of the current game in the series, from 1.
+	Game int `json:"game"`
+	// Scores holds each player's wins, by player ID.
+	Scores map[string]int `json:"scores"`
+}
+
+// Clock is the time left in a game, in milliseconds. Fields are omitted
+// when the room has no such limit or the game is over.
+type Clock struct {
+	// MoveTimeLeft is the time left for the current move.
+	MoveTimeLeft int64 `json:"moveTimeLeft,omitempty"`
+	// Clocks holds each symbol's remaining game time.
+	Clocks map[string]int64 `json:"clocks,omitempty"`
+}
+
+// Matched says a game has started: either the first game with a new
+// opponent, or a rematch.
+type Matched struct {
+	RoomID   string       `json:"roomId"`
+	PlayerID string       `json:"playerId"`
+	Symbol   string       `json:"symbol"`
+	Board    [3][3]string `json:"board"`
+	Turn     string       `json:"turn"`
+	Status   string       `json:"status"`
+	Message  string       `json:"message"`
+	Series
+	Clock
+	Name           string `json:"name,omitempty"`
+	Rating         int    `json:"rating"`
+	OpponentName   string `json:"opponentName,omitempty"`
+	OpponentRating int    `json:"opponentRating"`
+	// Chat is the room's recent chat, oldest first.
+	Chat []ChatMessage `json:"chat"`
+	// TournamentID is set when the game is part of a tournament match.
+	TournamentID string `json:"tournamentId,omitempty"`
+}
+
+func (*Matched) Kind() string { return "matched" }
+
+// Update is the state of the game after a move or when it ends.
+type Update struct {
+	Board [3][3]string `json:"board"`
+	Turn  string       `json:"turn"`
+	// Status describes the game from the recipient's point of view.
+	Status string `json:"status"`
+	// Winner is X, O or draw once the game is over.
+	Winner string `json:"winner,omitempty"`
+	Series
+	// SeriesWinner is the ID of the player who won the series, if decided.
+	SeriesWinner string `json:"seriesWinner,omitempty"`
+	Clock
+	// Reason is timeout, disconnect, resignation or agreement for games
+	// not decided on the board, or undo after a move was taken back.
+	Reason string `json:"reason,omitempty"`
+	// Rating is the recipient's rating, which changes when a game ends.
+	Rating int `json:"rating,omitempty"`
+}
+
+func (*Update) Kind() string { return "update" }
+
+// RematchStatus says a rematch has been requested and is waiting for the
+// other player.
+type RematchStatus struct {
+	RoomID  string `json:"roomId"`
+	Message string `json:"message"`
+}
+
+func (*RematchStatus) Kind() string { return "rematch" }
+
+// Spectating is the first message to a spectator, with the state of the
+// game they are watching. Spectators then receive Update, ChatPosted and
+// ReactionSent messages like the players.
+type Spectating struct {
+	RoomID  string       `json:"roomId"`
+	Board   [3][3]string `json:"board"`
+	Turn    string       `json:"turn"`
+	Winner  string       `json:"winner,omitempty"`
+	Message string       `json:"message"`
+	Series
+	Clock
+	// Chat is the room's recent chat, oldest first.
+	Chat []ChatMessage `json:"chat"`
+}
+
+func (*Spectating) Kind() string { return "spectating" }
+
+// ChatMessage is one chat message.
+type ChatMessage struct {
+	// From is the sender's player ID.
+	From   string    `json:"from"`
+	Name   string    `json:"name,omitempty"`
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+// ChatPosted delivers a new chat message.
+type ChatPosted struct {
+	RoomID string `json:"roomId"`
+	ChatMessage
+}
+
+func (*ChatPosted) Kind() string { return "chat" }
+
+// ReactionSent delivers a quick reaction.
+type ReactionSent struct {
+	RoomID   string `json:"roomId"`
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name,omitempty"`
+	Reaction string `json:"reaction"`
+}
+
+func (*ReactionSent) Kind() string { return "reaction" }
+
+// OpponentDisconnected says the other player left. The room is closed.
+type OpponentDisconnected struct {
+	Message string `json:"message"`
+}
+
+func (*OpponentDisconnected) Kind() string { return "opponent_disconnected" }
+
+// RoomClosed says the room has closed, because a player left or an
+// administrator closed it. It is sent to spectators, and to players when
+// the room is closed for them.
+type RoomClosed struct {
+	RoomID  string `json:"roomId"`
+	Message string `json:"message"`
+}
+
+func (*RoomClosed) Kind() string { return "room_closed" }
+
+// Error reports a request that failed.
+type Error struct {
+	// Code is one of the error codes, such as bad_request.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (*Error) Kind() string { return "error" }
+
+// Kicked says an administrator has disconnected the player. The server
+// closes the connection after sending it.
+type Kicked struct {
+	Message string `json:"message"`
+}
+
+func (*Kicked) Kind() string { return "kicked" }
+
+// Offer is the state of a draw offer or undo request. Both players receive
+// it when the offer is made and when it is accepted, declined or expires.
+// An offer expires when a move is made or the game ends before the
+// opponent answers. An accepted offer is followed by an Update.
+type Offer struct {
+	RoomID string `json:"roomId"`
+	// Offer is draw or undo.
+	Offer string `json:"offer"`
+	// From is the ID of the player who made the offer.
+	From string `json:"from"`
+	// Status is pending, accepted, declined or expired.
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func (*Offer) Kind() string { return "offer" }
+
+// Tournament is the state of a tournament: its matches so far and the
+// current standings. Players who connect with ?tournament= receive it when
+// they join and whenever a match starts or ends; their matches then start
+// with Matched like any other game. GET /api/tournaments/{id} returns the
+// same object.
+type Tournament struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Format is single_elimination or round_robin.
+	Format string `json:"format"`
+	// Status is registering, running or finished.
+	Status string `json:"status"`
+	// BestOf is the number of games in each match.
+	BestOf int `json:"bestOf"`
+	// Round is the round being played, from 1, or 0 before the start.
+	Round  int `json:"round"`
+	Rounds int `json:"rounds"`
+	// Winner is the name of the winner once the tournament has finished.
+	Winner    string            `json:"winner,omitempty"`
+	Standings []Standing        `json:"standings"`
+	Matches   []TournamentMatch `json:"matches"`
+	// Message describes what has just happened.
+	Message string `json:"message,omitempty"`
+}
+
+func (*Tournament) Kind() string { return "tournament" }
+
+// Standing is one entrant's record in a tournament. Round robin entrants
+// are ranked by points, then by games won less games lost; single
+// elimination entrants by how far they have got.
+type Standing struct {
+	Rank int    `json:"rank"`
+	Name string `json:"name"`
+	// Seed is the entrant's place in the seeding by rating, from 1, once
+	// the tournament has started.
+	Seed   int `json:"seed"`
+	Rating int `json:"rating"`
+	Played int `json:"played"`
+	Won    int `json:"won"`
+	Drawn  int `json:"drawn"`
+	Lost   int `json:"lost"`
+	// Points are 3 for each match won and 1 for each drawn.
+	Points    int `json:"points"`
+	GamesWon  int `json:"gamesWon"`
+	GamesLost int `json:"gamesLost"`
+	// Eliminated is set for single elimination entrants who have lost.
+	Eliminated bool `json:"eliminated,omitempty"`
+}
+
+// TournamentMatch is one match in a tournament.
+type TournamentMatch struct {
+	ID    string `json:"id"`
+	Round int    `json:"round"`
+	// Players are the entrants' names. A name is empty while the place
+	// depends on an earlier match, or for a bye.
+	Players [2]string `json:"players"`
+	// Status is pending until the match's round starts, then waiting for
+	// the players to connect, playing or finished.
+	Status string `json:"status"`
+	RoomID string `json:"roomId,omitempty"`
+	// Scores are the games each player won, in the order of Players.
+	Scores [2]int `json:"scores"`
+	// Winner is the name of the winner, or draw, once the match is over.
+	Winner string `json:"winner,omitempty"`
+	// Result says how a finished match was decided: played, bye, forfeit
+	// if a player left, or walkover if a player didn't connect in time.
+	Result string `json:"result,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeClient(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Payload
+		code string
+	}{
+		{`{"type":"move","payload":{"row":0,"col":0}}`, &Move{Row: 0, Col: 0}, ""},
+		{`{"type":"move","payload":{"row":2,"col":1}}`, &Move{Row: 2, Col: 1}, ""},
+		{`{"type":"rematch"}`, &Rematch{}, ""},
+		{`{"type":"chat","payload":{"text":"hi"}}`, &Chat{Text: "hi"}, ""},
+		{`{"type":"reaction","payload":{"reaction":"clap"}}`, &Reaction{Reaction: "clap"}, ""},
+
+		{`{"type":"move","payload":{"row":0}}`, nil, BadRequest},
+		{`{"type":"move","payload":{}}`, nil, BadRequest},
+		{`{"type":"move","payload":{"row":0,"col":0,"x":1}}`, nil, BadRequest},
+		{`{"type":"move","payload":{"row":"0","col":0}}`, nil, BadRequest},
+		{`{"type":"chat","payload":{"message":"hi"}}`, nil, BadRequest},
+		{`{"type":"move","row":0,"col":0}`, nil, BadRequest},
+		{`{"type":"rematch"} {}`, nil, BadRequest},
+		{`{"payload":{}}`, nil, BadRequest},
+		{`not json`, nil, BadRequest},
+		{`{"type":"update"}`, nil, UnknownType},
+		{`{"type":"dance"}`, nil, UnknownType},
+	}
+	for _, tt := range tests {
+		got, err := DecodeClient([]byte(tt.in))
+		if tt.code != "" {
+			var perr *Error
+			if !errors.As(err, &perr) || perr.Code != tt.code {
+				t.Errorf("DecodeClient(%s) error = %v, want code %s", tt.in, err, tt.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DecodeClient(%s): %v", tt.in, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecodeClient(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	want := &Update{Board: [3][3]string{{"X"}}, Turn: "O", Series: Series{BestOf: 3, Game: 1}}
+	data, err := json.Marshal(Encode(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeServer(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %#v, want %#v", got, want)
+	}
+}
+
+func TestDecodeServer(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Payload
+		code string
+	}{
+		{`{"type":"waiting","payload":{"message":"hi"}}`, &Waiting{Message: "hi"}, ""},
+		{`{"type":"rematch"}`, &RematchStatus{}, ""},
+		// Fields added by a newer server are ignored.
+		{`{"type":"waiting","payload":{"message":"hi","position":3},"sentAt":1}`, &Waiting{Message: "hi"}, ""},
+		{`{"type":"update","payload":{"turn":"O","reason":"undo","moveTimeLeft":5,"hint":[1,1]}}`,
+			&Update{Turn: "O", Reason: "undo", Clock: Clock{MoveTimeLeft: 5}}, ""},
+
+		{`{"type":"leaderboard","payload":{}}`, nil, UnknownType},
+		{`{"type":"move","payload":{"row":0,"col":0}}`, nil, UnknownType},
+		{`{"type":"waiting","payload":{"message":3}}`, nil, BadRequest},
+		{`not json`, nil, BadRequest},
+	}
+	for _, tt := range tests {
+		got, err := DecodeServer([]byte(tt.in))
+		if tt.code != "" {
+			var perr *Error
+			if !errors.As(err, &perr) || perr.Code != tt.code {
+				t.Errorf("DecodeServer(%s) error = %v, want code %s", tt.in, err, tt.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DecodeServer(%s): %v", tt.in, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecodeServer(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKindsMatch(t *testing.T) {
+	for _, kinds := range []map[string]func() Payload{ClientKinds, ServerKinds} {
+		for kind, newPayload := range kinds {
+			if got := newPayload().Kind(); got != kind {
+				t.Errorf("payload registered as %q has Kind %q", kind, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package protocol
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite PROTOCOL.md")
+
+// TestSpec checks that PROTOCOL.md matches the spec generated from the
+// types in this package. Run go generate to update it.
+func TestSpec(t *testing.T) {
+	docs, err := parseDocs("protocol.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := docs.spec()
+
+	const path = "../PROTOCOL.md"
+	if *update {
+		if err := os.WriteFile(path, spec, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(spec, want) {
+		t.Error("PROTOCOL.md is out of date; run go generate ./protocol")
+	}
+}
+
+// sourceDocs holds the doc comments from the package source.
+type sourceDocs struct {
+	pkg    string
+	types  map[string]string            // by type name
+	fields map[string]map[string]string // by type and field name
+	codes  [][2]string                  // error code values and comments
+}
+
+func parseDocs(filename string) (*sourceDocs, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	d := &sourceDocs{
+		pkg:    f.Doc.Text(),
+		types:  make(map[string]string),
+		fields: make(map[string]map[string]string),
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				doc := spec.Doc.Text()
+				if doc == "" && len(gen.Specs) == 1 {
+					doc = gen.Doc.Text()
+				}
+				d.types[spec.Name.Name] = doc
+				st, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				fields := make(map[string]string)
+				for _, field := range st.Fields.List {
+					for _, name := range field.Names {
+						fields[name.Name] = field.Doc.Text()
+					}
+				}
+				d.fields[spec.Name.Name] = fields
+			case *ast.ValueSpec:
+				if gen.Tok != token.CONST || len(spec.Values) != 1 || spec.Comment == nil {
+					continue
+				}
+				if lit, ok := spec.Values[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					code, _ := strconv.Unquote(lit.Value)
+					d.codes = append(d.codes, [2]string{code, spec.Comment.Text()})
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *sourceDocs) spec() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Tic-Tac-Toe Wire Protocol\n\n")
+	fmt.Fprintf(&b, "<!-- Code generated from ./protocol by go generate. DO NOT EDIT. -->\n\n")
+	fmt.Fprintf(&b, "Protocol version: %d\n\n", Version)
+	fmt.Fprintf(&b, "%s\n", paragraphs(d.pkg))
+
+	shared := make(map[reflect.Type]bool)
+	d.kinds(&b, "Client Messages", ClientKinds, shared)
+	d.kinds(&b, "Server Messages", ServerKinds, shared)
+
+	fmt.Fprintf(&b, "## Shared Types\n\n")
+	var types []reflect.Type
+	for t := range shared {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name() < types[j].Name() })
+	for _, t := range types {
+		fmt.Fprintf(&b, "### %s\n\n", t.Name())
+		d.fieldTable(&b, t, shared)
+	}
+
+	fmt.Fprintf(&b, "## Error Codes\n\n| Code | Meaning |\n| --- | --- |\n")
+	for _, c := range d.codes {
+		fmt.Fprintf(&b, "| `%s` | %s |\n", c[0], strings.TrimSpace(c[1]))
+	}
+	return b.Bytes()
+}
+
+func (d *sourceDocs) kinds(b *bytes.Buffer, title string, kinds map[string]func() Payload, shared map[reflect.Type]bool) {
+	fmt.Fprintf(b, "## %s\n\n", title)
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	for _, kind := range names {
+		t := reflect.TypeOf(kinds[kind]()).Elem()
+		fmt.Fprintf(b, "### `%s`\n\n", kind)
+		if doc := d.types[t.Name()]; doc != "" {
+			fmt.Fprintf(b, "%s\n", paragraphs(doc))
+		}
+		d.fieldTable(b, t, shared)
+	}
+}
+
+// fieldTable describes the JSON fields of struct type t, including those of
+// embedded structs, and adds struct types it refers to to shared.
+func (d *sourceDocs) fieldTable(b *bytes.Buffer, t reflect.Type, shared map[reflect.Type]bool) {
+	var rows []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				walk(f.Type)
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			presence := "required"
+			if strings.Contains(opts, "omitempty") {
+				presence = "optional"
+			}
+			doc := strings.Join(strings.Fields(d.fields[t.Name()][f.Name]), " ")
+			rows = append(rows, fmt.Sprintf("| `%s` | %s | %s | %s |", name, typeName(f.Type, shared), presence, doc))
+		}
+	}
+	walk(t)
+	if len(rows) == 0 {
+		fmt.Fprintf(b, "No fields; the payload may be omitted.\n\n")
+		return
+	}
+	fmt.Fprintf(b, "| Field | Type | Presence | Description |\n| --- | --- | --- | --- |\n")
+	fmt.Fprintf(b, "%s\n\n", strings.Join(rows, "\n"))
+}
+
+func typeName(t reflect.Type, shared map[reflect.Type]bool) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "string (RFC 3339 time)"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Array:
+		return fmt.Sprintf("array[%d] of %s", t.Len(), typeName(t.Elem(), shared))
+	case reflect.Slice:
+		return "array of " + typeName(t.Elem(), shared)
+	case reflect.Map:
+		return "object of " + typeName(t.Elem(), shared)
+	case reflect.Struct:
+		shared[t] = true
+		return fmt.Sprintf("[%s](#%s)", t.Name(), strings.ToLower(t.Name()))
+	}
+	return t.String()
+}
+
+// paragraphs reformats a doc comment as Markdown, keeping indented blocks as
+// code.
+func paragraphs(doc string) string {
+	var b strings.Builder
+	inCode := false
+	for _, line := range strings.Split(strings.TrimRight(doc, "\n"), "\n") {
+		code := strings.HasPrefix(line, "\t")
+		if code && !inCode {
+			b.WriteString("```json\n")
+		} else if !code && inCode {
+			b.WriteString("```\n")
+		}
+		inCode = code
+		b.WriteString(strings.TrimPrefix(line, "\t") + "\n")
+	}
+	if inCode {
+		b.WriteString("```\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ratingsPath = flag.String("ratings", "ratings.json", "file player accounts and ratings are stored in")
+
+const (
+	// defaultRating is the rating of new and anonymous players.
+	defaultRating = 1200
+	// eloK is the maximum rating change from a single game.
+	eloK = 32
+	// maxSecretLen is the longest secret bcrypt can hash.
+	maxSecretLen = 72
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// Errors returned by AccountStore.Claim
+var (
+	errNoSecret   = errors.New("a name needs a secret")
+	errLongSecret = errors.New("secret must be at most 72 bytes")
+	errNameTaken  = errors.New("name is taken by another player")
+)
+
+// Account is a named player's persisted rating and record.
+type Account struct {
+	Name      string    `json:"name"`
+	Rating    int       `json:"rating"`
+	Games     int       `json:"games"`
+	Wins      int       `json:"wins"`
+	Losses    int       `json:"losses"`
+	Draws     int       `json:"draws"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// SecretHash is the bcrypt hash of the secret the player claimed the
+	// name with.
+	SecretHash string `json:"secretHash,omitempty"`
+}
+
+// AccountStore keeps accounts in memory and rewrites the whole set to a JSON
+// file after every change. An empty path keeps accounts in memory only.
+type AccountStore struct {
+	path     string
+	mu       sync.Mutex
+	accounts map[string]*Account
+	// claims holds the secret hashes of names claimed but not yet rated.
+	// They only become accounts, and are saved, after a rated game.
+	claims map[string]string
+}
+
+// NewAccountStore returns an empty store that saves to path.
+func NewAccountStore(path string) *AccountStore {
+	return &AccountStore{
+		path:     path,
+		accounts: make(map[string]*Account),
+		claims:   make(map[string]string),
+	}
+}
+
+// LoadAccountStore returns a store holding the accounts saved at path. A
+// missing file yields an empty store.
+func LoadAccountStore(path string) (*AccountStore, error) {
+	s := NewAccountStore(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var list []*Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		s.accounts[a.Name] = a
+	}
+	return s, nil
+}
+
+// Rating returns the named player's current rating, or defaultRating if
+// they haven't played a rated game.
+func (s *AccountStore) Rating(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.accounts[name]; ok {
+		return a.Rating
+	}
+	return defaultRating
+}
+
+// Claim checks that secret is the secret of the named player. The first
+// player to use a name claims it with their secret; anyone using the name
+// afterwards must present the same secret, so names can't be taken over to
+// play on someone else's rating. A claim is kept in memory until the name's
+// first rated game, when it is saved with the new account.
+func (s *AccountStore) Claim(name, secret string) error {
+	switch {
+	case secret == "":
+		return errNoSecret
+	case len(secret) > maxSecretLen:
+		return errLongSecret
+	}
+
+	// bcrypt is slow by design, so hash and compare without holding the lock.
+	s.mu.Lock()
+	hash := s.secretHashLocked(name)
+	s.mu.Unlock()
+	if hash != "" {
+		return checkSecret(hash, secret)
+	}
+	newHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hash := s.secretHashLocked(name); hash != "" {
+		// Claimed by another connection while we were hashing
+		return checkSecret(hash, secret)
+	}
+	if a, ok := s.accounts[name]; ok {
+		// Rated before names needed secrets
+		a.SecretHash = string(newHash)
+		return s.saveLocked()
+	}
+	s.claims[name] = string(newHash)
+	return nil
+}
+
+// secretHashLocked returns the hash of the secret name was claimed with, or
+// "" if it is unclaimed.
+func (s *AccountStore) secretHashLocked(name string) string {
+	if a, ok := s.accounts[name]; ok {
+		return a.SecretHash
+	}
+	return s.claims[name]
+}
+
+// checkSecret returns errNameTaken unless secret matches hash.
+func checkSecret(hash, secret string) error {
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) != nil {
+		return errNameTaken
+	}
+	return nil
+}
+
+// writeClaimError replies to a request whose claim to name failed with err.
+func writeClaimError(w http.ResponseWriter, name string, err error) {
+	switch err {
+	case errNoSecret, errLongSecret:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errNameTaken:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("Claiming name %s: %v", name, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+	}
+}
+
+// Top returns up to n accounts that have played, ordered by rating,
+// highest first.
+func (s *AccountStore) Top(n int) []Account {
+	s.mu.Lock()
+	list := make([]Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		if a.Games > 0 {
+			a := *a
+			a.SecretHash = ""
+			list = append(list, a)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Rating != list[j].Rating {
+			return list[i].Rating > list[j].Rating
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Record applies the result of one game between a and b, where score is 1 if
+// a won, 0 if b won and 0.5 for a draw. Either name may be empty for an
+// anonymous player, who is treated as rated defaultRating and isn't saved.
+// It returns both players' new ratings.
+func (s *AccountStore) Record(a, b string, score float64) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ra, rb := s.ratingLocked(a), s.ratingLocked(b)
+	newA, newB := elo(ra, rb, score)
+	s.updateLocked(a, newA, score)
+	s.updateLocked(b, newB, 1-score)
+	return newA, newB, s.saveLocked()
+}
+
+func (s *AccountStore) ratingLocked(name string) int {
+	if a, ok := s.accounts[name]; ok {
+		return a.Rating
+	}
+	return defaultRating
+}
+
+func (s *AccountStore) updateLocked(name string, rating int, score float64) {
+	if name == "" {
+		return
+	}
+	a, ok := s.accounts[name]
+	if !ok {
+		a = &Account{Name: name, SecretHash: s.claims[name]}
+		s.accounts[name] = a
+		delete(s.claims, name)
+	}
+	a.Rating = rating
+	a.Games++
+	switch score {
+	case 1:
+		a.Wins++
+	case 0:
+		a.Losses++
+	default:
+		a.Draws++
+	}
+	a.UpdatedAt = time.Now()
+}
+
+// saveLocked writes all accounts to a temporary file and renames it over
+// s.path, so a crash never leaves a half-written file behind.
+func (s *AccountStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".ratings-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// elo returns the new ratings of players rated ra and rb after a game in
+// which the first player scored score (1 win, 0.5 draw, 0 loss).
+func elo(ra, rb int, score float64) (int, int) {
+	expected := 1 / (1 + math.Pow(10, float64(rb-ra)/400))
+	delta := int(math.Round(eloK * (score - expected)))
+	return ra + delta, rb - delta
+}
+
+// rateGame updates the ratings of the room's players after a finished game.
+// Only games between two different named players are rated, so a rating
+// can't be farmed against anonymous opponents or oneself.
+func (s *Server) rateGame(room *Room) {
+	if len(room.Players) != 2 {
+		return
+	}
+	a, b := room.Players[0], room.Players[1]
+	if a.Name == "" || b.Name == "" || a.Name == b.Name {
+		return
+	}
+	score := 0.5
+	switch room.Winner {
+	case a.Symbol:
+		score = 1
+	case b.Symbol:
+		score = 0
+	}
+
+	newA, newB, err := s.accounts.Record(a.Name, b.Name, score)
+	if err != nil {
+		log.Printf("Saving ratings for room %s: %v", room.ID, err)
+	}
+	a.Rating, b.Rating = newA, newB
+}
+
+// handleLeaderboard serves GET /leaderboard?limit=N with the highest rated
+// named players.
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, s.accounts.Top(limit))
+}
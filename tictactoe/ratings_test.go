@@ -0,0 +1,201 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tictactoe/engine"
+)
+
+func TestElo(t *testing.T) {
+	tests := []struct {
+		ra, rb       int
+		score        float64
+		wantA, wantB int
+	}{
+		{1200, 1200, 1, 1216, 1184},
+		{1200, 1200, 0.5, 1200, 1200},
+		{1200, 1200, 0, 1184, 1216},
+		{1400, 1200, 1, 1408, 1192}, // expected win gains little
+		{1200, 1400, 1, 1224, 1376}, // upset gains a lot
+		{1400, 1200, 0.5, 1392, 1208},
+	}
+	for _, tt := range tests {
+		gotA, gotB := elo(tt.ra, tt.rb, tt.score)
+		if gotA != tt.wantA || gotB != tt.wantB {
+			t.Errorf("elo(%d, %d, %v) = %d, %d; want %d, %d", tt.ra, tt.rb, tt.score, gotA, gotB, tt.wantA, tt.wantB)
+		}
+	}
+}
+
+func TestAccountStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.json")
+	s := NewAccountStore(path)
+
+	if _, _, err := s.Record("alice", "bob", 1); err != nil {
+		t.Fatal(err)
+	}
+	// Anonymous opponents affect the named player's rating only.
+	if _, _, err := s.Record("", "bob", 0.5); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadAccountStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := s.Top(10)
+	if len(top) != 2 {
+		t.Fatalf("got %d accounts, want 2", len(top))
+	}
+	alice, bob := top[0], top[1]
+	if alice.Name != "alice" || alice.Rating != 1216 || alice.Wins != 1 || alice.Games != 1 {
+		t.Errorf("alice = %+v", alice)
+	}
+	if bob.Name != "bob" || bob.Losses != 1 || bob.Draws != 1 || bob.Games != 2 {
+		t.Errorf("bob = %+v", bob)
+	}
+	if got := s.Rating("carol"); got != defaultRating {
+		t.Errorf("Rating(carol) = %d, want %d", got, defaultRating)
+	}
+}
+
+func TestAccountClaim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.json")
+	s := NewAccountStore(path)
+	// bob was rated before names needed secrets.
+	if _, _, err := s.Record("alice2", "bob", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, secret string
+		want         error
+	}{
+		{"alice", "", errNoSecret},
+		{"alice", strings.Repeat("s", maxSecretLen+1), errLongSecret},
+		{"alice", "a-secret", nil},
+		{"alice", "a-secret", nil},
+		{"alice", "guess", errNameTaken},
+		{"bob", "b-secret", nil},
+		{"bob", "a-secret", errNameTaken},
+	}
+	for _, tt := range tests {
+		if err := s.Claim(tt.name, tt.secret); err != tt.want {
+			t.Errorf("Claim(%q, %q) = %v, want %v", tt.name, tt.secret, err, tt.want)
+		}
+	}
+
+	// alice has claimed her name but not played, so she isn't saved and her
+	// claim doesn't outlive the store.
+	reloaded, err := LoadAccountStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Claim("alice", "guess"); err != nil {
+		t.Errorf("after reload, Claim(alice, guess) = %v, want nil for a name never rated", err)
+	}
+	if err := reloaded.Claim("bob", "guess"); err != errNameTaken {
+		t.Errorf("after reload, Claim(bob, guess) = %v, want %v", err, errNameTaken)
+	}
+
+	// Her first rated game saves her claim with her account.
+	if _, _, err := s.Record("alice", "bob", 1); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "a-secret") || !strings.Contains(string(data), `"secretHash": "$2a$`) {
+		t.Errorf("saved accounts don't hold bcrypt hashes of the secrets:\n%s", data)
+	}
+	if s, err = LoadAccountStore(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Claim("alice", "guess"); err != errNameTaken {
+		t.Errorf("after rating, Claim(alice, guess) = %v, want %v", err, errNameTaken)
+	}
+	if err := s.Claim("alice", "a-secret"); err != nil {
+		t.Errorf("after rating, Claim(alice, a-secret) = %v", err)
+	}
+	for _, a := range s.Top(10) {
+		if a.SecretHash != "" {
+			t.Errorf("Top includes the secret hash of %s", a.Name)
+		}
+	}
+}
+
+func TestRateGame(t *testing.T) {
+	tests := []struct {
+		desc  string
+		x, o  string
+		rated bool
+	}{
+		{"named players", "alice", "bob", true},
+		{"anonymous opponent", "alice", "", false},
+		{"anonymous players", "", "", false},
+		{"same name", "alice", "alice", false},
+	}
+	for _, tt := range tests {
+		s := &Server{accounts: NewAccountStore("")}
+		room := &Room{
+			Game: &engine.Game{Winner: "X"},
+			Players: []*RoomPlayer{
+				{ID: "p1", Name: tt.x, Rating: defaultRating, Symbol: "X"},
+				{ID: "p2", Name: tt.o, Rating: defaultRating, Symbol: "O"},
+			},
+		}
+		s.rateGame(room)
+		if rated := room.Players[0].Rating != defaultRating; rated != tt.rated {
+			t.Errorf("%s: rated is %v, want %v", tt.desc, rated, tt.rated)
+		}
+		if got := len(s.accounts.Top(10)); tt.rated && got != 2 || !tt.rated && got != 0 {
+			t.Errorf("%s: %d accounts saved", tt.desc, got)
+		}
+	}
+}
+
+func TestLeaderboardAPI(t *testing.T) {
+	s := &Server{accounts: NewAccountStore("")}
+	s.accounts.Claim("alice", "a-secret")
+	s.accounts.Record("alice", "bob", 1)
+	s.accounts.Record("carol", "bob", 1)
+	s.accounts.Claim("dave", "d-secret") // never rated
+
+	tests := []struct {
+		url   string
+		code  int
+		names []string
+	}{
+		{"/leaderboard", http.StatusOK, []string{"alice", "carol", "bob"}},
+		{"/leaderboard?limit=2", http.StatusOK, []string{"alice", "carol"}},
+		{"/leaderboard?limit=0", http.StatusBadRequest, nil},
+		{"/leaderboard?limit=101", http.StatusBadRequest, nil},
+		{"/leaderboard?limit=top", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		var top []map[string]any
+		code := getJSON(t, s.handleLeaderboard, tt.url, &top)
+		var names []string
+		for _, a := range top {
+			names = append(names, a["name"].(string))
+			if _, ok := a["secretHash"]; ok {
+				t.Errorf("%s: %s listed with the hash of their secret", tt.url, a["name"])
+			}
+		}
+		if code != tt.code || strings.Join(names, " ") != strings.Join(tt.names, " ") {
+			t.Errorf("%s: status %d, %v; want %d, %v", tt.url, code, names, tt.code, tt.names)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.handleLeaderboard(w, httptest.NewRequest(http.MethodPost, "/leaderboard", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /leaderboard: status %d, want 405", w.Code)
+	}
+}
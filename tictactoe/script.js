@@ -1,11 +1,24 @@
+// PROTOCOL_VERSION is the version of PROTOCOL.md this client speaks.
+const PROTOCOL_VERSION = 1;
+
 class TicTacToeClient {
     constructor() {
         this.ws = null;
         this.playerId = null;
+        this.playerName = localStorage.getItem('tictactoeName') || '';
+        this.playerSecret = TicTacToeClient.secret();
+        const query = new URLSearchParams(window.location.search);
+        this.watchRoom = query.get('watch');
+        this.tournamentId = query.get('tournament');
         this.playerSymbol = null;
         this.roomId = null;
         this.currentTurn = null;
         this.gameStatus = 'connecting';
+        this.rematchRequested = false;
+        this.seriesWinner = null;
+        this.offer = null;
+        this.clock = null;
+        this.clockTimer = null;
         this.board = [
             ['', '', ''],
             ['', '', ''],
@@ -15,14 +28,42 @@ class TicTacToeClient {
         this.init();
     }
 
+    // secret returns this browser's secret, which claims any name it plays
+    // under, creating it on first use.
+    static secret() {
+        let secret = localStorage.getItem('tictactoeSecret');
+        if (!secret) {
+            const bytes = crypto.getRandomValues(new Uint8Array(16));
+            secret = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+            localStorage.setItem('tictactoeSecret', secret);
+        }
+        return secret;
+    }
+
     init() {
         this.connect();
         this.setupEventListeners();
     }
 
     connect() {
+        if (this.tournamentId && !this.playerName) {
+            this.updateStatus('Enter a name to play in the tournament');
+            return;
+        }
+
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/ws`;
+        const params = new URLSearchParams({ protocol: PROTOCOL_VERSION });
+        if (this.playerName) {
+            params.set('name', this.playerName);
+            params.set('secret', this.playerSecret);
+        }
+        if (this.watchRoom) {
+            params.set('watch', this.watchRoom);
+        }
+        if (this.tournamentId) {
+            params.set('tournament', this.tournamentId);
+        }
+        const wsUrl = `${protocol}//${window.location.host}/ws?${params}`;
         
         console.log('Connecting to:', wsUrl);
         this.updateConnectionStatus('Connecting...', false);
@@ -51,20 +92,22 @@ class TicTacToeClient {
             
             // Attempt to reconnect after 3 seconds
             setTimeout(() => {
-                if (this.gameStatus !== 'finished') {
+                if (this.gameStatus !== 'finished' && this.gameStatus !== 'kicked') {
                     this.connect();
                 }
             }, 3000);
         };
     }
 
-    handleMessage(message) {
-        console.log('Received message:', message);
+    handleMessage(envelope) {
+        console.log('Received message:', envelope);
+        const message = envelope.payload || {};
 
-        switch (message.type) {
+        switch (envelope.type) {
             case 'connected':
                 this.playerId = message.playerId;
                 this.updateStatus(message.message || 'Connected. Waiting for opponent...');
+                this.updateRating(message);
                 break;
 
             case 'waiting':
@@ -77,27 +120,90 @@ class TicTacToeClient {
                 this.currentTurn = message.turn;
                 this.board = message.board;
                 this.gameStatus = 'playing';
+                this.rematchRequested = false;
+                this.seriesWinner = null;
+                this.showOffer(null);
                 
                 this.updateStatus(message.message || 'Game started!');
                 this.updatePlayerInfo();
+                this.updateRating(message);
+                this.updateSeries(message);
+                this.updateClocks(message);
                 this.updateBoard();
                 this.updateGameControls();
+                this.showChat(message.chat);
+                break;
+
+            case 'spectating':
+                this.roomId = message.roomId;
+                this.currentTurn = message.turn;
+                this.board = message.board;
+                this.gameStatus = 'spectating';
+
+                this.updateStatus(message.message || 'Watching game');
+                this.updateSeries(message);
+                this.updateClocks(message);
+                this.updateBoard();
+                this.showChat(message.chat);
                 break;
 
             case 'update':
                 this.board = message.board;
                 this.currentTurn = message.turn;
-                this.updateStatus(message.message || message.status);
+                this.updateStatus(message.status);
+                this.updateRating(message);
+                this.updateSeries(message);
+                this.updateClocks(message);
                 this.updateBoard();
                 
-                if (message.winner) {
+                if (message.winner && this.gameStatus !== 'spectating') {
                     this.gameStatus = 'finished';
-                    this.handleGameEnd(message.winner);
+                    this.seriesWinner = message.seriesWinner || null;
+                    this.handleGameEnd(message.winner, message.seriesWinner, message.reason);
+                } else {
+                    this.updateGameControls();
                 }
                 break;
 
+            case 'offer':
+                this.updateStatus(message.message);
+                this.showOffer(message.status === 'pending' ? message : null);
+                break;
+
+            case 'tournament':
+                this.showTournament(message);
+                break;
+
+            case 'chat':
+                this.appendChat(message);
+                break;
+
+            case 'reaction':
+                this.showReaction(message);
+                break;
+
+            case 'room_closed':
+                this.updateStatus(message.message || 'The game is over');
+                if (this.gameStatus !== 'spectating') {
+                    this.gameStatus = 'closed';
+                    this.roomId = null;
+                    this.updateBoard();
+                    this.updateGameControls();
+                }
+                break;
+
+            case 'kicked':
+                this.gameStatus = 'kicked';
+                this.updateStatus(message.message || 'You were disconnected', 'error');
+                this.updateBoard();
+                break;
+
+            case 'rematch':
+                this.updateStatus(message.message || 'Rematch requested');
+                break;
+
             case 'error':
-                this.updateStatus(`Error: ${message.error}`, 'error');
+                this.updateStatus(`Error: ${message.message}`, 'error');
                 break;
 
             case 'opponent_disconnected':
@@ -121,6 +227,159 @@ class TicTacToeClient {
         newGameBtn.addEventListener('click', () => {
             this.startNewGame();
         });
+
+        const rematchBtn = document.getElementById('rematchBtn');
+        rematchBtn.addEventListener('click', () => {
+            this.requestRematch();
+        });
+
+        document.getElementById('offerDrawBtn').addEventListener('click', () => {
+            this.send('offer_draw');
+        });
+        document.getElementById('undoBtn').addEventListener('click', () => {
+            this.send('request_undo');
+        });
+        document.getElementById('resignBtn').addEventListener('click', () => {
+            if (this.gameStatus === 'playing' && confirm('Resign this game?')) {
+                this.send('resign');
+            }
+        });
+        document.getElementById('acceptOfferBtn').addEventListener('click', () => {
+            this.send('accept_offer');
+        });
+        document.getElementById('declineOfferBtn').addEventListener('click', () => {
+            this.send('decline_offer');
+        });
+
+        const chatInput = document.getElementById('chatInput');
+        document.getElementById('chatForm').addEventListener('submit', (event) => {
+            event.preventDefault();
+            const text = chatInput.value.trim();
+            if (text && this.roomId) {
+                this.send('chat', { text: text });
+                chatInput.value = '';
+            }
+        });
+
+        document.querySelectorAll('.reaction-btn').forEach(btn => {
+            btn.addEventListener('click', () => {
+                if (this.roomId) {
+                    this.send('reaction', { reaction: btn.dataset.reaction });
+                }
+            });
+        });
+
+        const nameInput = document.getElementById('nameInput');
+        nameInput.value = this.playerName;
+        document.getElementById('nameForm').addEventListener('submit', (event) => {
+            event.preventDefault();
+            this.playerName = nameInput.value.trim();
+            localStorage.setItem('tictactoeName', this.playerName);
+            this.startNewGame();
+        });
+    }
+
+    updateRating(message) {
+        const ratingEl = document.getElementById('playerRating');
+        if (!message.rating) {
+            return;
+        }
+        let text = `${message.name || 'Guest'} (${message.rating})`;
+        if (message.opponentRating) {
+            text += ` vs ${message.opponentName || 'Guest'} (${message.opponentRating})`;
+        }
+        ratingEl.textContent = text;
+    }
+
+    showTournament(tournament) {
+        document.getElementById('tournamentPanel').style.display = 'block';
+        document.getElementById('tournamentName').textContent = tournament.name;
+        let round = tournament.status;
+        if (tournament.status === 'running') {
+            round = `Round ${tournament.round} of ${tournament.rounds}`;
+        } else if (tournament.status === 'finished') {
+            round = `Winner: ${tournament.winner}`;
+        }
+        document.getElementById('tournamentRound').textContent = round;
+        document.getElementById('tournamentMessage').textContent = tournament.message || '';
+
+        const rows = (tournament.standings || []).map(standing => {
+            const row = document.createElement('tr');
+            if (standing.name === this.playerName) {
+                row.className = 'me';
+            }
+            if (standing.eliminated) {
+                row.classList.add('eliminated');
+            }
+            const cells = [standing.rank, standing.name, standing.played, standing.won,
+                standing.drawn, standing.lost, standing.points];
+            for (const value of cells) {
+                const cell = document.createElement('td');
+                cell.textContent = value;
+                row.appendChild(cell);
+            }
+            return row;
+        });
+        document.getElementById('standingsBody').replaceChildren(...rows);
+
+        if (!this.roomId) {
+            this.updateStatus(tournament.message || 'Waiting for your next match...');
+        }
+    }
+
+    showChat(chat) {
+        document.getElementById('chatLog').replaceChildren();
+        document.getElementById('chatPanel').style.display = 'block';
+        (chat || []).forEach(entry => this.appendChat(entry));
+    }
+
+    appendChat(chat) {
+        const log = document.getElementById('chatLog');
+        const line = document.createElement('div');
+        line.className = 'chat-line';
+        const name = document.createElement('span');
+        name.className = 'chat-name';
+        name.textContent = chat.from === this.playerId ? 'You' : (chat.name || 'Guest');
+        line.append(name, `: ${chat.text}`);
+        log.appendChild(line);
+        log.scrollTop = log.scrollHeight;
+    }
+
+    showReaction(message) {
+        const emoji = document.querySelector(`.reaction-btn[data-reaction="${message.reaction}"]`);
+        if (!emoji) {
+            return;
+        }
+        const bubble = document.createElement('span');
+        bubble.className = 'reaction-bubble';
+        bubble.textContent = emoji.textContent;
+        bubble.title = message.playerId === this.playerId ? 'You' : (message.name || 'Guest');
+        document.getElementById('reactionFeed').appendChild(bubble);
+        setTimeout(() => bubble.remove(), 3000);
+    }
+
+    // showOffer shows the pending draw offer or undo request, or hides it if
+    // offer is null. Only the opponent of the player who made it can answer.
+    showOffer(offer) {
+        this.offer = offer;
+        const offerBar = document.getElementById('offerBar');
+        if (offer && offer.from !== this.playerId) {
+            document.getElementById('offerText').textContent = offer.message;
+            offerBar.style.display = 'flex';
+        } else {
+            offerBar.style.display = 'none';
+        }
+        this.updateGameControls();
+    }
+
+    requestRematch() {
+        if (this.gameStatus !== 'finished' || this.rematchRequested) {
+            return;
+        }
+
+        this.rematchRequested = true;
+        this.send('rematch');
+        this.updateGameControls();
     }
 
     makeMove(row, col) {
@@ -137,15 +396,12 @@ class TicTacToeClient {
             return;
         }
 
-        const message = {
-            type: 'move',
-            playerId: this.playerId,
-            roomId: this.roomId,
-            row: row,
-            col: col
-        };
+        this.send('move', { row: row, col: col });
+    }
 
-        this.ws.send(JSON.stringify(message));
+    // send sends a message of the given type; see PROTOCOL.md.
+    send(type, payload) {
+        this.ws.send(JSON.stringify(payload ? { type, payload } : { type }));
     }
 
     updateBoard() {
@@ -201,6 +457,76 @@ class TicTacToeClient {
         roomInfo.style.display = 'block';
     }
 
+    updateSeries(message) {
+        const seriesInfo = document.getElementById('seriesInfo');
+        if (!message.bestOf || !message.scores) {
+            seriesInfo.style.display = 'none';
+            return;
+        }
+
+        const mine = message.scores[this.playerId] || 0;
+        let theirs = 0;
+        for (const [id, wins] of Object.entries(message.scores)) {
+            if (id !== this.playerId) {
+                theirs = wins;
+            }
+        }
+
+        document.getElementById('seriesScore').textContent =
+            `${mine} - ${theirs} (game ${message.game} of ${message.bestOf})`;
+        seriesInfo.style.display = 'block';
+    }
+
+    updateClocks(message) {
+        if (this.clockTimer) {
+            clearInterval(this.clockTimer);
+            this.clockTimer = null;
+        }
+
+        const clockInfo = document.getElementById('clockInfo');
+        if (!message.moveTimeLeft && !message.clocks) {
+            clockInfo.style.display = message.winner ? clockInfo.style.display : 'none';
+            return;
+        }
+
+        this.clock = {
+            receivedAt: Date.now(),
+            turn: message.turn,
+            moveTimeLeft: message.moveTimeLeft || 0,
+            clocks: message.clocks || {}
+        };
+        clockInfo.style.display = 'flex';
+        this.renderClocks();
+        this.clockTimer = setInterval(() => this.renderClocks(), 250);
+    }
+
+    renderClocks() {
+        const elapsed = Date.now() - this.clock.receivedAt;
+        const format = (ms) => {
+            const seconds = Math.max(0, Math.ceil(ms / 1000));
+            return `${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')}`;
+        };
+
+        const moveClock = document.getElementById('moveClock');
+        const moveLeft = this.clock.moveTimeLeft - elapsed;
+        moveClock.textContent = this.clock.moveTimeLeft ? `Move ${format(moveLeft)}` : 'Move -';
+        moveClock.classList.toggle('low', this.clock.moveTimeLeft > 0 && moveLeft < 10000);
+
+        for (const symbol of ['X', 'O']) {
+            const el = document.getElementById(`clock${symbol}`);
+            let left = this.clock.clocks[symbol];
+            if (left === undefined) {
+                el.textContent = `${symbol} -`;
+                continue;
+            }
+            if (symbol === this.clock.turn) {
+                left -= elapsed;
+            }
+            el.textContent = `${symbol} ${format(left)}`;
+            el.classList.toggle('low', left < 10000);
+        }
+    }
+
     updateConnectionStatus(text, connected) {
         const connectionText = document.getElementById('connectionText');
         const statusIndicator = document.getElementById('statusIndicator');
@@ -216,23 +542,47 @@ class TicTacToeClient {
     }
 
     updateGameControls() {
+        const playing = this.gameStatus === 'playing';
+        const myLastMove = this.currentTurn !== this.playerSymbol && this.board.some(row => row.some(cell => cell !== ''));
+        document.getElementById('offerDrawBtn').style.display = playing && !this.offer ? 'block' : 'none';
+        document.getElementById('undoBtn').style.display = playing && !this.offer && myLastMove ? 'block' : 'none';
+        document.getElementById('resignBtn').style.display = playing ? 'block' : 'none';
+
         const newGameBtn = document.getElementById('newGameBtn');
-        if (this.gameStatus === 'finished') {
+        const rematchBtn = document.getElementById('rematchBtn');
+        if (this.tournamentId) {
+            // Tournament matches are scheduled by the server; Rematch only
+            // plays the next game of the current match.
+            const nextGame = this.gameStatus === 'finished' && !this.seriesWinner && !this.rematchRequested;
+            newGameBtn.style.display = 'none';
+            rematchBtn.style.display = nextGame ? 'block' : 'none';
+        } else if (this.gameStatus === 'finished') {
+            newGameBtn.style.display = 'block';
+            rematchBtn.style.display = this.rematchRequested ? 'none' : 'block';
+        } else if (this.gameStatus === 'closed') {
             newGameBtn.style.display = 'block';
+            rematchBtn.style.display = 'none';
         } else {
             newGameBtn.style.display = 'none';
+            rematchBtn.style.display = 'none';
         }
     }
 
-    handleGameEnd(winner) {
+    handleGameEnd(winner, seriesWinner, reason) {
         this.updateGameControls();
         
-        if (winner === 'draw') {
-            this.updateStatus('Game ended in a draw!', 'info');
+        if (seriesWinner) {
+            if (seriesWinner === this.playerId) {
+                this.updateStatus('🏆 You won the series!', 'success');
+            } else {
+                this.updateStatus('Your opponent won the series.', 'error');
+            }
+        } else if (winner === 'draw') {
+            this.updateStatus(reason === 'agreement' ? 'Draw agreed.' : 'Game ended in a draw!', 'info');
         } else if (winner === this.playerSymbol) {
-            this.updateStatus('🎉 You won!', 'success');
+            this.updateStatus(reason === 'resignation' ? '🎉 Your opponent resigned. You won!' : '🎉 You won!', 'success');
         } else {
-            this.updateStatus('You lost. Better luck next time!', 'error');
+            this.updateStatus(reason === 'resignation' ? 'You resigned.' : 'You lost. Better luck next time!', 'error');
         }
     }
 
@@ -250,7 +600,16 @@ class TicTacToeClient {
         
         // Hide room info
         document.getElementById('roomInfo').style.display = 'none';
+        document.getElementById('seriesInfo').style.display = 'none';
+        document.getElementById('clockInfo').style.display = 'none';
+        document.getElementById('chatPanel').style.display = 'none';
+        if (this.clockTimer) {
+            clearInterval(this.clockTimer);
+            this.clockTimer = null;
+        }
+        this.showOffer(null);
         document.getElementById('newGameBtn').style.display = 'none';
+        document.getElementById('rematchBtn').style.display = 'none';
         
         // Reconnect to get matched with a new opponent
         if (this.ws) {
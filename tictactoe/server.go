@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"tictactoe/protocol"
+)
+
+// Config holds the settings applied to rooms created by a Server.
+type Config struct {
+	BestOf   int           // games in a series
+	MoveTime time.Duration // time allowed per move, or 0 for no limit
+	GameTime time.Duration // time each player has per game, or 0 for no limit
+
+	ChatFilter ChatFilter // applied to chat messages, or nil for none
+	AdminToken string     // token for the admin API, or "" to disable it
+
+	// Abuse limits; zero disables each one. AllowedOrigins may contain "*";
+	// if it is empty, only the server's own origin may connect.
+	AllowedOrigins []string
+	MaxConnsPerIP  int     // websocket connections per IP address
+	MessageRate    float64 // average messages per second per connection
+	MessageBurst   int     // messages allowed at once before MessageRate applies
+	MaxMessageSize int64   // largest message in bytes; zero means defaultMaxMessageSize
+	MaxViolations  int     // malformed or rate-limited messages before disconnecting
+
+	// TournamentNoShow is how long a tournament match waits for a player to
+	// connect before the opponent wins it; zero means no limit.
+	TournamentNoShow time.Duration
+	// TournamentNextGame is how long after a game of an undecided
+	// tournament match the next one starts, unless both players ask for it
+	// sooner.
+	TournamentNextGame time.Duration
+}
+
+// Server is one node of the game server. Rooms and the waiting queue live in
+// a RoomStore that any number of nodes may share; a node only holds the
+// sockets of the players connected to it, and reaches players on other
+// nodes through a PubSub.
+type Server struct {
+	cfg      Config
+	rooms    RoomStore
+	bus      PubSub
+	history  HistoryStore
+	accounts *AccountStore
+	metrics  Metrics
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	players map[string]*Player     // connected to this node, by ID
+	timers  map[string]*time.Timer // turn timers started by this node, by room ID
+	conns   map[string]int         // websocket connections to this node, by IP
+
+	stop chan struct{}
+}
+
+// NewServer returns a Server node and starts its matchmaker. Call Close to
+// stop it.
+func NewServer(cfg Config, rooms RoomStore, bus PubSub, history HistoryStore, accounts *AccountStore) *Server {
+	s := &Server{
+		cfg:      cfg,
+		rooms:    rooms,
+		bus:      bus,
+		history:  history,
+		accounts: accounts,
+		players:  make(map[string]*Player),
+		timers:   make(map[string]*time.Timer),
+		conns:    make(map[string]int),
+		stop:     make(chan struct{}),
+	}
+	s.upgrader.CheckOrigin = s.checkOrigin
+	go s.runMatchmaker()
+	return s
+}
+
+// Close stops the matchmaker and this node's turn timers. It does not
+// disconnect players.
+func (s *Server) Close() {
+	close(s.stop)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.timers {
+		t.Stop()
+		delete(s.timers, id)
+	}
+}
+
+// RegisterHandlers adds the server's websocket and HTTP API endpoints to mux.
+func (s *Server) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/api/games", s.handleGames)
+	mux.HandleFunc("/api/games/", s.handleGame)
+	mux.HandleFunc("/leaderboard", s.handleLeaderboard)
+	mux.HandleFunc("/api/tournaments", s.handleTournaments)
+	mux.HandleFunc("/api/tournaments/", s.handleTournament)
+	if s.cfg.AdminToken != "" {
+		s.registerAdminHandlers(mux)
+	}
+}
+
+// publish sends msg to a player, wherever they are connected.
+func (s *Server) publish(playerID string, msg protocol.Payload) {
+	if err := s.bus.Publish(playerTopic(playerID), protocol.Encode(msg)); err != nil {
+		log.Printf("Publishing to player %s: %v", playerID, err)
+	}
+}
+
+// deliver hands a message from the PubSub to a local player's socket. A
+// "matched" message also tells this node which room the player is now in,
+// since the room may have been created by another node, and a "kicked"
+// message disconnects the player once it has been written.
+func (s *Server) deliver(player *Player, msg protocol.Envelope) {
+	switch msg.Type {
+	case "kicked":
+		player.Client.SendAndClose(msg)
+		return
+	case "matched":
+		var matched protocol.Matched
+		if err := json.Unmarshal(msg.Payload, &matched); err != nil {
+			log.Printf("Decoding match for player %s: %v", player.ID, err)
+			return
+		}
+		s.mu.Lock()
+		player.RoomID = matched.RoomID
+		s.mu.Unlock()
+	}
+	player.Client.Send(msg)
+}
+
+// roomOf returns the ID of the room a local player is in, or "".
+func (s *Server) roomOf(player *Player) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return player.RoomID
+}
+
+// startTimer arranges for fn to run after d, replacing this node's timer
+// for the room.
+func (s *Server) startTimer(roomID string, d time.Duration, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.timers[roomID]; t != nil {
+		t.Stop()
+	}
+	s.timers[roomID] = time.AfterFunc(d, fn)
+}
+
+// stopTimer cancels this node's timer for the room, if any. Timers started
+// by other nodes keep running but find the room's ClockSeq has moved on.
+func (s *Server) stopTimer(roomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.timers[roomID]; t != nil {
+		t.Stop()
+		delete(s.timers, roomID)
+	}
+}
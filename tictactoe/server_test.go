@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"tictactoe/client"
+	"tictactoe/protocol"
+)
+
+// startNode runs a Server node sharing rooms and bus with any other nodes
+// started with them, and returns its websocket URL.
+func startNode(t *testing.T, rooms RoomStore, bus PubSub, history HistoryStore) string {
+	t.Helper()
+	return startNodeConfig(t, Config{BestOf: 3}, rooms, bus, history)
+}
+
+// startNodeConfig is like startNode with the given configuration.
+func startNodeConfig(t *testing.T, cfg Config, rooms RoomStore, bus PubSub, history HistoryStore) string {
+	t.Helper()
+	s := NewServer(cfg, rooms, bus, history, NewAccountStore(""))
+	t.Cleanup(s.Close)
+	mux := http.NewServeMux()
+	s.RegisterHandlers(mux)
+
+	// Websocket handlers outlive srv.Close, so wait for them to finish
+	// cleaning up after their players before the test's files go away.
+	var active sync.WaitGroup
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active.Add(1)
+		defer active.Done()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		active.Wait()
+	})
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+type testPlayer struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialPlayer(t *testing.T, url string) *testPlayer {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testPlayer{t, conn}
+}
+
+// expect reads messages until one of type T arrives. Other messages are
+// skipped, but an error fails the test unless T is *protocol.Error.
+func expect[T protocol.Payload](p *testPlayer) T {
+	p.t.Helper()
+	var want T
+	p.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := p.conn.ReadMessage()
+		if err != nil {
+			p.t.Fatalf("waiting for %q: %v", want.Kind(), err)
+		}
+		msg, err := protocol.DecodeServer(data)
+		if err != nil {
+			p.t.Fatalf("waiting for %q: %v", want.Kind(), err)
+		}
+		if m, ok := msg.(T); ok {
+			return m
+		}
+		if e, ok := msg.(*protocol.Error); ok {
+			p.t.Fatalf("waiting for %q: got error %q", want.Kind(), e.Message)
+		}
+	}
+}
+
+func (p *testPlayer) send(msg protocol.Payload) {
+	p.t.Helper()
+	if err := p.conn.WriteJSON(protocol.Encode(msg)); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func (p *testPlayer) sendRaw(msg string) {
+	p.t.Helper()
+	if err := p.conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func TestTwoNodesShareMatch(t *testing.T) {
+	backends := map[string]func(t *testing.T) (RoomStore, PubSub){
+		"memory": func(t *testing.T) (RoomStore, PubSub) {
+			return NewMemoryStore(), NewMemoryBus()
+		},
+		"file": func(t *testing.T) (RoomStore, PubSub) {
+			dir := t.TempDir()
+			rooms, err := NewFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bus, err := NewFileBus(filepath.Join(dir, "topics"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return rooms, bus
+		},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			rooms, bus := backend(t)
+			history := NewMemoryHistory()
+			node1 := startNode(t, rooms, bus, history)
+			node2 := startNode(t, rooms, bus, history)
+
+			alice := dialPlayer(t, node1)
+			expect[*protocol.Waiting](alice)
+			bob := dialPlayer(t, node2)
+
+			am, bm := expect[*protocol.Matched](alice), expect[*protocol.Matched](bob)
+			if am.RoomID == "" || am.RoomID != bm.RoomID {
+				t.Fatalf("matched into rooms %q and %q", am.RoomID, bm.RoomID)
+			}
+			if am.Symbol != "X" || bm.Symbol != "O" {
+				t.Fatalf("symbols %q and %q, want X and O", am.Symbol, bm.Symbol)
+			}
+
+			// Alice wins along the top row; every move is seen on both nodes.
+			moves := []struct {
+				p        *testPlayer
+				row, col int
+			}{
+				{alice, 0, 0}, {bob, 1, 0}, {alice, 0, 1}, {bob, 1, 1}, {alice, 0, 2},
+			}
+			var au, bu *protocol.Update
+			for _, m := range moves {
+				m.p.send(&protocol.Move{Row: m.row, Col: m.col})
+				au, bu = expect[*protocol.Update](alice), expect[*protocol.Update](bob)
+				if au.Board != bu.Board {
+					t.Fatalf("boards differ: %v and %v", au.Board, bu.Board)
+				}
+			}
+			if au.Winner != "X" || au.Status != "You won!" || bu.Status != "You lost!" {
+				t.Fatalf("final update: winner %q, statuses %q and %q", au.Winner, au.Status, bu.Status)
+			}
+			if games, _ := history.Recent(10); len(games) != 1 || len(games[0].Moves) != 5 {
+				t.Fatalf("recorded games: %+v", games)
+			}
+
+			// A rematch needs both players and swaps symbols.
+			bob.send(&protocol.Rematch{})
+			expect[*protocol.RematchStatus](alice)
+			alice.send(&protocol.Rematch{})
+			am, bm = expect[*protocol.Matched](alice), expect[*protocol.Matched](bob)
+			if am.Symbol != "O" || bm.Symbol != "X" || bm.Game != 2 {
+				t.Fatalf("rematch: symbols %q and %q, game %d", am.Symbol, bm.Symbol, bm.Game)
+			}
+
+			bob.conn.Close()
+			expect[*protocol.OpponentDisconnected](alice)
+		})
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	url := startNode(t, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+
+	if e := expect[*protocol.Error](dialPlayer(t, url+"?protocol=99")); e.Code != protocol.UnsupportedVersion {
+		t.Fatalf("protocol=99: error code %q", e.Code)
+	}
+
+	alice := dialPlayer(t, url+"?protocol=1")
+	if c := expect[*protocol.Connected](alice); c.Protocol != protocol.Version {
+		t.Fatalf("connected with protocol %d, want %d", c.Protocol, protocol.Version)
+	}
+	expect[*protocol.Waiting](alice)
+	bob := dialPlayer(t, url)
+	expect[*protocol.Matched](alice)
+	expect[*protocol.Matched](bob)
+
+	tests := []struct {
+		msg, code string
+	}{
+		{`{"type":"dance"}`, protocol.UnknownType},
+		{`{"type":"move","payload":{"row":1}}`, protocol.BadRequest},
+		{`{"type":"move","row":1,"col":1}`, protocol.BadRequest},
+		{`{"type":"chat","payload":{"text":"hi","loud":true}}`, protocol.BadRequest},
+		{`[1, 2]`, protocol.BadRequest},
+	}
+	for _, tt := range tests {
+		alice.sendRaw(tt.msg)
+		if e := expect[*protocol.Error](alice); e.Code != tt.code {
+			t.Errorf("%s: error code %q, want %q", tt.msg, e.Code, tt.code)
+		}
+	}
+
+	// A move to (0, 0) is a move, not a message without coordinates.
+	alice.sendRaw(`{"type":"move","payload":{"row":0,"col":0}}`)
+	if u := expect[*protocol.Update](bob); u.Board[0][0] != "X" {
+		t.Fatalf("board after move to (0, 0): %v", u.Board)
+	}
+}
+
+func TestGoClient(t *testing.T) {
+	url := startNode(t, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	x, err := client.Dial(ctx, url, &client.Options{Name: "xavier", Secret: "xavier-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	o, err := client.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if x.Name != "xavier" || x.Rating != defaultRating || o.PlayerID == "" {
+		t.Fatalf("connected as %+v and %+v", x, o)
+	}
+
+	for _, c := range []*client.Conn{x, o} {
+		if _, err := c.WaitMatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// X wins down the left column.
+	moves := []struct {
+		c        *client.Conn
+		row, col int
+	}{
+		{x, 0, 0}, {o, 0, 1}, {x, 1, 0}, {o, 1, 1}, {x, 2, 0},
+	}
+	var u *protocol.Update
+	for _, m := range moves {
+		if err := m.c.Move(m.row, m.col); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []*client.Conn{x, o} {
+			if u, err = c.WaitUpdate(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if u.Winner != "X" {
+		t.Fatalf("winner %q, want X", u.Winner)
+	}
+
+	// Server errors come back as *protocol.Error.
+	if err := o.Move(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	var perr *protocol.Error
+	if _, err := o.WaitUpdate(ctx); !errors.As(err, &perr) || perr.Code != protocol.IllegalMove {
+		t.Fatalf("move after game over: %v", err)
+	}
+}
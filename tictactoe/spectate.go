@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"tictactoe/engine"
+	"tictactoe/protocol"
+)
+
+// spectate adds player to the spectators of the room with the given ID and
+// sends them its current state and recent chat.
+func (s *Server) spectate(player *Player, roomID string) {
+	s.mu.Lock()
+	player.RoomID = roomID
+	s.mu.Unlock()
+
+	err := s.rooms.Update(roomID, func(room *Room) error {
+		room.Spectators = append(room.Spectators, player.ID)
+
+		sendMessage(player.Client, &protocol.Spectating{
+			RoomID:  room.ID,
+			Board:   room.Board,
+			Turn:    room.Turn,
+			Winner:  room.Winner,
+			Message: fmt.Sprintf("Watching %s. %s", matchup(room), spectatorStatus(room, "")),
+			Series:  series(room),
+			Clock:   clockState(room),
+			Chat:    room.Chat,
+		})
+		return nil
+	})
+	if err == ErrRoomNotFound {
+		sendError(player.Client, protocol.NotFound, "Room not found")
+	} else if err != nil {
+		log.Printf("Adding spectator %s to room %s: %v", player.ID, roomID, err)
+		sendError(player.Client, protocol.ServerError, "Server error")
+	}
+}
+
+// leaveRoom removes a spectator from the room they were watching.
+func (s *Server) leaveRoom(player *Player, roomID string) {
+	err := s.rooms.Update(roomID, func(room *Room) error {
+		for i, id := range room.Spectators {
+			if id == player.ID {
+				room.Spectators = append(room.Spectators[:i], room.Spectators[i+1:]...)
+				break
+			}
+		}
+		delete(room.ChatSent, player.ID)
+		delete(room.ReactionSent, player.ID)
+		return nil
+	})
+	if err != nil && err != ErrRoomNotFound {
+		log.Printf("Removing spectator %s from room %s: %v", player.ID, roomID, err)
+	}
+}
+
+// updateSpectators sends the room's current state to its spectators. reason
+// is as for broadcastUpdate.
+func (s *Server) updateSpectators(room *Room, reason string) {
+	if len(room.Spectators) == 0 {
+		return
+	}
+	msg := &protocol.Update{
+		Board:        room.Board,
+		Turn:         room.Turn,
+		Status:       spectatorStatus(room, reason),
+		Winner:       room.Winner,
+		Series:       series(room),
+		SeriesWinner: seriesWinner(room),
+		Clock:        clockState(room),
+		Reason:       reason,
+	}
+	for _, id := range room.Spectators {
+		s.publish(id, msg)
+	}
+}
+
+// spectatorStatus describes the state of room's game from a neutral point of
+// view.
+func spectatorStatus(room *Room, reason string) string {
+	switch {
+	case room.Status != "finished":
+		return fmt.Sprintf("%s's turn", room.Turn)
+	case room.Winner == engine.Draw && reason == "agreement":
+		return "Draw agreed."
+	case room.Winner == engine.Draw:
+		return "Game ended in a draw!"
+	case reason == "timeout":
+		return fmt.Sprintf("%s ran out of time. %s won!", engine.Other(room.Winner), room.Winner)
+	case reason == "resignation":
+		return fmt.Sprintf("%s resigned. %s won!", engine.Other(room.Winner), room.Winner)
+	default:
+		return fmt.Sprintf("%s won!", room.Winner)
+	}
+}
+
+// matchup describes who is playing in room, such as "alice (X) vs bob (O)".
+func matchup(room *Room) string {
+	desc := ""
+	for i, p := range room.Players {
+		if i > 0 {
+			desc += " vs "
+		}
+		name := p.Name
+		if name == "" {
+			name = "Guest"
+		}
+		desc += fmt.Sprintf("%s (%s)", name, p.Symbol)
+	}
+	return desc
+}
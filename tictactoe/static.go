@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"flag"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+// embeddedStatic holds the web client, built into the binary so that it
+// runs from any directory.
+//
+//go:embed index.html script.js style.css
+var embeddedStatic embed.FS
+
+var staticDir = flag.String("static-dir", "", "serve the web client from this directory instead of the copy built into the binary, for live editing")
+
+// staticFiles maps the URL paths of the web client to its file names. Only
+// these files are served, so nothing else in the directory leaks out.
+var staticFiles = map[string]string{
+	"/":           "index.html",
+	"/index.html": "index.html",
+	"/script.js":  "script.js",
+	"/style.css":  "style.css",
+}
+
+// staticHandler serves the web client. Files from the embedded copy are
+// read once and revalidated by browsers with an ETag on every load, so a
+// new build is picked up at once; with live set they are read on every
+// request and not cached at all.
+type staticHandler struct {
+	fsys  fs.FS
+	live  bool
+	files map[string][]byte // contents by name, unless live
+	etags map[string]string // by name, unless live
+}
+
+// newStaticHandler returns a handler serving the web client from fsys. It
+// fails if any of the client's files is missing.
+func newStaticHandler(fsys fs.FS, live bool) (*staticHandler, error) {
+	h := &staticHandler{
+		fsys:  fsys,
+		live:  live,
+		files: make(map[string][]byte),
+		etags: make(map[string]string),
+	}
+	for _, name := range staticFiles {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if live {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		h.files[name] = data
+		h.etags[name] = `"` + hex.EncodeToString(sum[:8]) + `"`
+	}
+	return h, nil
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := staticFiles[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	data := h.files[name]
+	if h.live {
+		var err error
+		if data, err = fs.ReadFile(h.fsys, name); err != nil {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", h.etags[name])
+	}
+	// ServeContent sets the content type from the name and answers
+	// If-None-Match with 304 Not Modified.
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
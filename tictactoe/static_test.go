@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticHandler(t *testing.T) {
+	h, err := newStaticHandler(embeddedStatic, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("GET", "/", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<title>") {
+		t.Fatalf("GET /: status %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("GET /: Content-Type %q", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("GET /: Cache-Control %q", cc)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /: no ETag")
+	}
+	if w := get("GET", "/index.html", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("GET /index.html with matching ETag: status %d", w.Code)
+	}
+	if w := get("GET", "/script.js", nil); !strings.Contains(w.Header().Get("Content-Type"), "javascript") {
+		t.Errorf("GET /script.js: Content-Type %q", w.Header().Get("Content-Type"))
+	}
+
+	// Nothing but the client's own files is served.
+	for _, path := range []string{"/main.go", "/go.mod", "/ratings.json", "/../main.go", "/static/", "/index.html/"} {
+		if w := get("GET", path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, w.Code)
+		}
+	}
+	if w := get("POST", "/", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /: status %d, want 405", w.Code)
+	}
+}
+
+func TestStaticHandlerLive(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html": {Data: []byte("v1")},
+		"script.js":  {Data: []byte("")},
+		"style.css":  {Data: []byte("")},
+	}
+	h, err := newStaticHandler(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["index.html"].Data = []byte("v2")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "v2" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("live GET /: body %q, Cache-Control %q", w.Body, w.Header().Get("Cache-Control"))
+	}
+
+	delete(files, "style.css")
+	if _, err := newStaticHandler(files, true); err == nil {
+		t.Fatal("newStaticHandler succeeded without style.css")
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+// ErrRoomNotFound is returned by RoomStore methods for unknown room IDs.
+var ErrRoomNotFound = errors.New("room not found")
+
+// QueueEntry is a player waiting to be matched.
+type QueueEntry struct {
+	PlayerID string    `json:"playerId"`
+	Name     string    `json:"name,omitempty"`
+	Rating   int       `json:"rating"`
+	QueuedAt time.Time `json:"queuedAt"`
+}
+
+// A RoomStore holds the rooms, waiting queue and tournaments shared by every
+// server node. Rooms and tournaments are copied in and out of the store, so a
+// *Room or *Tournament is only valid for the duration of the call that
+// produced it.
+type RoomStore interface {
+	// Create adds a new room.
+	Create(room *Room) error
+	// Get returns a copy of the room with the given ID, or ErrRoomNotFound.
+	Get(id string) (*Room, error)
+	// Rooms returns a copy of every room, in no particular order.
+	Rooms() ([]*Room, error)
+	// Update calls fn with exclusive access to the room with the given ID
+	// and saves the room afterwards, unless fn returns an error, which
+	// Update then returns. It returns ErrRoomNotFound for unknown IDs.
+	Update(id string, fn func(*Room) error) error
+	// Delete removes a room. Deleting an unknown room is not an error.
+	Delete(id string) error
+	// UpdateQueue calls fn with exclusive access to the waiting queue and
+	// saves the queue it returns, unless fn returns an error.
+	UpdateQueue(fn func([]QueueEntry) ([]QueueEntry, error)) error
+	// Queue returns a copy of the waiting queue.
+	Queue() ([]QueueEntry, error)
+
+	// CreateTournament adds a new tournament.
+	CreateTournament(t *Tournament) error
+	// GetTournament returns a copy of the tournament with the given ID, or
+	// ErrTournamentNotFound.
+	GetTournament(id string) (*Tournament, error)
+	// Tournaments returns a copy of every tournament, in no particular
+	// order.
+	Tournaments() ([]*Tournament, error)
+	// UpdateTournament is Update for tournaments. fn may create rooms.
+	UpdateTournament(id string, fn func(*Tournament) error) error
+}
+
+// A PubSub delivers messages to whichever node holds a player's socket.
+// Each connected player has a topic; see playerTopic.
+type PubSub interface {
+	// Publish sends msg to every current subscriber of topic.
+	Publish(topic string, msg protocol.Envelope) error
+	// Subscribe calls fn, in publication order, for each message later
+	// published to topic until cancel is called. fn must not block or call
+	// back into the RoomStore.
+	Subscribe(topic string, fn func(protocol.Envelope)) (cancel func(), err error)
+}
+
+// playerTopic is the PubSub topic messages for a player are published on.
+func playerTopic(playerID string) string {
+	return "player." + playerID
+}
+
+// MemoryStore is a RoomStore for nodes running in a single process. It keeps
+// rooms in encoded form so that, as with a shared external store, no node
+// can see another's changes except through Update.
+type MemoryStore struct {
+	mu    sync.Mutex
+	rooms map[string][]byte
+
+	// queueMu and tournamentMu are separate from mu because matching
+	// players from the queue and scheduling tournament matches create
+	// rooms.
+	queueMu sync.Mutex
+	queue   []QueueEntry
+
+	tournamentMu sync.Mutex
+	tournaments  map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rooms:       make(map[string][]byte),
+		tournaments: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) Create(room *Room) error {
+	data, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms[room.ID] = data
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*Room, error) {
+	s.mu.Lock()
+	data, ok := s.rooms[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	var room Room
+	if err := json.Unmarshal(data, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func (s *MemoryStore) Rooms() ([]*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, data := range s.rooms {
+		var room Room
+		if err := json.Unmarshal(data, &room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, &room)
+	}
+	return rooms, nil
+}
+
+func (s *MemoryStore) Update(id string, fn func(*Room) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.rooms[id]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	var room Room
+	if err := json.Unmarshal(data, &room); err != nil {
+		return err
+	}
+	if err := fn(&room); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&room)
+	if err != nil {
+		return err
+	}
+	s.rooms[id] = data
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rooms, id)
+	return nil
+}
+
+func (s *MemoryStore) UpdateQueue(fn func([]QueueEntry) ([]QueueEntry, error)) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	queue, err := fn(append([]QueueEntry(nil), s.queue...))
+	if err != nil {
+		return err
+	}
+	s.queue = queue
+	return nil
+}
+
+func (s *MemoryStore) Queue() ([]QueueEntry, error) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	return append([]QueueEntry(nil), s.queue...), nil
+}
+
+func (s *MemoryStore) CreateTournament(t *Tournament) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	s.tournamentMu.Lock()
+	defer s.tournamentMu.Unlock()
+	s.tournaments[t.ID] = data
+	return nil
+}
+
+func (s *MemoryStore) GetTournament(id string) (*Tournament, error) {
+	s.tournamentMu.Lock()
+	data, ok := s.tournaments[id]
+	s.tournamentMu.Unlock()
+	if !ok {
+		return nil, ErrTournamentNotFound
+	}
+	var t Tournament
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *MemoryStore) Tournaments() ([]*Tournament, error) {
+	s.tournamentMu.Lock()
+	defer s.tournamentMu.Unlock()
+	list := make([]*Tournament, 0, len(s.tournaments))
+	for _, data := range s.tournaments {
+		var t Tournament
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		list = append(list, &t)
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) UpdateTournament(id string, fn func(*Tournament) error) error {
+	s.tournamentMu.Lock()
+	defer s.tournamentMu.Unlock()
+	data, ok := s.tournaments[id]
+	if !ok {
+		return ErrTournamentNotFound
+	}
+	var t Tournament
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	if err := fn(&t); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&t)
+	if err != nil {
+		return err
+	}
+	s.tournaments[id] = data
+	return nil
+}
+
+// MemoryBus is a PubSub for nodes running in a single process. Messages
+// are delivered synchronously, each subscriber getting its own copy.
+type MemoryBus struct {
+	mu   sync.RWMutex
+	subs map[string]map[int]func(protocol.Envelope)
+	next int
+}
+
+// NewMemoryBus returns a MemoryBus with no subscribers.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[string]map[int]func(protocol.Envelope))}
+}
+
+func (b *MemoryBus) Publish(topic string, msg protocol.Envelope) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subs[topic] {
+		fn(protocol.Envelope{Type: msg.Type, Payload: bytes.Clone(msg.Payload)})
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(topic string, fn func(protocol.Envelope)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[int]func(protocol.Envelope))
+	}
+	id := b.next
+	b.next++
+	b.subs[topic][id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[topic], id)
+		if len(b.subs[topic]) == 0 {
+			delete(b.subs, topic)
+		}
+	}, nil
+}
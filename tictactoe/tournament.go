@@ -0,0 +1,837 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"tictactoe/protocol"
+)
+
+var tournamentNoShow = flag.Duration("tournament-no-show", 2*time.Minute, "how long a tournament match waits for a player to connect before awarding it to their opponent (0 to wait forever)")
+var tournamentNextGame = flag.Duration("tournament-next-game", 5*time.Second, "pause between the games of a tournament match")
+
+// Tournament formats.
+const (
+	SingleElimination = "single_elimination"
+	RoundRobin        = "round_robin"
+)
+
+// ErrTournamentNotFound is returned by RoomStore methods for unknown
+// tournament IDs.
+var ErrTournamentNotFound = errors.New("tournament not found")
+
+var (
+	errRegistrationClosed = errors.New("registration has closed")
+	errTournamentFull     = errors.New("the tournament is full")
+	errTooFewEntrants     = errors.New("a tournament needs at least two players")
+)
+
+// Tournament is a set of matches between registered players, either a
+// single elimination bracket or a round robin. Like rooms, tournaments are
+// kept in the RoomStore so that every node can schedule their matches.
+type Tournament struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Status string `json:"status"` // "registering", "running", "finished"
+
+	BestOf     int `json:"bestOf"`               // games in each match
+	MaxPlayers int `json:"maxPlayers,omitempty"` // zero means no limit
+
+	Entrants  []*Entrant `json:"entrants"` // in seed order once running
+	Matches   []*Match   `json:"matches"`  // in round order
+	Winner    string     `json:"winner,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// Entrant is a player registered for a tournament. Entrants are known by
+// name, since they may connect afresh for each match.
+type Entrant struct {
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
+	Seed   int    `json:"seed,omitempty"`
+	// PlayerID is the entrant's connection to the tournament, or "" while
+	// they aren't connected.
+	PlayerID string `json:"playerId,omitempty"`
+}
+
+// Match is one match of a tournament. Index is its position in its round,
+// from 0; ReadyAt is when it began waiting for its players.
+type Match struct {
+	protocol.TournamentMatch
+	Index   int       `json:"index"`
+	ReadyAt time.Time `json:"readyAt"`
+}
+
+// newTournament returns a tournament open for registration.
+func newTournament(name, format string, bestOf, maxPlayers int) (*Tournament, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "" || len(name) > 50:
+		return nil, errors.New("name must be 1-50 characters")
+	case format != SingleElimination && format != RoundRobin:
+		return nil, fmt.Errorf("format must be %s or %s", SingleElimination, RoundRobin)
+	case bestOf < 1:
+		return nil, errors.New("bestOf must be at least 1")
+	case maxPlayers < 0 || maxPlayers == 1:
+		return nil, errors.New("maxPlayers must be at least 2, or 0 for no limit")
+	}
+	return &Tournament{
+		ID:         generateTournamentID(),
+		Name:       name,
+		Format:     format,
+		Status:     "registering",
+		BestOf:     bestOf,
+		MaxPlayers: maxPlayers,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// entrant returns the entrant with the given name, or nil.
+func (t *Tournament) entrant(name string) *Entrant {
+	for _, e := range t.Entrants {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// match returns the match with the given ID, or nil.
+func (t *Tournament) match(id string) *Match {
+	for _, m := range t.Matches {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// register adds a player to t. Registering twice is not an error, so
+// entrants can reconnect once registration has closed.
+func (t *Tournament) register(name string, rating int) error {
+	if t.entrant(name) != nil {
+		return nil
+	}
+	if t.Status != "registering" {
+		return errRegistrationClosed
+	}
+	if t.MaxPlayers > 0 && len(t.Entrants) >= t.MaxPlayers {
+		return errTournamentFull
+	}
+	t.Entrants = append(t.Entrants, &Entrant{Name: name, Rating: rating})
+	return nil
+}
+
+// start closes registration, seeds the entrants by their current rating,
+// best first, and draws up the matches.
+func (t *Tournament) start(rating func(name string) int) error {
+	if t.Status != "registering" {
+		return errors.New("the tournament has already started")
+	}
+	if len(t.Entrants) < 2 {
+		return errTooFewEntrants
+	}
+	for _, e := range t.Entrants {
+		e.Rating = rating(e.Name)
+	}
+	// Entrants with the same rating keep their registration order.
+	sort.SliceStable(t.Entrants, func(i, j int) bool {
+		return t.Entrants[i].Rating > t.Entrants[j].Rating
+	})
+	for i, e := range t.Entrants {
+		e.Seed = i + 1
+	}
+
+	t.Status = "running"
+	if t.Format == SingleElimination {
+		t.Matches = singleElimination(t.Entrants)
+	} else {
+		t.Matches = roundRobin(t.Entrants)
+	}
+	for _, m := range t.Matches {
+		if m.Round == 1 && m.Players[1] == "" {
+			t.finish(m, m.Players[0], [2]int{}, "bye")
+		}
+	}
+	return nil
+}
+
+// singleElimination draws up a bracket for entrants, who are in seed order.
+// The bracket is padded to a power of two with byes, which go to the top
+// seeds, and seeded so that the top two can only meet in the final.
+func singleElimination(entrants []*Entrant) []*Match {
+	size := 2
+	for size < len(entrants) {
+		size *= 2
+	}
+	order := bracketOrder(size)
+
+	var matches []*Match
+	for round, n := 1, size/2; n >= 1; round, n = round+1, n/2 {
+		for i := 0; i < n; i++ {
+			m := newMatch(round, i)
+			if round == 1 {
+				for j, seed := range order[2*i : 2*i+2] {
+					if seed <= len(entrants) {
+						m.Players[j] = entrants[seed-1].Name
+					}
+				}
+			}
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// bracketOrder returns the seeds 1 to size in bracket order: neighbours
+// meet in the first round, and seed s would meet seed size+1-s.
+func bracketOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// roundRobin pairs every entrant with every other once, using the circle
+// method: the first entrant stays put while the rest rotate, so each round
+// has everyone play at most once. With an odd number of entrants one sits
+// out each round.
+func roundRobin(entrants []*Entrant) []*Match {
+	names := make([]string, 0, len(entrants)+1)
+	for _, e := range entrants {
+		names = append(names, e.Name)
+	}
+	if len(names)%2 == 1 {
+		names = append(names, "") // sits out
+	}
+	n := len(names)
+
+	var matches []*Match
+	for round := 1; round < n; round++ {
+		index := 0
+		for i := 0; i < n/2; i++ {
+			a, b := names[i], names[n-1-i]
+			if a == "" || b == "" {
+				continue
+			}
+			m := newMatch(round, index)
+			m.Players = [2]string{a, b}
+			matches = append(matches, m)
+			index++
+		}
+		names = append([]string{names[0], names[n-1]}, names[1:n-1]...)
+	}
+	return matches
+}
+
+func newMatch(round, index int) *Match {
+	return &Match{
+		TournamentMatch: protocol.TournamentMatch{
+			ID:     fmt.Sprintf("r%dm%d", round, index+1),
+			Round:  round,
+			Status: "pending",
+		},
+		Index: index,
+	}
+}
+
+// round returns the round being played: the earliest with a match still to
+// finish, or the last once all have. It is 0 before the tournament starts.
+func (t *Tournament) round() int {
+	current, last := 0, 0
+	for _, m := range t.Matches {
+		last = max(last, m.Round)
+		if m.Status != "finished" && (current == 0 || m.Round < current) {
+			current = m.Round
+		}
+	}
+	if current == 0 {
+		return last
+	}
+	return current
+}
+
+// finish records the result of m. winner is the winner's name, or "draw".
+// In a single elimination the winner takes their place in the next round.
+// The tournament is over once every match has finished.
+func (t *Tournament) finish(m *Match, winner string, scores [2]int, result string) {
+	m.Status, m.Winner, m.Scores, m.Result = "finished", winner, scores, result
+	if t.Format == SingleElimination {
+		for _, next := range t.Matches {
+			if next.Round == m.Round+1 && next.Index == m.Index/2 {
+				next.Players[m.Index%2] = winner
+			}
+		}
+	}
+
+	for _, m := range t.Matches {
+		if m.Status != "finished" {
+			return
+		}
+	}
+	t.Status = "finished"
+	t.Winner = t.standings()[0].Name
+}
+
+// standings ranks the entrants on their results so far.
+func (t *Tournament) standings() []protocol.Standing {
+	list := make([]protocol.Standing, len(t.Entrants))
+	byName := make(map[string]*protocol.Standing)
+	for i, e := range t.Entrants {
+		list[i] = protocol.Standing{Name: e.Name, Seed: e.Seed, Rating: e.Rating}
+		byName[e.Name] = &list[i]
+	}
+	reached := make(map[string]int) // furthest round, for single elimination
+	for _, m := range t.Matches {
+		for _, name := range m.Players {
+			reached[name] = max(reached[name], m.Round)
+		}
+		if m.Status != "finished" || m.Result == "bye" {
+			continue
+		}
+		for i, name := range m.Players {
+			st := byName[name]
+			st.Played++
+			st.GamesWon += m.Scores[i]
+			st.GamesLost += m.Scores[1-i]
+			switch m.Winner {
+			case "draw":
+				st.Drawn++
+				st.Points++
+			case name:
+				st.Won++
+				st.Points += 3
+			default:
+				st.Lost++
+				st.Eliminated = t.Format == SingleElimination
+			}
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if t.Format == SingleElimination {
+			if a.Eliminated != b.Eliminated {
+				return !a.Eliminated
+			}
+			if reached[a.Name] != reached[b.Name] {
+				return reached[a.Name] > reached[b.Name]
+			}
+		} else {
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+			if da, db := a.GamesWon-a.GamesLost, b.GamesWon-b.GamesLost; da != db {
+				return da > db
+			}
+		}
+		return a.Seed < b.Seed
+	})
+	for i := range list {
+		list[i].Rank = i + 1
+	}
+	return list
+}
+
+// view returns t as sent to clients. message describes what has just
+// happened, if anything.
+func (t *Tournament) view(message string) *protocol.Tournament {
+	v := &protocol.Tournament{
+		ID:        t.ID,
+		Name:      t.Name,
+		Format:    t.Format,
+		Status:    t.Status,
+		BestOf:    t.BestOf,
+		Round:     t.round(),
+		Winner:    t.Winner,
+		Standings: t.standings(),
+		Matches:   make([]protocol.TournamentMatch, 0, len(t.Matches)),
+		Message:   message,
+	}
+	for _, m := range t.Matches {
+		v.Matches = append(v.Matches, m.TournamentMatch)
+		v.Rounds = max(v.Rounds, m.Round)
+	}
+	return v
+}
+
+// describeMatch says how a finished match was decided.
+func describeMatch(m *Match) string {
+	a, b := m.Players[0], m.Players[1]
+	sa, sb := m.Scores[0], m.Scores[1]
+	if m.Winner == b {
+		a, b, sa, sb = b, a, sb, sa
+	}
+	switch {
+	case m.Result == "forfeit":
+		return fmt.Sprintf("%s beat %s, who left", a, b)
+	case m.Result == "walkover":
+		return fmt.Sprintf("%s beat %s by walkover", a, b)
+	case m.Winner == "draw":
+		return fmt.Sprintf("%s and %s drew %d-%d", a, b, sa, sb)
+	default:
+		return fmt.Sprintf("%s beat %s %d-%d", a, b, sa, sb)
+	}
+}
+
+// joinTournament adds a player who connected with ?tournament= to the
+// tournament, registering them if it hasn't started, and marks them
+// connected so that their matches can begin.
+func (s *Server) joinTournament(player *Player) {
+	err := s.rooms.UpdateTournament(player.TournamentID, func(t *Tournament) error {
+		message := fmt.Sprintf("%s is ready", player.Name)
+		if t.entrant(player.Name) == nil {
+			message = fmt.Sprintf("%s registered", player.Name)
+		}
+		if err := t.register(player.Name, player.Rating); err != nil {
+			return err
+		}
+		t.entrant(player.Name).PlayerID = player.ID
+		s.scheduleMatches(t, time.Now())
+		s.publishTournament(t, message)
+		return nil
+	})
+	switch err {
+	case nil:
+		log.Printf("Player %s joined tournament %s as %s", player.ID, player.TournamentID, player.Name)
+	case ErrTournamentNotFound:
+		sendError(player.Client, protocol.NotFound, "Tournament not found")
+	case errRegistrationClosed, errTournamentFull:
+		sendError(player.Client, protocol.Forbidden, "Can't join the tournament: "+err.Error())
+	default:
+		log.Printf("Adding player %s to tournament %s: %v", player.ID, player.TournamentID, err)
+		sendError(player.Client, protocol.ServerError, "Server error")
+	}
+}
+
+// leaveTournament marks a tournament player as gone. A match of theirs that
+// hasn't started goes to their opponent unless they come back in time; one
+// in progress was forfeited when they left its room.
+func (s *Server) leaveTournament(player *Player) {
+	err := s.rooms.UpdateTournament(player.TournamentID, func(t *Tournament) error {
+		if e := t.entrant(player.Name); e != nil && e.PlayerID == player.ID {
+			e.PlayerID = ""
+		}
+		return nil
+	})
+	if err != nil && err != ErrTournamentNotFound {
+		log.Printf("Removing player %s from tournament %s: %v", player.ID, player.TournamentID, err)
+	}
+}
+
+// scheduleMatches starts the matches of t's current round whose players
+// are both connected. A match that has waited longer than the no-show limit
+// goes to whichever player is connected, or to the higher seed if neither
+// is. It reports whether any match changed.
+func (s *Server) scheduleMatches(t *Tournament, now time.Time) bool {
+	if t.Status != "running" {
+		return false
+	}
+	changed := false
+	for progress := true; progress && t.Status == "running"; {
+		progress = false
+		round := t.round()
+		for _, m := range t.Matches {
+			if m.Round != round || m.Players[0] == "" || m.Players[1] == "" {
+				continue
+			}
+			if m.Status == "pending" {
+				m.Status, m.ReadyAt = "waiting", now
+				changed = true
+			}
+			if m.Status != "waiting" {
+				continue
+			}
+
+			a, b := t.entrant(m.Players[0]), t.entrant(m.Players[1])
+			switch {
+			case a.PlayerID != "" && b.PlayerID != "":
+				if err := s.startMatch(t, m, a, b); err != nil {
+					log.Printf("Starting tournament %s match %s: %v", t.ID, m.ID, err)
+					continue
+				}
+				changed = true
+			case s.cfg.TournamentNoShow > 0 && now.Sub(m.ReadyAt) >= s.cfg.TournamentNoShow:
+				winner := a
+				if b.PlayerID != "" || (a.PlayerID == "" && b.Seed < a.Seed) {
+					winner = b
+				}
+				t.finish(m, winner.Name, [2]int{}, "walkover")
+				log.Printf("Tournament %s match %s: %s", t.ID, m.ID, describeMatch(m))
+				changed, progress = true, true
+			}
+		}
+	}
+	return changed
+}
+
+// startMatch opens a room for match m between entrants a and b.
+func (s *Server) startMatch(t *Tournament, m *Match, a, b *Entrant) error {
+	room := s.newRoom(
+		&RoomPlayer{ID: a.PlayerID, Name: a.Name, Rating: s.accounts.Rating(a.Name)},
+		&RoomPlayer{ID: b.PlayerID, Name: b.Name, Rating: s.accounts.Rating(b.Name)},
+	)
+	room.BestOf = t.BestOf
+	room.TournamentID, room.MatchID = t.ID, m.ID
+	room.DrawAllowed = t.Format == RoundRobin
+	if err := s.openRoom(room); err != nil {
+		return err
+	}
+	m.Status, m.RoomID = "playing", room.ID
+	return nil
+}
+
+// matchWinner reports whether a tournament room's match has been decided,
+// and if so the ID of the winner, or "" for a draw. A match is won by
+// winning its series, or by leading once BestOf games have been played. A
+// match still level by then is drawn if the room allows draws, and
+// otherwise goes on until a game is won.
+func matchWinner(room *Room) (winner string, decided bool) {
+	if id := seriesWinner(room); id != "" {
+		return id, true
+	}
+	if room.Status != "finished" || room.GameNumber < room.BestOf {
+		return "", false
+	}
+	a, b := room.Players[0].ID, room.Players[1].ID
+	switch {
+	case room.Scores[a] > room.Scores[b]:
+		return a, true
+	case room.Scores[b] > room.Scores[a]:
+		return b, true
+	}
+	return "", room.DrawAllowed
+}
+
+// scheduleNextGame starts the next game of a tournament room's undecided
+// match after the configured pause, so a player who is behind can't hold up
+// the match, and the rest of the tournament, by never asking for it. It
+// uses the room's turn timer, which the finished game has stopped.
+func (s *Server) scheduleNextGame(room *Room) {
+	id, seq := room.ID, room.ClockSeq
+	s.startTimer(id, s.cfg.TournamentNextGame, func() {
+		err := s.rooms.Update(id, func(room *Room) error {
+			if room.Status != "finished" || room.MatchSettled || seq != room.ClockSeq {
+				return errStaleTimer
+			}
+			s.startNextGame(room)
+			return nil
+		})
+		if err != nil && err != errStaleTimer && err != ErrRoomNotFound {
+			log.Printf("Starting the next game in room %s: %v", id, err)
+		}
+	})
+}
+
+// matchResult is the outcome of a tournament match, as reported by its
+// room.
+type matchResult struct {
+	RoomID  string
+	MatchID string
+	Winner  string // name, or "draw"
+	Scores  [2]int // in the order of the room's players
+	Result  string
+}
+
+// settleMatch reports the result of a tournament room's match once it has
+// been decided. quitter is the ID of a player who has left the room, and so
+// loses an undecided match, or "". It must be called from inside
+// RoomStore.Update; the tournament is updated once the room is unlocked.
+func (s *Server) settleMatch(room *Room, quitter string) {
+	if room.TournamentID == "" || room.MatchSettled {
+		return
+	}
+	r := matchResult{RoomID: room.ID, MatchID: room.MatchID, Result: "played"}
+	winner, decided := matchWinner(room)
+	if !decided && quitter != "" {
+		if seat := room.player(quitter); seat != nil {
+			winner, decided, r.Result = opponentOf(room, seat).ID, true, "forfeit"
+		}
+	}
+	if !decided {
+		return
+	}
+	room.MatchSettled = true
+
+	r.Winner = "draw"
+	for i, p := range room.Players {
+		r.Scores[i] = room.Scores[p.ID]
+		if p.ID == winner {
+			r.Winner = p.Name
+		}
+	}
+	for _, id := range room.Spectators {
+		s.publish(id, &protocol.RoomClosed{RoomID: room.ID, Message: "The match is over"})
+	}
+	go s.recordMatch(room.TournamentID, r)
+}
+
+// recordMatch records a match's result in its tournament, deletes its
+// room and starts whichever matches can now be played.
+func (s *Server) recordMatch(tournamentID string, r matchResult) {
+	if err := s.rooms.Delete(r.RoomID); err != nil {
+		log.Printf("Deleting room %s: %v", r.RoomID, err)
+	}
+	err := s.rooms.UpdateTournament(tournamentID, func(t *Tournament) error {
+		m := t.match(r.MatchID)
+		if m == nil || m.RoomID != r.RoomID || m.Status != "playing" {
+			return nil // already settled
+		}
+		t.finish(m, r.Winner, r.Scores, r.Result)
+		message := describeMatch(m)
+		log.Printf("Tournament %s match %s: %s", t.ID, m.ID, message)
+		if t.Status == "finished" {
+			message += fmt.Sprintf(". %s wins the tournament!", t.Winner)
+		}
+		s.scheduleMatches(t, time.Now())
+		s.publishTournament(t, message)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Recording tournament %s match %s: %v", tournamentID, r.MatchID, err)
+	}
+}
+
+// replayMatch puts a tournament match whose room was closed without a
+// result back in the schedule.
+func (s *Server) replayMatch(tournamentID, matchID, roomID string) {
+	err := s.rooms.UpdateTournament(tournamentID, func(t *Tournament) error {
+		m := t.match(matchID)
+		if m == nil || m.RoomID != roomID || m.Status != "playing" {
+			return nil
+		}
+		m.Status, m.RoomID, m.ReadyAt = "waiting", "", time.Now()
+		s.scheduleMatches(t, time.Now())
+		s.publishTournament(t, fmt.Sprintf("%s vs %s will be replayed", m.Players[0], m.Players[1]))
+		return nil
+	})
+	if err != nil {
+		log.Printf("Replaying tournament %s match %s: %v", tournamentID, matchID, err)
+	}
+}
+
+// publishTournament sends t's state to its connected entrants.
+func (s *Server) publishTournament(t *Tournament, message string) {
+	view := t.view(message)
+	for _, e := range t.Entrants {
+		if e.PlayerID != "" {
+			s.publish(e.PlayerID, view)
+		}
+	}
+}
+
+// sweepTournaments schedules matches in every running tournament, so that
+// walkovers are awarded even when nobody connects or finishes a match.
+func (s *Server) sweepTournaments(now time.Time) {
+	list, err := s.rooms.Tournaments()
+	if err != nil {
+		log.Printf("Listing tournaments: %v", err)
+		return
+	}
+	for _, t := range list {
+		if t.Status != "running" {
+			continue
+		}
+		err := s.rooms.UpdateTournament(t.ID, func(t *Tournament) error {
+			if s.scheduleMatches(t, now) {
+				s.publishTournament(t, "")
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Scheduling tournament %s: %v", t.ID, err)
+		}
+	}
+}
+
+// handleTournaments serves GET /api/tournaments, listing every tournament,
+// newest first.
+func (s *Server) handleTournaments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list, err := s.rooms.Tournaments()
+	if err != nil {
+		log.Printf("Listing tournaments: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	views := make([]*protocol.Tournament, 0, len(list))
+	for _, t := range list {
+		views = append(views, t.view(""))
+	}
+	writeJSON(w, views)
+}
+
+// handleTournament serves GET /api/tournaments/{id} with the tournament's
+// matches and standings, and POST /api/tournaments/{id}/players to register
+// a player, given as {"name": "..."}.
+func (s *Server) handleTournament(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/tournaments/"), "/")
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		t, err := s.rooms.GetTournament(id)
+		if err == ErrTournamentNotFound {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Printf("Fetching tournament %s: %v", id, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, t.view(""))
+	case action == "players" && r.Method == http.MethodPost:
+		s.handleRegister(w, r, id)
+	case action == "" || action == "players":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleRegister registers a player for tournament id ahead of its start.
+// Like a connection, a registration must claim the name with its secret.
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Name   string `json:"name"`
+		Secret string `json:"secret"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validName.MatchString(req.Name) {
+		http.Error(w, "name must be 3-20 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+	if err := s.accounts.Claim(req.Name, req.Secret); err != nil {
+		writeClaimError(w, req.Name, err)
+		return
+	}
+
+	var view *protocol.Tournament
+	err := s.rooms.UpdateTournament(id, func(t *Tournament) error {
+		if err := t.register(req.Name, s.accounts.Rating(req.Name)); err != nil {
+			return err
+		}
+		view = t.view("")
+		s.publishTournament(t, fmt.Sprintf("%s registered", req.Name))
+		return nil
+	})
+	switch err {
+	case nil:
+		writeJSON(w, view)
+	case ErrTournamentNotFound:
+		http.NotFound(w, r)
+	case errRegistrationClosed, errTournamentFull:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Registering %s for tournament %s: %v", req.Name, id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handleAdminTournaments serves POST /admin/tournaments, which creates a
+// tournament from {"name", "format", "bestOf", "maxPlayers"}. bestOf
+// defaults to the server's series length.
+func (s *Server) handleAdminTournaments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Name       string `json:"name"`
+		Format     string `json:"format"`
+		BestOf     int    `json:"bestOf"`
+		MaxPlayers int    `json:"maxPlayers"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.BestOf == 0 {
+		req.BestOf = s.cfg.BestOf
+	}
+	t, err := newTournament(req.Name, req.Format, req.BestOf, req.MaxPlayers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.rooms.CreateTournament(t); err != nil {
+		log.Printf("Creating tournament: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Tournament %s (%s, %s) created", t.ID, t.Name, t.Format)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t.view(""))
+}
+
+// handleAdminTournament serves POST /admin/tournaments/{id}/start, which
+// closes registration and schedules the first round.
+func (s *Server) handleAdminTournament(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/tournaments/"), "/")
+	if id == "" || action != "start" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var view *protocol.Tournament
+	var startErr error
+	err := s.rooms.UpdateTournament(id, func(t *Tournament) error {
+		if startErr = t.start(s.accounts.Rating); startErr != nil {
+			return startErr
+		}
+		s.scheduleMatches(t, time.Now())
+		view = t.view("The tournament has started")
+		s.publishTournament(t, view.Message)
+		return nil
+	})
+	switch {
+	case err == nil:
+		log.Printf("Tournament %s started", id)
+		writeJSON(w, view)
+	case err == ErrTournamentNotFound:
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+	case err == startErr:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Starting tournament %s: %v", id, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+	}
+}
+
+// decodeBody decodes a small JSON request body into v, rejecting unknown
+// fields.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func generateTournamentID() string {
+	return "tournament_" + randomString(6)
+}
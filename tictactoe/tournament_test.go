@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/client"
+	"tictactoe/protocol"
+)
+
+func TestBracketOrder(t *testing.T) {
+	want := []int{1, 8, 4, 5, 2, 7, 3, 6}
+	if got := bracketOrder(8); !reflect.DeepEqual(got, want) {
+		t.Errorf("bracketOrder(8) = %v, want %v", got, want)
+	}
+}
+
+// newTestTournament returns a started tournament whose entrants, in seed
+// order, are named p1, p2 and so on.
+func newTestTournament(t *testing.T, format string, n int) *Tournament {
+	t.Helper()
+	tour, err := newTournament("Test", format, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		if err := tour.register(fmt.Sprintf("p%d", i), defaultRating); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tour.start(func(string) int { return defaultRating }); err != nil {
+		t.Fatal(err)
+	}
+	return tour
+}
+
+func TestSingleElimination(t *testing.T) {
+	tour := newTestTournament(t, SingleElimination, 5)
+	if err := tour.register("late", defaultRating); err != errRegistrationClosed {
+		t.Errorf("registering after the start: %v", err)
+	}
+
+	// Five players fill an eight-place bracket; the top three seeds get byes.
+	var got []string
+	for _, m := range tour.Matches {
+		got = append(got, fmt.Sprintf("%s:%s-%s:%s", m.ID, m.Players[0], m.Players[1], m.Status))
+	}
+	want := []string{
+		"r1m1:p1-:finished", "r1m2:p4-p5:pending", "r1m3:p2-:finished", "r1m4:p3-:finished",
+		"r2m1:p1-:pending", "r2m2:p2-p3:pending",
+		"r3m1:-:pending",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("bracket:\n got %v\nwant %v", got, want)
+	}
+	if r := tour.round(); r != 1 {
+		t.Errorf("round = %d, want 1", r)
+	}
+
+	tour.finish(tour.match("r1m2"), "p5", [2]int{0, 1}, "played")
+	tour.finish(tour.match("r2m1"), "p1", [2]int{1, 0}, "played")
+	tour.finish(tour.match("r2m2"), "p3", [2]int{0, 1}, "played")
+	if m := tour.match("r3m1"); m.Players != [2]string{"p1", "p3"} {
+		t.Fatalf("final players %v", m.Players)
+	}
+	tour.finish(tour.match("r3m1"), "p3", [2]int{0, 1}, "played")
+	if tour.Status != "finished" || tour.Winner != "p3" {
+		t.Fatalf("status %q, winner %q", tour.Status, tour.Winner)
+	}
+
+	var order []string
+	for _, st := range tour.standings() {
+		order = append(order, st.Name)
+	}
+	if want := []string{"p3", "p1", "p2", "p5", "p4"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("standings %v, want %v", order, want)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	for n := 2; n <= 7; n++ {
+		tour := newTestTournament(t, RoundRobin, n)
+		pairs := make(map[[2]string]bool)
+		played := make(map[string]map[int]bool) // rounds each player played in
+		for _, m := range tour.Matches {
+			a, b := m.Players[0], m.Players[1]
+			if a > b {
+				a, b = b, a
+			}
+			if pairs[[2]string{a, b}] {
+				t.Errorf("%d players: %s and %s meet twice", n, a, b)
+			}
+			pairs[[2]string{a, b}] = true
+			for _, p := range m.Players {
+				if played[p] == nil {
+					played[p] = make(map[int]bool)
+				}
+				if played[p][m.Round] {
+					t.Errorf("%d players: %s plays twice in round %d", n, p, m.Round)
+				}
+				played[p][m.Round] = true
+			}
+		}
+		if len(pairs) != n*(n-1)/2 {
+			t.Errorf("%d players: %d pairings, want %d", n, len(pairs), n*(n-1)/2)
+		}
+	}
+}
+
+func TestRoundRobinStandings(t *testing.T) {
+	tour := newTestTournament(t, RoundRobin, 4)
+	// Games won by each player, keyed by the pairing.
+	games := map[[2]string][2]int{
+		{"p1", "p2"}: {0, 2},
+		{"p1", "p3"}: {2, 1},
+		{"p1", "p4"}: {1, 1},
+		{"p2", "p3"}: {1, 2},
+		{"p2", "p4"}: {2, 0},
+		{"p3", "p4"}: {2, 0},
+	}
+	for _, m := range tour.Matches {
+		scores, ok := games[m.Players]
+		if !ok {
+			rev := games[[2]string{m.Players[1], m.Players[0]}]
+			scores = [2]int{rev[1], rev[0]}
+		}
+		winner := "draw"
+		if scores[0] > scores[1] {
+			winner = m.Players[0]
+		} else if scores[1] > scores[0] {
+			winner = m.Players[1]
+		}
+		tour.finish(m, winner, scores, "played")
+	}
+
+	// p2 and p3 both won twice, but p2 has the better game difference.
+	var got []string
+	for _, st := range tour.standings() {
+		got = append(got, fmt.Sprintf("%d %s %d", st.Rank, st.Name, st.Points))
+	}
+	if want := []string{"1 p2 6", "2 p3 6", "3 p1 4", "4 p4 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("standings %v, want %v", got, want)
+	}
+	if tour.Status != "finished" || tour.Winner != "p2" {
+		t.Errorf("status %q, winner %q", tour.Status, tour.Winner)
+	}
+}
+
+func TestTournamentWalkover(t *testing.T) {
+	s := NewServer(Config{TournamentNoShow: time.Minute}, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory(), NewAccountStore(""))
+	defer s.Close()
+
+	tour := newTestTournament(t, SingleElimination, 2)
+	tour.entrant("p2").PlayerID = "player_p2"
+	now := time.Now()
+	if !s.scheduleMatches(tour, now) || tour.Matches[0].Status != "waiting" {
+		t.Fatalf("match %+v, want waiting", tour.Matches[0])
+	}
+	if s.scheduleMatches(tour, now.Add(time.Minute-time.Second)) {
+		t.Fatal("match changed before the no-show limit")
+	}
+	s.scheduleMatches(tour, now.Add(time.Minute))
+	if m := tour.Matches[0]; m.Winner != "p2" || m.Result != "walkover" || tour.Winner != "p2" {
+		t.Fatalf("match %+v, tournament winner %q", m, tour.Winner)
+	}
+}
+
+// apiRequest makes a request with a JSON body to url, decoding a JSON
+// response into v if it is not nil, and returns the status code.
+func apiRequest(t *testing.T, method, url, token string, body, v any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// playTournament plays c's tournament matches, always taking the first
+// empty cell, and returns the final state of the tournament.
+func playTournament(ctx context.Context, c *client.Conn) (*protocol.Tournament, error) {
+	var symbol string
+	move := func(board [3][3]string, turn string) error {
+		if turn != symbol {
+			return nil
+		}
+		for r := range board {
+			for col := range board[r] {
+				if board[r][col] == "" {
+					return c.Move(r, col)
+				}
+			}
+		}
+		return nil
+	}
+	for {
+		msg, err := c.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch msg := msg.(type) {
+		case *protocol.Matched:
+			symbol = msg.Symbol
+			err = move(msg.Board, msg.Turn)
+		case *protocol.Update:
+			if msg.Winner == "" {
+				err = move(msg.Board, msg.Turn)
+			}
+		case *protocol.Tournament:
+			if msg.Status == "finished" {
+				return msg, nil
+			}
+		case *protocol.Error:
+			err = msg
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func TestTournament(t *testing.T) {
+	const token = "secret"
+	wsURL := startNodeConfig(t, Config{BestOf: 3, AdminToken: token}, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+	base := "http" + strings.TrimSuffix(strings.TrimPrefix(wsURL, "ws"), "/ws")
+
+	if code := apiRequest(t, "POST", base+"/admin/tournaments", "", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("creating without a token: status %d", code)
+	}
+	if code := apiRequest(t, "POST", base+"/admin/tournaments", token, map[string]any{"name": "Cup", "format": "swiss"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("creating with an unknown format: status %d", code)
+	}
+	var tour protocol.Tournament
+	body := map[string]any{"name": "Cup", "format": SingleElimination, "bestOf": 1}
+	if code := apiRequest(t, "POST", base+"/admin/tournaments", token, body, &tour); code != http.StatusCreated || tour.Status != "registering" {
+		t.Fatalf("create: status %d, %+v", code, tour)
+	}
+
+	// Register in order, so that the seeds are known: alice gets a bye
+	// and bob plays carol for the other place in the final.
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if code := apiRequest(t, "POST", base+"/api/tournaments/"+tour.ID+"/players", "", map[string]string{"name": name, "secret": name + "-secret"}, nil); code != http.StatusOK {
+			t.Fatalf("registering %s: status %d", name, code)
+		}
+	}
+	// Registering claims the name, so nobody can enter in someone else's.
+	for _, tt := range []struct {
+		name, secret string
+		code         int
+	}{
+		{"alice", "guess", http.StatusForbidden},
+		{"dave", "", http.StatusBadRequest},
+	} {
+		body := map[string]string{"name": tt.name, "secret": tt.secret}
+		if code := apiRequest(t, "POST", base+"/api/tournaments/"+tour.ID+"/players", "", body, nil); code != tt.code {
+			t.Fatalf("registering %s with secret %q: status %d, want %d", tt.name, tt.secret, code, tt.code)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results := make(chan *protocol.Tournament, 3)
+	errs := make(chan error, 3)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		c, err := client.Dial(ctx, wsURL, &client.Options{Name: name, Secret: name + "-secret", Tournament: tour.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		// Wait until the server has seen the player, so none of them
+		// misses the start.
+		if _, err := client.Wait[*protocol.Tournament](ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			final, err := playTournament(ctx, c)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- final
+		}()
+	}
+
+	if code := apiRequest(t, "POST", base+"/admin/tournaments/"+tour.ID+"/start", token, nil, &tour); code != http.StatusOK || tour.Status != "running" {
+		t.Fatalf("start: status %d, %+v", code, tour)
+	}
+	if code := apiRequest(t, "POST", base+"/admin/tournaments/"+tour.ID+"/start", token, nil, nil); code != http.StatusConflict {
+		t.Fatalf("starting twice: status %d", code)
+	}
+
+	// X always wins with the bots' strategy, and the first player in a
+	// match, who is the one placed higher in the bracket, plays X.
+	for i := 0; i < 3; i++ {
+		select {
+		case final := <-results:
+			if final.Winner != "alice" {
+				t.Errorf("winner %q, want alice", final.Winner)
+			}
+		case err := <-errs:
+			t.Fatal(err)
+		}
+	}
+
+	if code := apiRequest(t, "GET", base+"/api/tournaments/"+tour.ID, "", nil, &tour); code != http.StatusOK {
+		t.Fatalf("get: status %d", code)
+	}
+	var got []string
+	for _, st := range tour.Standings {
+		got = append(got, fmt.Sprintf("%d %s %d-%d", st.Rank, st.Name, st.Won, st.Lost))
+	}
+	if want := []string{"1 alice 1-0", "2 bob 1-1", "3 carol 0-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("standings %v, want %v", got, want)
+	}
+	var list []protocol.Tournament
+	if code := apiRequest(t, "GET", base+"/api/tournaments", "", nil, &list); code != http.StatusOK || len(list) != 1 {
+		t.Errorf("list: status %d, %d tournaments", code, len(list))
+	}
+}
+
+// TestTournamentForfeit plays a round robin match between players on
+// different nodes, one of whom leaves.
+func TestTournamentForfeit(t *testing.T) {
+	backends := map[string]func(t *testing.T) (RoomStore, PubSub){
+		"memory": func(t *testing.T) (RoomStore, PubSub) {
+			return NewMemoryStore(), NewMemoryBus()
+		},
+		"file": func(t *testing.T) (RoomStore, PubSub) {
+			dir := t.TempDir()
+			rooms, err := NewFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bus, err := NewFileBus(filepath.Join(dir, "topics"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return rooms, bus
+		},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			const token = "secret"
+			rooms, bus := backend(t)
+			cfg := Config{BestOf: 3, AdminToken: token}
+			node1 := startNodeConfig(t, cfg, rooms, bus, NewMemoryHistory())
+			node2 := startNodeConfig(t, cfg, rooms, bus, NewMemoryHistory())
+			base := "http" + strings.TrimSuffix(strings.TrimPrefix(node1, "ws"), "/ws")
+
+			var tour protocol.Tournament
+			body := map[string]any{"name": "League", "format": RoundRobin}
+			if code := apiRequest(t, "POST", base+"/admin/tournaments", token, body, &tour); code != http.StatusCreated {
+				t.Fatalf("create: status %d", code)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			dial := func(url, name string) *client.Conn {
+				c, err := client.Dial(ctx, url, &client.Options{Name: name, Secret: name + "-secret", Tournament: tour.ID})
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { c.Close() })
+				if _, err := client.Wait[*protocol.Tournament](ctx, c); err != nil {
+					t.Fatal(err)
+				}
+				return c
+			}
+			alice, bob := dial(node1, "alice"), dial(node2, "bob")
+
+			if code := apiRequest(t, "POST", base+"/admin/tournaments/"+tour.ID+"/start", token, nil, nil); code != http.StatusOK {
+				t.Fatalf("start: status %d", code)
+			}
+			if _, err := alice.WaitMatch(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := bob.WaitMatch(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			// Leaving the room loses the whole match, not just the game.
+			bob.Close()
+			for {
+				final, err := client.Wait[*protocol.Tournament](ctx, alice)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if final.Status != "finished" {
+					continue
+				}
+				if m := final.Matches[0]; final.Winner != "alice" || m.Result != "forfeit" {
+					t.Fatalf("winner %q, match %+v", final.Winner, m)
+				}
+				break
+			}
+		})
+	}
+}
+
+// TestTournamentNextGame plays a best-of-3 match through without either
+// player asking for a rematch: each game starts on its own.
+func TestTournamentNextGame(t *testing.T) {
+	const token = "secret"
+	cfg := Config{AdminToken: token, TournamentNextGame: 10 * time.Millisecond}
+	wsURL := startNodeConfig(t, cfg, NewMemoryStore(), NewMemoryBus(), NewMemoryHistory())
+	base := "http" + strings.TrimSuffix(strings.TrimPrefix(wsURL, "ws"), "/ws")
+
+	var tour protocol.Tournament
+	body := map[string]any{"name": "Final", "format": SingleElimination, "bestOf": 3}
+	if code := apiRequest(t, "POST", base+"/admin/tournaments", token, body, &tour); code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if code := apiRequest(t, "POST", base+"/api/tournaments/"+tour.ID+"/players", "", map[string]string{"name": name, "secret": name + "-secret"}, nil); code != http.StatusOK {
+			t.Fatalf("registering %s: status %d", name, code)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results := make(chan *protocol.Tournament, 2)
+	errs := make(chan error, 2)
+	for _, name := range []string{"alice", "bob"} {
+		c, err := client.Dial(ctx, wsURL, &client.Options{Name: name, Secret: name + "-secret", Tournament: tour.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := client.Wait[*protocol.Tournament](ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			final, err := playTournament(ctx, c)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- final
+		}()
+	}
+	if code := apiRequest(t, "POST", base+"/admin/tournaments/"+tour.ID+"/start", token, nil, nil); code != http.StatusOK {
+		t.Fatalf("start: status %d", code)
+	}
+
+	// X always wins and the symbols swap after each game, so alice wins
+	// the first and third games.
+	for i := 0; i < 2; i++ {
+		select {
+		case final := <-results:
+			if m := final.Matches[0]; final.Winner != "alice" || m.Scores != [2]int{2, 1} || m.Result != "played" {
+				t.Fatalf("winner %q, match %+v", final.Winner, m)
+			}
+		case err := <-errs:
+			t.Fatal(err)
+		}
+	}
+}